@@ -7,6 +7,8 @@ import (
 	"daily-planner-backend/internal/database"
 	"daily-planner-backend/internal/handlers"
 	"daily-planner-backend/internal/middleware"
+	"daily-planner-backend/internal/scheduler"
+	"daily-planner-backend/internal/storage"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -16,6 +18,7 @@ func main() {
 	cfg := config.Load()
 
 	middleware.SetJWTSecret(cfg.JWTSecret)
+	handlers.SetUploadMaxBytes(cfg.UploadMaxBytes)
 
 	if err := database.Init(cfg); err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
@@ -25,6 +28,20 @@ func main() {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
+	if err := database.EnsureFullTextIndexes(); err != nil {
+		log.Printf("Full-text search unavailable, falling back to LIKE: %v", err)
+	}
+
+	if err := storage.Init(cfg); err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	scheduler.Start(cfg.SchedulerInterval,
+		scheduler.Job{Name: "recurring-expenses", Run: handlers.MaterializeDueRecurringExpenses},
+		scheduler.Job{Name: "installments", Run: handlers.MaterializeDueInstallments},
+		scheduler.Job{Name: "overdue-loans", Run: handlers.RemindOverdueLoans},
+	)
+
 	r := gin.Default()
 
 	// CORS 配置
@@ -42,6 +59,9 @@ func main() {
 		auth.POST("/login", handlers.Login)
 	}
 
+	// 本地存储的签名下载地址（签名即授权）
+	r.GET(storage.LocalFilesPath+"*key", handlers.ServeLocalFile)
+
 	// Admin routes (public login)
 	r.POST("/api/admin/login", handlers.AdminLogin)
 
@@ -52,6 +72,10 @@ func main() {
 		admin.GET("/users", handlers.GetUsers)
 		admin.POST("/users", handlers.AdminCreateUser)
 		admin.PUT("/users/:id/password", handlers.AdminResetPassword)
+		admin.GET("/exchange-rates", handlers.AdminGetExchangeRates)
+		admin.PUT("/exchange-rates", handlers.AdminUpsertExchangeRates)
+		admin.POST("/exchange-rates/import", handlers.AdminImportExchangeRates)
+		admin.DELETE("/exchange-rates/:id", handlers.AdminDeleteExchangeRate)
 	}
 
 	// Protected routes
@@ -66,23 +90,218 @@ func main() {
 		api.PUT("/user/profile", handlers.UpdateProfile)
 		api.PUT("/user/password", handlers.ChangePassword)
 
+		// 应用密码（CalDAV 等客户端使用）
+		api.GET("/user/app-tokens", handlers.GetAppTokens)
+		api.POST("/user/app-tokens", handlers.CreateAppToken)
+		api.DELETE("/user/app-tokens/:id", handlers.DeleteAppToken)
+
 		// Plans
 		api.GET("/plans", handlers.GetPlans)
 		api.POST("/plans", handlers.CreatePlan)
 		api.PUT("/plans/:id", handlers.UpdatePlan)
 		api.DELETE("/plans/:id", handlers.DeletePlan)
+		api.GET("/plans/:id/attachments", handlers.GetPlanAttachments)
+		api.POST("/plans/:id/attachments", handlers.UploadPlanAttachment)
+
+		// Shared plan lists
+		api.GET("/lists", handlers.GetPlanLists)
+		api.POST("/lists", handlers.CreatePlanList)
+		api.PUT("/lists/:id", handlers.UpdatePlanList)
+		api.DELETE("/lists/:id", handlers.DeletePlanList)
+		api.GET("/lists/:id/members", handlers.GetPlanListMembers)
+		api.POST("/lists/:id/share", handlers.SharePlanList)
+		api.PUT("/lists/:id/members/:user_id", handlers.UpdatePlanListMember)
+		api.DELETE("/lists/:id/members/:user_id", handlers.RemovePlanListMember)
+		api.GET("/invitations", handlers.GetInvitations)
+		api.POST("/invitations/:id/accept", handlers.AcceptInvitation)
+		api.POST("/invitations/:id/decline", handlers.DeclineInvitation)
+
+		// Plan templates
+		api.GET("/templates", handlers.GetPlanTemplates)
+		api.GET("/templates/:id", handlers.GetPlanTemplate)
+		api.POST("/templates", handlers.CreatePlanTemplate)
+		api.PUT("/templates/:id", handlers.UpdatePlanTemplate)
+		api.DELETE("/templates/:id", handlers.DeletePlanTemplate)
+		api.POST("/templates/:id/apply", handlers.ApplyPlanTemplate)
+
+		// Focus sessions
+		api.GET("/focus/current", handlers.GetCurrentFocus)
+		api.POST("/focus/start", handlers.StartFocus)
+		api.POST("/focus/pause", handlers.PauseFocus)
+		api.POST("/focus/resume", handlers.ResumeFocus)
+		api.POST("/focus/stop", handlers.StopFocus)
+		api.GET("/focus/sessions", handlers.GetFocusSessions)
+		api.GET("/focus/report", handlers.GetFocusReport)
+
+		// Habits
+		api.GET("/habits", handlers.GetHabits)
+		api.POST("/habits", handlers.CreateHabit)
+		api.GET("/habits/heatmap", handlers.GetHabitHeatmap)
+		api.PUT("/habits/:id", handlers.UpdateHabit)
+		api.DELETE("/habits/:id", handlers.DeleteHabit)
+		api.GET("/habits/:id/checkins", handlers.GetHabitCheckIns)
+		api.POST("/habits/:id/checkins", handlers.CheckInHabit)
+		api.DELETE("/habits/:id/checkins/:day", handlers.DeleteHabitCheckIn)
+		api.GET("/habits/:id/heatmap", handlers.GetHabitHeatmap)
+
+		// Exchange rates
+		api.GET("/exchange-rates", handlers.GetExchangeRates)
+
+		// Expense categories
+		api.GET("/categories", handlers.GetCategories)
+		api.POST("/categories", handlers.CreateCategory)
+		api.PUT("/categories/:id", handlers.UpdateCategory)
+		api.DELETE("/categories/:id", handlers.DeleteCategory)
+		api.POST("/categories/:id/merge", handlers.MergeCategory)
+		api.GET("/category-rules", handlers.GetCategoryRules)
+		api.POST("/category-rules", handlers.CreateCategoryRule)
+		api.PUT("/category-rules/:id", handlers.UpdateCategoryRule)
+		api.DELETE("/category-rules/:id", handlers.DeleteCategoryRule)
+
+		// Shared ledgers
+		api.GET("/ledgers", handlers.GetLedgers)
+		api.POST("/ledgers", handlers.CreateLedger)
+		api.PUT("/ledgers/:id", handlers.UpdateLedger)
+		api.DELETE("/ledgers/:id", handlers.DeleteLedger)
+		api.GET("/ledgers/:id/members", handlers.GetLedgerMembers)
+		api.POST("/ledgers/:id/members", handlers.InviteLedgerMember)
+		api.DELETE("/ledgers/:id/members/:user_id", handlers.RemoveLedgerMember)
+		api.GET("/ledgers/:id/expenses", handlers.GetLedgerExpenses)
+		api.GET("/ledgers/:id/settle-up", handlers.GetLedgerSettleUp)
+		api.GET("/ledgers/:id/settlements", handlers.GetLedgerSettlements)
+		api.POST("/ledgers/:id/settlements", handlers.CreateLedgerSettlement)
+		api.GET("/ledger-invitations", handlers.GetLedgerInvitations)
+		api.POST("/ledger-invitations/:id/accept", handlers.AcceptLedgerInvitation)
+		api.POST("/ledger-invitations/:id/decline", handlers.DeclineLedgerInvitation)
+
+		// Accounts and transfers
+		api.GET("/accounts", handlers.GetAccounts)
+		api.GET("/accounts/balances", handlers.GetAccountBalances)
+		api.POST("/accounts", handlers.CreateAccount)
+		api.PUT("/accounts/:id", handlers.UpdateAccount)
+		api.DELETE("/accounts/:id", handlers.DeleteAccount)
+		api.GET("/transfers", handlers.GetTransfers)
+		api.POST("/transfers", handlers.CreateTransfer)
+		api.PUT("/transfers/:id", handlers.UpdateTransfer)
+		api.DELETE("/transfers/:id", handlers.DeleteTransfer)
+
+		// Installment plans
+		api.GET("/installments", handlers.GetInstallments)
+		api.GET("/installments/:id", handlers.GetInstallment)
+		api.POST("/installments", handlers.CreateInstallment)
+		api.PUT("/installments/:id", handlers.UpdateInstallment)
+		api.DELETE("/installments/:id", handlers.DeleteInstallment)
+		api.POST("/installments/:id/settle", handlers.SettleInstallment)
+
+		// Reimbursements
+		api.GET("/reimbursements/claims", handlers.GetClaims)
+		api.GET("/reimbursements/claims/:id", handlers.GetClaim)
+		api.POST("/reimbursements/claims", handlers.CreateClaim)
+		api.PUT("/reimbursements/claims/:id", handlers.UpdateClaim)
+		api.DELETE("/reimbursements/claims/:id", handlers.DeleteClaim)
+		api.POST("/reimbursements/claims/:id/submit", handlers.SubmitClaim)
+		api.POST("/reimbursements/claims/:id/reject", handlers.RejectClaim)
+		api.POST("/reimbursements/claims/:id/pay", handlers.PayClaim)
+		api.GET("/reimbursements/summary", handlers.GetReimbursementSummary)
+
+		// Recurring expenses
+		api.GET("/recurring-expenses", handlers.GetRecurringExpenses)
+		api.GET("/recurring-expenses/upcoming", handlers.GetUpcomingCharges)
+		api.POST("/recurring-expenses", handlers.CreateRecurringExpense)
+		api.PUT("/recurring-expenses/:id", handlers.UpdateRecurringExpense)
+		api.DELETE("/recurring-expenses/:id", handlers.DeleteRecurringExpense)
+
+		// Budgets
+		api.GET("/budgets", handlers.GetBudgets)
+		api.GET("/budgets/status", handlers.GetBudgetStatus)
+		api.POST("/budgets", handlers.CreateBudget)
+		api.PUT("/budgets/:id", handlers.UpdateBudget)
+		api.DELETE("/budgets/:id", handlers.DeleteBudget)
+
+		// Notifications
+		api.GET("/notifications", handlers.GetNotifications)
+		api.PUT("/notifications/:id/read", handlers.MarkNotificationRead)
+		api.POST("/notifications/read-all", handlers.MarkAllNotificationsRead)
+
+		// Import / export
+		api.POST("/import/expenses", handlers.ImportExpenses)
+		api.GET("/export/expenses", handlers.ExportExpenses)
+
+		// Statistics
+		api.GET("/stats/expenses", handlers.GetExpenseStats)
+		api.GET("/stats/cashflow", handlers.GetCashFlow)
+
+		// Goals
+		api.GET("/goals", handlers.GetGoals)
+		api.GET("/goals/:id", handlers.GetGoal)
+		api.POST("/goals", handlers.CreateGoal)
+		api.PUT("/goals/:id", handlers.UpdateGoal)
+		api.DELETE("/goals/:id", handlers.DeleteGoal)
+
+		// Savings goals
+		api.GET("/savings-goals", handlers.GetSavingsGoals)
+		api.GET("/savings-goals/:id", handlers.GetSavingsGoal)
+		api.POST("/savings-goals", handlers.CreateSavingsGoal)
+		api.PUT("/savings-goals/:id", handlers.UpdateSavingsGoal)
+		api.DELETE("/savings-goals/:id", handlers.DeleteSavingsGoal)
+		api.POST("/savings-goals/:id/entries", handlers.CreateSavingsEntry)
+		api.DELETE("/savings-goals/:id/entries/:entry_id", handlers.DeleteSavingsEntry)
+
+		// Loans
+		api.GET("/loans", handlers.GetLoans)
+		api.GET("/loans/summary", handlers.GetLoanSummary)
+		api.GET("/loans/:id", handlers.GetLoan)
+		api.POST("/loans", handlers.CreateLoan)
+		api.PUT("/loans/:id", handlers.UpdateLoan)
+		api.DELETE("/loans/:id", handlers.DeleteLoan)
+		api.POST("/loans/:id/repayments", handlers.CreateLoanRepayment)
+		api.DELETE("/loans/:id/repayments/:repayment_id", handlers.DeleteLoanRepayment)
 
 		// Expenses
 		api.GET("/expenses", handlers.GetExpenses)
+		api.GET("/expenses/suggest-category", handlers.SuggestExpenseCategory)
 		api.POST("/expenses", handlers.CreateExpense)
+		api.POST("/expenses/parse", handlers.ParseExpenseSMS)
 		api.PUT("/expenses/:id", handlers.UpdateExpense)
 		api.DELETE("/expenses/:id", handlers.DeleteExpense)
+		api.GET("/expenses/:id/attachments", handlers.GetExpenseAttachments)
+		api.POST("/expenses/:id/attachments", handlers.UploadExpenseAttachment)
+
+		// Attachments
+		api.GET("/attachments/:id/url", handlers.GetAttachmentURL)
+		api.DELETE("/attachments/:id", handlers.DeleteAttachment)
 
 		// Reminders
 		api.GET("/reminders", handlers.GetReminders)
 		api.POST("/reminders", handlers.CreateReminder)
 		api.PUT("/reminders/:id", handlers.UpdateReminder)
 		api.DELETE("/reminders/:id", handlers.DeleteReminder)
+
+		// Search
+		api.GET("/search", handlers.Search)
+	}
+
+	// CalDAV routes (HTTP Basic)
+	r.GET("/.well-known/caldav", handlers.CalDAVWellKnown)
+	r.Handle("PROPFIND", "/.well-known/caldav", handlers.CalDAVWellKnown)
+	caldav := r.Group("/caldav")
+	{
+		caldav.OPTIONS("/", handlers.CalDAVOptions)
+		caldav.OPTIONS("/:username/", handlers.CalDAVOptions)
+		caldav.OPTIONS("/:username/plans/", handlers.CalDAVOptions)
+		caldav.OPTIONS("/:username/plans/:resource", handlers.CalDAVOptions)
+
+		dav := caldav.Group("")
+		dav.Use(middleware.BasicAuthMiddleware("Daily Planner", handlers.VerifyBasicCredentials))
+		dav.Handle("PROPFIND", "/", handlers.CalDAVRootPropfind)
+		dav.Handle("PROPFIND", "/:username/", handlers.CalDAVHomePropfind)
+		dav.Handle("PROPFIND", "/:username/plans/", handlers.CalDAVPlansPropfind)
+		dav.Handle("REPORT", "/:username/plans/", handlers.CalDAVPlansReport)
+		dav.Handle("PROPFIND", "/:username/plans/:resource", handlers.CalDAVPlanPropfind)
+		dav.GET("/:username/plans/:resource", handlers.CalDAVGetPlan)
+		dav.HEAD("/:username/plans/:resource", handlers.CalDAVGetPlan)
+		dav.PUT("/:username/plans/:resource", handlers.CalDAVPutPlan)
+		dav.DELETE("/:username/plans/:resource", handlers.CalDAVDeletePlan)
 	}
 
 	log.Printf("Server starting on port %s", cfg.ServerPort)
@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"time"
 )
 
 type Config struct {
@@ -12,6 +14,21 @@ type Config struct {
 	DBName     string
 	JWTSecret  string
 	ServerPort string
+
+	// 附件存储
+	PublicBaseURL   string
+	StorageDriver   string // local 或 s3
+	StorageLocalDir string
+	UploadMaxBytes  int64
+	S3Endpoint      string
+	S3Region        string
+	S3Bucket        string
+	S3AccessKey     string
+	S3SecretKey     string
+	S3PathStyle     bool
+
+	// 后台任务（周期支出等）的执行间隔
+	SchedulerInterval time.Duration
 }
 
 func Load() *Config {
@@ -23,6 +40,19 @@ func Load() *Config {
 		DBName:     getEnv("DB_NAME", "daily_planner"),
 		JWTSecret:  getEnv("JWT_SECRET", "your-secret-key"),
 		ServerPort: getEnv("SERVER_PORT", "8080"),
+
+		PublicBaseURL:   getEnv("PUBLIC_BASE_URL", "http://localhost:8080"),
+		StorageDriver:   getEnv("STORAGE_DRIVER", "local"),
+		StorageLocalDir: getEnv("STORAGE_LOCAL_DIR", "./uploads"),
+		UploadMaxBytes:  getEnvPositiveInt64("UPLOAD_MAX_MB", 10) << 20,
+		S3Endpoint:      getEnv("S3_ENDPOINT", ""),
+		S3Region:        getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:        getEnv("S3_BUCKET", ""),
+		S3AccessKey:     getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:     getEnv("S3_SECRET_KEY", ""),
+		S3PathStyle:     getEnv("S3_PATH_STYLE", "true") == "true",
+
+		SchedulerInterval: time.Duration(getEnvPositiveInt64("SCHEDULER_INTERVAL_SECONDS", 300)) * time.Second,
 	}
 }
 
@@ -36,3 +66,20 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt64(key string, defaultValue int64) int64 {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+// getEnvPositiveInt64 读取正整数配置，未设置、无法解析或不大于 0 时使用默认值
+func getEnvPositiveInt64(key string, defaultValue int64) int64 {
+	if n := getEnvInt64(key, defaultValue); n > 0 {
+		return n
+	}
+	return defaultValue
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedulerIntervalFallsBackToDefault(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":    300 * time.Second,
+		"60":  60 * time.Second,
+		"0":   300 * time.Second,
+		"-5":  300 * time.Second,
+		"abc": 300 * time.Second,
+	}
+	for value, want := range cases {
+		t.Setenv("SCHEDULER_INTERVAL_SECONDS", value)
+		if got := Load().SchedulerInterval; got != want {
+			t.Errorf("SCHEDULER_INTERVAL_SECONDS=%q: expected %v, got %v", value, want, got)
+		}
+	}
+}
+
+func TestUploadMaxBytesFallsBackToDefault(t *testing.T) {
+	cases := map[string]int64{
+		"":   10 << 20,
+		"25": 25 << 20,
+		"0":  10 << 20,
+		"-1": 10 << 20,
+	}
+	for value, want := range cases {
+		t.Setenv("UPLOAD_MAX_MB", value)
+		if got := Load().UploadMaxBytes; got != want {
+			t.Errorf("UPLOAD_MAX_MB=%q: expected %d, got %d", value, want, got)
+		}
+	}
+}
@@ -20,12 +20,46 @@ func Init(cfg *config.Config) error {
 }
 
 func AutoMigrate() error {
-	return DB.AutoMigrate(
+	if err := DB.AutoMigrate(
 		&models.User{},
 		&models.Plan{},
 		&models.Expense{},
 		&models.Reminder{},
-	)
+		&models.AppToken{},
+		&models.PlanTemplate{},
+		&models.PlanTemplateItem{},
+		&models.PlanList{},
+		&models.PlanListMember{},
+		&models.Attachment{},
+		&models.FocusSession{},
+		&models.Habit{},
+		&models.HabitCheckIn{},
+		&models.Goal{},
+		&models.ExpenseCategory{},
+		&models.Budget{},
+		&models.BudgetAlert{},
+		&models.Notification{},
+		&models.RecurringExpense{},
+		&models.Account{},
+		&models.Transfer{},
+		&models.Ledger{},
+		&models.LedgerMember{},
+		&models.ExpenseSplit{},
+		&models.LedgerSettlement{},
+		&models.CategoryRule{},
+		&models.ReimbursementClaim{},
+		&models.InstallmentPlan{},
+		&models.ExchangeRate{},
+		&models.SavingsGoal{},
+		&models.SavingsEntry{},
+		&models.Loan{},
+		&models.LoanRepayment{},
+	); err != nil {
+		return err
+	}
+
+	// 新增 spent_at 之前的支出以创建时间作为消费时间
+	return DB.Exec("UPDATE expenses SET spent_at = created_at WHERE spent_at IS NULL").Error
 }
 
 func GetDB() *gorm.DB {
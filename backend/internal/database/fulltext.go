@@ -0,0 +1,45 @@
+package database
+
+import (
+	"log"
+)
+
+// fullTextIndexes 需要创建的全文索引，使用 ngram 解析器以支持中文
+var fullTextIndexes = []struct {
+	Table   string
+	Name    string
+	Columns string
+}{
+	{Table: "plans", Name: "ft_plans_content", Columns: "content"},
+	{Table: "expenses", Name: "ft_expenses_note_category", Columns: "note, category"},
+	{Table: "reminders", Name: "ft_reminders_content", Columns: "content"},
+}
+
+var fullTextEnabled bool
+
+// EnsureFullTextIndexes 创建全文索引；数据库不支持时返回错误，搜索将退化为 LIKE 匹配
+func EnsureFullTextIndexes() error {
+	for _, idx := range fullTextIndexes {
+		var count int64
+		if err := DB.Raw(
+			"SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?",
+			idx.Table, idx.Name,
+		).Scan(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		if err := DB.Exec("ALTER TABLE " + idx.Table + " ADD FULLTEXT INDEX " + idx.Name + " (" + idx.Columns + ") WITH PARSER ngram").Error; err != nil {
+			return err
+		}
+		log.Printf("Created full-text index %s on %s", idx.Name, idx.Table)
+	}
+	fullTextEnabled = true
+	return nil
+}
+
+// FullTextEnabled 全文索引是否可用
+func FullTextEnabled() bool {
+	return fullTextEnabled
+}
@@ -0,0 +1,459 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"daily-planner-backend/internal/database"
+	"daily-planner-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateAccountRequest struct {
+	Name           string  `json:"name" binding:"required,max=50"`
+	Type           string  `json:"type" binding:"required,oneof=cash debit credit alipay wechat other"`
+	OpeningBalance float64 `json:"opening_balance"`
+	SortOrder      int     `json:"sort_order"`
+}
+
+type UpdateAccountRequest struct {
+	Name           string   `json:"name" binding:"max=50"`
+	Type           string   `json:"type" binding:"omitempty,oneof=cash debit credit alipay wechat other"`
+	OpeningBalance *float64 `json:"opening_balance"`
+	SortOrder      *int     `json:"sort_order"`
+	Archived       *bool    `json:"archived"`
+}
+
+type CreateTransferRequest struct {
+	FromAccountID uint    `json:"from_account_id" binding:"required"`
+	ToAccountID   uint    `json:"to_account_id" binding:"required"`
+	Amount        float64 `json:"amount" binding:"required,gt=0"`
+	Note          string  `json:"note" binding:"max=255"`
+	TransferredAt string  `json:"transferred_at"` // 缺省为当前时间
+}
+
+type UpdateTransferRequest struct {
+	Amount        float64 `json:"amount" binding:"omitempty,gt=0"`
+	Note          *string `json:"note" binding:"omitempty,max=255"`
+	TransferredAt string  `json:"transferred_at"`
+}
+
+// AccountBalance 账户余额及其构成：余额 = 期初 + 收入 - 支出 + 转入 - 转出
+type AccountBalance struct {
+	models.Account
+	Income      float64 `json:"income"`
+	Expense     float64 `json:"expense"`
+	TransferIn  float64 `json:"transfer_in"`
+	TransferOut float64 `json:"transfer_out"`
+	Balance     float64 `json:"balance"`
+}
+
+// accountFlows 各账户的收支和转账合计
+type accountFlows struct {
+	Income      map[uint]float64
+	Expense     map[uint]float64
+	TransferIn  map[uint]float64
+	TransferOut map[uint]float64
+}
+
+// computeAccountBalance 根据期初余额和流水计算账户余额
+func computeAccountBalance(account models.Account, flows accountFlows) AccountBalance {
+	b := AccountBalance{
+		Account:     account,
+		Income:      round2(flows.Income[account.ID]),
+		Expense:     round2(flows.Expense[account.ID]),
+		TransferIn:  round2(flows.TransferIn[account.ID]),
+		TransferOut: round2(flows.TransferOut[account.ID]),
+	}
+	b.Balance = round2(account.OpeningBalance + b.Income - b.Expense + b.TransferIn - b.TransferOut)
+	return b
+}
+
+// validateAccountLink 校验要关联的账户属于 ownerID（被关联记录的所有者），accountID 为 0 表示不关联；失败时已写入响应
+func validateAccountLink(c *gin.Context, ownerID, accountID uint) bool {
+	if accountID == 0 {
+		return true
+	}
+	var account models.Account
+	if err := database.GetDB().First(&account, accountID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "account not found"})
+		return false
+	}
+	if account.UserID != ownerID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return false
+	}
+	return true
+}
+
+func loadOwnedAccount(c *gin.Context) (*models.Account, bool) {
+	var account models.Account
+	if err := database.GetDB().First(&account, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return nil, false
+	}
+
+	if account.UserID != c.GetUint("userID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return nil, false
+	}
+
+	return &account, true
+}
+
+func accountNameTaken(userID uint, name string, excludeID uint) (bool, error) {
+	var count int64
+	err := database.GetDB().Model(&models.Account{}).
+		Where("user_id = ? AND name = ? AND id <> ?", userID, name, excludeID).Count(&count).Error
+	return count > 0, err
+}
+
+func GetAccounts(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	query := database.GetDB().Where("user_id = ?", userID)
+	if c.Query("archived") != "true" {
+		query = query.Where("archived = ?", false)
+	}
+
+	var accounts []models.Account
+	if err := query.Order("sort_order ASC, id ASC").Find(&accounts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, accounts)
+}
+
+// GetAccountBalances 各账户截至 as_of（YYYY-MM-DD，含当天，缺省为全部记录）的余额，收支换算为本位币。
+// unassigned 为未指定账户的收支合计，便于与收支记录核对
+func GetAccountBalances(c *gin.Context) {
+	userID := c.GetUint("userID")
+	db := database.GetDB()
+
+	var end *time.Time
+	if s := c.Query("as_of"); s != "" {
+		d, err := time.ParseInLocation(dayLayout, s, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+			return
+		}
+		d = d.AddDate(0, 0, 1)
+		end = &d
+	}
+
+	var accounts []models.Account
+	if err := db.Where("user_id = ?", userID).Order("sort_order ASC, id ASC").Find(&accounts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	conv, err := loadCurrencyConverter(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	flows := accountFlows{
+		Income:      make(map[uint]float64),
+		Expense:     make(map[uint]float64),
+		TransferIn:  make(map[uint]float64),
+		TransferOut: make(map[uint]float64),
+	}
+	var unassigned struct {
+		Income  float64 `json:"income"`
+		Expense float64 `json:"expense"`
+	}
+
+	// 收支按消费日期的汇率换算为本位币，未指定账户的记录分组键为 0
+	for typ, target := range map[string]map[uint]float64{transactionIncome: flows.Income, transactionExpense: flows.Expense} {
+		q := db.Model(&models.Expense{}).Where("user_id = ? AND type = ?", userID, typ)
+		if end != nil {
+			q = q.Where("spent_at < ?", *end)
+		}
+		groups, err := convertedTotals(q, "COALESCE(account_id, 0)", conv)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		for _, g := range groups {
+			id, _ := strconv.ParseUint(g.Key, 10, 64)
+			target[uint(id)] = g.Total
+		}
+	}
+	unassigned.Income, unassigned.Expense = flows.Income[0], flows.Expense[0]
+
+	for column, target := range map[string]map[uint]float64{"from_account_id": flows.TransferOut, "to_account_id": flows.TransferIn} {
+		var rows []struct {
+			AccountID uint
+			Total     float64
+		}
+		q := db.Model(&models.Transfer{}).Where("user_id = ?", userID)
+		if end != nil {
+			q = q.Where("transferred_at < ?", *end)
+		}
+		if err := q.Select(column + " AS account_id, COALESCE(SUM(amount), 0) AS total").Group(column).Scan(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		for _, r := range rows {
+			target[r.AccountID] = r.Total
+		}
+	}
+
+	balances := make([]AccountBalance, 0, len(accounts))
+	var total float64
+	for _, a := range accounts {
+		b := computeAccountBalance(a, flows)
+		total += b.Balance
+		balances = append(balances, b)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"accounts":      balances,
+		"total":         round2(total),
+		"unassigned":    unassigned,
+		"currency":      conv.base,
+		"missing_rates": conv.missingCurrencies(),
+	})
+}
+
+func CreateAccount(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req CreateAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	taken, err := accountNameTaken(userID, req.Name, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if taken {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "account already exists"})
+		return
+	}
+
+	account := models.Account{
+		UserID:         userID,
+		Name:           req.Name,
+		Type:           req.Type,
+		OpeningBalance: req.OpeningBalance,
+		SortOrder:      req.SortOrder,
+	}
+
+	if err := database.GetDB().Create(&account).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, account)
+}
+
+func UpdateAccount(c *gin.Context) {
+	account, ok := loadOwnedAccount(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Name != "" && req.Name != account.Name {
+		taken, err := accountNameTaken(account.UserID, req.Name, account.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		if taken {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "account already exists"})
+			return
+		}
+		updates["name"] = req.Name
+	}
+	if req.Type != "" {
+		updates["type"] = req.Type
+	}
+	if req.OpeningBalance != nil {
+		updates["opening_balance"] = *req.OpeningBalance
+	}
+	if req.SortOrder != nil {
+		updates["sort_order"] = *req.SortOrder
+	}
+	if req.Archived != nil {
+		updates["archived"] = *req.Archived
+	}
+
+	if err := database.GetDB().Model(account).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, account)
+}
+
+// DeleteAccount 删除没有收支和转账记录的账户，有记录的账户需要归档
+func DeleteAccount(c *gin.Context) {
+	account, ok := loadOwnedAccount(c)
+	if !ok {
+		return
+	}
+
+	var used, transfers int64
+	if err := database.GetDB().Model(&models.Expense{}).Where("account_id = ?", account.ID).Count(&used).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if err := database.GetDB().Model(&models.Transfer{}).
+		Where("from_account_id = ? OR to_account_id = ?", account.ID, account.ID).Count(&transfers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if used+transfers > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "account has transactions, archive it instead"})
+		return
+	}
+
+	if err := database.GetDB().Delete(account).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "account deleted"})
+}
+
+// GetTransfers 转账列表，account_id 过滤与某账户相关的转账
+func GetTransfers(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	query := database.GetDB().Where("user_id = ?", userID)
+	if accountID := c.Query("account_id"); accountID != "" {
+		query = query.Where("from_account_id = ? OR to_account_id = ?", accountID, accountID)
+	}
+
+	var transfers []models.Transfer
+	if err := query.Order("transferred_at DESC, id DESC").Find(&transfers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfers)
+}
+
+func CreateTransfer(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req CreateTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	if req.FromAccountID == req.ToAccountID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot transfer to the same account"})
+		return
+	}
+	if !validateAccountLink(c, userID, req.FromAccountID) || !validateAccountLink(c, userID, req.ToAccountID) {
+		return
+	}
+
+	transferredAt := time.Now()
+	if req.TransferredAt != "" {
+		t, err := parseSpentAt(req.TransferredAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid transferred_at, use RFC3339 or YYYY-MM-DD"})
+			return
+		}
+		transferredAt = t
+	}
+
+	transfer := models.Transfer{
+		UserID:        userID,
+		FromAccountID: req.FromAccountID,
+		ToAccountID:   req.ToAccountID,
+		Amount:        req.Amount,
+		Note:          req.Note,
+		TransferredAt: transferredAt,
+	}
+
+	if err := database.GetDB().Create(&transfer).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, transfer)
+}
+
+func loadOwnedTransfer(c *gin.Context) (*models.Transfer, bool) {
+	var transfer models.Transfer
+	if err := database.GetDB().First(&transfer, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return nil, false
+	}
+
+	if transfer.UserID != c.GetUint("userID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return nil, false
+	}
+
+	return &transfer, true
+}
+
+func UpdateTransfer(c *gin.Context) {
+	transfer, ok := loadOwnedTransfer(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Amount != 0 {
+		updates["amount"] = req.Amount
+	}
+	if req.Note != nil {
+		updates["note"] = *req.Note
+	}
+	if req.TransferredAt != "" {
+		t, err := parseSpentAt(req.TransferredAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid transferred_at, use RFC3339 or YYYY-MM-DD"})
+			return
+		}
+		updates["transferred_at"] = t
+	}
+
+	if err := database.GetDB().Model(transfer).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfer)
+}
+
+func DeleteTransfer(c *gin.Context) {
+	transfer, ok := loadOwnedTransfer(c)
+	if !ok {
+		return
+	}
+
+	if err := database.GetDB().Delete(transfer).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "transfer deleted"})
+}
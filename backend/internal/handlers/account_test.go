@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"testing"
+
+	"daily-planner-backend/internal/models"
+
+	"pgregory.net/rapid"
+)
+
+func TestComputeAccountBalance(t *testing.T) {
+	flows := accountFlows{
+		Income:      map[uint]float64{1: 8000},
+		Expense:     map[uint]float64{1: 1234.5, 2: 600},
+		TransferIn:  map[uint]float64{2: 600},
+		TransferOut: map[uint]float64{1: 600},
+	}
+
+	bank := computeAccountBalance(models.Account{ID: 1, OpeningBalance: 1000}, flows)
+	if bank.Balance != 7165.5 {
+		t.Errorf("Expected bank balance 7165.5, got %v", bank.Balance)
+	}
+
+	// 信用卡消费 600 后全额还款，余额回到期初
+	card := computeAccountBalance(models.Account{ID: 2}, flows)
+	if card.Balance != 0 || card.Expense != 600 || card.TransferIn != 600 {
+		t.Errorf("Unexpected credit card balance: %+v", card)
+	}
+}
+
+// **Feature: accounts, Property 1: Transfers move money between accounts without changing the total**
+func TestTransfersPreserveTotalBalance(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		accounts := []models.Account{
+			{ID: 1, OpeningBalance: float64(rapid.IntRange(-100000, 100000).Draw(t, "opening1"))},
+			{ID: 2, OpeningBalance: float64(rapid.IntRange(-100000, 100000).Draw(t, "opening2"))},
+			{ID: 3, OpeningBalance: float64(rapid.IntRange(-100000, 100000).Draw(t, "opening3"))},
+		}
+		flows := accountFlows{
+			Income:      map[uint]float64{},
+			Expense:     map[uint]float64{},
+			TransferIn:  map[uint]float64{},
+			TransferOut: map[uint]float64{},
+		}
+		total := func() float64 {
+			var sum float64
+			for _, a := range accounts {
+				sum += computeAccountBalance(a, flows).Balance
+			}
+			return round2(sum)
+		}
+
+		before := total()
+		n := rapid.IntRange(0, 20).Draw(t, "transfers")
+		for i := 0; i < n; i++ {
+			from := uint(rapid.IntRange(1, 3).Draw(t, "from"))
+			to := uint(rapid.IntRange(1, 3).Draw(t, "to"))
+			amount := float64(rapid.IntRange(1, 100000).Draw(t, "amount")) / 100
+			flows.TransferOut[from] += amount
+			flows.TransferIn[to] += amount
+		}
+
+		if after := total(); after != before {
+			t.Fatalf("Total balance changed from %v to %v", before, after)
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"daily-planner-backend/internal/database"
+	"daily-planner-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type CreateAppTokenRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// hashAppToken 应用密码只保存 SHA-256 摘要
+func hashAppToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateAppToken() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// GetAppTokens 获取当前用户的应用密码列表
+func GetAppTokens(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var tokens []models.AppToken
+	if err := database.GetDB().Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// CreateAppToken 创建应用密码，明文只在创建时返回一次
+func CreateAppToken(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req CreateAppTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	token, err := generateAppToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	appToken := models.AppToken{
+		UserID:    userID,
+		Name:      req.Name,
+		TokenHash: hashAppToken(token),
+	}
+
+	if err := database.GetDB().Create(&appToken).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token":     token,
+		"app_token": appToken,
+	})
+}
+
+// DeleteAppToken 吊销应用密码
+func DeleteAppToken(c *gin.Context) {
+	userID := c.GetUint("userID")
+	tokenID := c.Param("id")
+
+	var appToken models.AppToken
+	if err := database.GetDB().First(&appToken, tokenID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return
+	}
+
+	if appToken.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return
+	}
+
+	if err := database.GetDB().Delete(&appToken).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "app token deleted"})
+}
+
+// VerifyBasicCredentials 校验 Basic 认证凭据：账号为用户名或手机号，密码为登录密码或应用密码
+func VerifyBasicCredentials(account, password string) (uint, bool) {
+	var user models.User
+	if err := database.GetDB().Where("username = ? OR phone_number = ?", account, account).First(&user).Error; err != nil {
+		return 0, false
+	}
+
+	var appToken models.AppToken
+	if err := database.GetDB().Where("user_id = ? AND token_hash = ?", user.ID, hashAppToken(password)).First(&appToken).Error; err == nil {
+		now := time.Now()
+		database.GetDB().Model(&appToken).Update("last_used_at", &now)
+		return user.ID, true
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return 0, false
+	}
+
+	return user.ID, true
+}
@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"daily-planner-backend/internal/database"
+	"daily-planner-backend/internal/models"
+	"daily-planner-backend/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	attachmentOwnerPlan    = "plans"
+	attachmentOwnerExpense = "expenses"
+
+	attachmentURLDefaultTTL = 15 * time.Minute
+	attachmentURLMaxTTL     = 24 * time.Hour
+)
+
+// allowedAttachmentTypes 允许上传的 MIME 类型及对应扩展名，类型由文件内容嗅探得出
+var allowedAttachmentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+var uploadMaxBytes int64 = 10 << 20
+
+// SetUploadMaxBytes 设置附件大小上限
+func SetUploadMaxBytes(n int64) {
+	uploadMaxBytes = n
+}
+
+// detectAttachmentType 根据文件头嗅探 MIME 类型，不在白名单内时返回错误
+func detectAttachmentType(head []byte) (string, error) {
+	contentType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil {
+		return "", err
+	}
+	if _, ok := allowedAttachmentTypes[contentType]; !ok {
+		return "", errors.New("unsupported file type " + contentType)
+	}
+	return contentType, nil
+}
+
+func newAttachmentKey(userID uint, ownerType, contentType string) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(uint64(userID), 10) + "/" + ownerType + "/" + hex.EncodeToString(buf) + allowedAttachmentTypes[contentType], nil
+}
+
+// saveAttachment 校验并保存上传文件，失败时已写入响应
+func saveAttachment(c *gin.Context, ownerType string, ownerID uint) {
+	userID := c.GetUint("userID")
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, uploadMaxBytes+1<<20)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": "multipart field file is required"})
+		return
+	}
+	if header.Size > uploadMaxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file too large", "max_bytes": uploadMaxBytes})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file"})
+		return
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file"})
+		return
+	}
+	head = head[:n]
+
+	contentType, err := detectAttachmentType(head)
+	if err != nil {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		return
+	}
+
+	key, err := newAttachmentKey(userID, ownerType, contentType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	body := io.MultiReader(bytes.NewReader(head), file)
+	if err := storage.GetStorage().Put(c.Request.Context(), key, body, header.Size, contentType); err != nil {
+		log.Printf("Failed to store attachment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store file"})
+		return
+	}
+
+	attachment := models.Attachment{
+		UserID:      userID,
+		OwnerType:   ownerType,
+		OwnerID:     ownerID,
+		FileName:    filepath.Base(header.Filename),
+		ContentType: contentType,
+		Size:        header.Size,
+		StorageKey:  key,
+	}
+
+	if err := database.GetDB().Create(&attachment).Error; err != nil {
+		storage.GetStorage().Delete(c.Request.Context(), key)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, attachment)
+}
+
+func listAttachments(c *gin.Context, ownerType string, ownerID uint) {
+	var attachments []models.Attachment
+	if err := database.GetDB().Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).Order("created_at ASC").Find(&attachments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, attachments)
+}
+
+// deleteAttachmentRows 在事务中删除父资源的附件记录，返回待删除文件的存储键，事务提交后再删除文件
+func deleteAttachmentRows(tx *gorm.DB, ownerType string, ownerID uint) ([]string, error) {
+	var keys []string
+	if err := tx.Model(&models.Attachment{}).Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).Pluck("storage_key", &keys).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).Delete(&models.Attachment{}).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// removeAttachmentFiles 删除存储中的附件文件，失败只记录日志
+func removeAttachmentFiles(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := storage.GetStorage().Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete attachment file %s: %v", key, err)
+		}
+	}
+}
+
+// attachmentRole 用户对附件父资源的角色
+func attachmentRole(attachment *models.Attachment, userID uint) (string, error) {
+	switch attachment.OwnerType {
+	case attachmentOwnerPlan:
+		var plan models.Plan
+		if err := database.GetDB().First(&plan, attachment.OwnerID).Error; err != nil {
+			return "", err
+		}
+		return planRole(&plan, userID)
+	case attachmentOwnerExpense:
+		var expense models.Expense
+		if err := database.GetDB().First(&expense, attachment.OwnerID).Error; err != nil {
+			return "", err
+		}
+		if expense.UserID == userID {
+			return planRoleOwner, nil
+		}
+	}
+	return "", nil
+}
+
+// loadPlanForAttachment 读取计划并返回当前用户角色，无权访问时已写入响应
+func loadPlanForAttachment(c *gin.Context) (*models.Plan, string, bool) {
+	var plan models.Plan
+	if err := database.GetDB().First(&plan, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return nil, "", false
+	}
+
+	role, err := planRole(&plan, c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return nil, "", false
+	}
+	if role == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return nil, "", false
+	}
+
+	return &plan, role, true
+}
+
+// loadExpenseForAttachment 读取支出并校验归属，失败时已写入响应
+func loadExpenseForAttachment(c *gin.Context) (*models.Expense, bool) {
+	var expense models.Expense
+	if err := database.GetDB().First(&expense, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return nil, false
+	}
+
+	if expense.UserID != c.GetUint("userID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return nil, false
+	}
+
+	return &expense, true
+}
+
+func UploadPlanAttachment(c *gin.Context) {
+	plan, role, ok := loadPlanForAttachment(c)
+	if !ok {
+		return
+	}
+	if !canEditPlan(role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return
+	}
+
+	saveAttachment(c, attachmentOwnerPlan, plan.ID)
+}
+
+func GetPlanAttachments(c *gin.Context) {
+	plan, _, ok := loadPlanForAttachment(c)
+	if !ok {
+		return
+	}
+
+	listAttachments(c, attachmentOwnerPlan, plan.ID)
+}
+
+func UploadExpenseAttachment(c *gin.Context) {
+	expense, ok := loadExpenseForAttachment(c)
+	if !ok {
+		return
+	}
+
+	saveAttachment(c, attachmentOwnerExpense, expense.ID)
+}
+
+func GetExpenseAttachments(c *gin.Context) {
+	expense, ok := loadExpenseForAttachment(c)
+	if !ok {
+		return
+	}
+
+	listAttachments(c, attachmentOwnerExpense, expense.ID)
+}
+
+// GetAttachmentURL 生成限时下载地址，expires_in 为秒数
+func GetAttachmentURL(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var attachment models.Attachment
+	if err := database.GetDB().First(&attachment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return
+	}
+
+	role, err := attachmentRole(&attachment, userID)
+	if err != nil || role == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return
+	}
+
+	ttl := attachmentURLDefaultTTL
+	if s := c.Query("expires_in"); s != "" {
+		seconds, err := strconv.Atoi(s)
+		if err != nil || seconds <= 0 || time.Duration(seconds)*time.Second > attachmentURLMaxTTL {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in must be between 1 and 86400 seconds"})
+			return
+		}
+		ttl = time.Duration(seconds) * time.Second
+	}
+
+	url, err := storage.GetStorage().SignedURL(attachment.StorageKey, ttl)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"url":        url,
+		"expires_at": time.Now().Add(ttl),
+	})
+}
+
+func DeleteAttachment(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var attachment models.Attachment
+	if err := database.GetDB().First(&attachment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return
+	}
+
+	role, err := attachmentRole(&attachment, userID)
+	if err != nil || !canEditPlan(role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return
+	}
+
+	// 先删除记录再删除文件，删除文件失败只会留下无人引用的文件
+	if err := database.GetDB().Delete(&attachment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	removeAttachmentFiles(c.Request.Context(), []string{attachment.StorageKey})
+
+	c.JSON(http.StatusOK, gin.H{"message": "attachment deleted"})
+}
+
+// ServeLocalFile 提供本地存储的签名下载，签名即授权，无需登录
+func ServeLocalFile(c *gin.Context) {
+	local, ok := storage.GetStorage().(*storage.LocalStorage)
+	if !ok {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if !local.Verify(key, c.Query("expires"), c.Query("signature")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "invalid or expired link"})
+		return
+	}
+
+	var attachment models.Attachment
+	if err := database.GetDB().Where("storage_key = ?", key).First(&attachment).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return
+	}
+
+	file, err := local.Open(c.Request.Context(), key)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return
+	}
+	defer file.Close()
+
+	c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": attachment.FileName}))
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, file, nil)
+}
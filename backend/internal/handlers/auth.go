@@ -23,8 +23,9 @@ type LoginRequest struct {
 }
 
 type UpdateProfileRequest struct {
-	Nickname string `json:"nickname"`
-	Avatar   string `json:"avatar"`
+	Nickname     string `json:"nickname"`
+	Avatar       string `json:"avatar"`
+	BaseCurrency string `json:"base_currency" binding:"omitempty,iso4217"` // 统计换算到的本位币
 }
 
 func Register(c *gin.Context) {
@@ -142,7 +143,7 @@ func GetProfile(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
-// UpdateProfile 更新用户信息（昵称、头像）
+// UpdateProfile 更新用户信息（昵称、头像、本位币）
 func UpdateProfile(c *gin.Context) {
 	userID := c.GetUint("userID")
 
@@ -165,6 +166,9 @@ func UpdateProfile(c *gin.Context) {
 	if req.Avatar != "" {
 		updates["avatar"] = req.Avatar
 	}
+	if req.BaseCurrency != "" {
+		updates["base_currency"] = req.BaseCurrency
+	}
 
 	if len(updates) > 0 {
 		if err := database.GetDB().Model(&user).Updates(updates).Error; err != nil {
@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"daily-planner-backend/internal/database"
+	"daily-planner-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const monthLayout = "2006-01"
+
+// budgetThresholds 预算使用达到这些百分比时发送提醒
+var budgetThresholds = []int{80, 100}
+
+type CreateBudgetRequest struct {
+	Category string  `json:"category" binding:"max=50"` // 空表示总预算
+	Amount   float64 `json:"amount" binding:"required,gt=0"`
+}
+
+type UpdateBudgetRequest struct {
+	Amount float64 `json:"amount" binding:"required,gt=0"`
+}
+
+// BudgetStatus 预算在某月的执行情况
+type BudgetStatus struct {
+	models.Budget
+	Spent     float64 `json:"spent"`
+	Remaining float64 `json:"remaining"`
+	Percent   float64 `json:"percent"`
+	Projected float64 `json:"projected"` // 按当前速度推算的月末支出
+	Exceeded  bool    `json:"exceeded"`
+}
+
+// monthRange 返回 t 所在月的起止时间（左闭右开）
+func monthRange(t time.Time) (time.Time, time.Time) {
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(0, 1, 0)
+}
+
+// computeBudgetStatus 计算预算使用情况；当月按已过天数线性推算月末支出，其余月份以实际支出为准
+func computeBudgetStatus(budget models.Budget, spent float64, month, now time.Time) BudgetStatus {
+	s := BudgetStatus{
+		Budget:    budget,
+		Spent:     round2(spent),
+		Remaining: round2(budget.Amount - spent),
+		Projected: round2(spent),
+		Exceeded:  spent > budget.Amount,
+	}
+	if budget.Amount > 0 {
+		s.Percent = round2(spent / budget.Amount * 100)
+	}
+
+	start, end := monthRange(month)
+	if !now.Before(start) && now.Before(end) {
+		days := end.AddDate(0, 0, -1).Day()
+		s.Projected = round2(spent / float64(now.Day()) * float64(days))
+	}
+	return s
+}
+
+// crossedThresholds 返回支出已达到的提醒阈值
+func crossedThresholds(amount, spent float64) []int {
+	var crossed []int
+	for _, t := range budgetThresholds {
+		if amount > 0 && spent >= amount*float64(t)/100 {
+			crossed = append(crossed, t)
+		}
+	}
+	return crossed
+}
+
+// monthlySpending 统计用户某月各分类支出（换算为本位币），"" 为全部支出合计
+func monthlySpending(userID uint, month time.Time) (map[string]float64, error) {
+	conv, err := loadCurrencyConverter(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	spending := make(map[string]float64)
+	for _, query := range spendingQueries(database.GetDB(), userID, month) {
+		rows, err := convertedTotals(query, "category", conv)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			spending[r.Key] = round2(spending[r.Key] + r.Total)
+			spending[""] = round2(spending[""] + r.Total)
+		}
+	}
+	return spending, nil
+}
+
+// spendingQueries 某月计入用户预算的支出：自己的非共享支出按全额，
+// 共享账本中的支出（无论谁付款）只计用户分摊的部分
+func spendingQueries(db *gorm.DB, userID uint, month time.Time) []*gorm.DB {
+	start, end := monthRange(month)
+
+	own := db.Model(&models.Expense{}).
+		Where("user_id = ? AND type = ? AND ledger_id IS NULL AND spent_at >= ? AND spent_at < ?", userID, transactionExpense, start, end)
+
+	splits := db.Model(&models.ExpenseSplit{}).
+		Select("expenses.category, expenses.currency, expenses.spent_at, expense_splits.amount").
+		Joins("JOIN expenses ON expenses.id = expense_splits.expense_id").
+		Where("expense_splits.user_id = ? AND expenses.ledger_id IS NOT NULL AND expenses.type = ? AND expenses.spent_at >= ? AND expenses.spent_at < ?",
+			userID, transactionExpense, start, end)
+	shares := db.Table("(?) AS shares", splits)
+
+	return []*gorm.DB{own, shares}
+}
+
+// checkExpenseBudgets 检查支出影响到的预算：共享支出检查各分摊成员，其他支出检查付款人
+func checkExpenseBudgets(expense *models.Expense) {
+	if expense.LedgerID == nil {
+		checkBudgetThresholds(expense.UserID, expense.Category, expense.SpentAt)
+		return
+	}
+	for _, s := range expense.Splits {
+		checkBudgetThresholds(s.UserID, expense.Category, expense.SpentAt)
+	}
+}
+
+// checkBudgetThresholds 在支出变动后检查相关预算，首次达到阈值时发送通知
+func checkBudgetThresholds(userID uint, category string, spentAt time.Time) {
+	var budgets []models.Budget
+	if err := database.GetDB().Where("user_id = ? AND category IN ?", userID, []string{"", category}).Find(&budgets).Error; err != nil {
+		log.Printf("Failed to load budgets of user %d: %v", userID, err)
+		return
+	}
+	if len(budgets) == 0 {
+		return
+	}
+
+	spending, err := monthlySpending(userID, spentAt)
+	if err != nil {
+		log.Printf("Failed to sum spending of user %d: %v", userID, err)
+		return
+	}
+
+	month := spentAt.Format(monthLayout)
+	for _, b := range budgets {
+		spent := spending[b.Category]
+		for _, threshold := range crossedThresholds(b.Amount, spent) {
+			alert := models.BudgetAlert{BudgetID: b.ID, Month: month, Threshold: threshold}
+			result := database.GetDB().Clauses(clause.OnConflict{DoNothing: true}).Create(&alert)
+			if result.Error != nil {
+				log.Printf("Failed to record budget alert for budget %d: %v", b.ID, result.Error)
+				continue
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+
+			name := b.Category + "预算"
+			if b.Category == "" {
+				name = "总预算"
+			}
+			notify(userID, notificationBudgetThreshold,
+				fmt.Sprintf("%s已使用 %d%%", name, threshold),
+				fmt.Sprintf("%s %s已支出 %.2f / %.2f", month, name, spent, b.Amount),
+				b.ID)
+		}
+	}
+}
+
+func loadOwnedBudget(c *gin.Context) (*models.Budget, bool) {
+	var budget models.Budget
+	if err := database.GetDB().First(&budget, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return nil, false
+	}
+
+	if budget.UserID != c.GetUint("userID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return nil, false
+	}
+
+	return &budget, true
+}
+
+func GetBudgets(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var budgets []models.Budget
+	if err := database.GetDB().Where("user_id = ?", userID).Order("category ASC").Find(&budgets).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, budgets)
+}
+
+// GetBudgetStatus 各预算在指定月份（month=YYYY-MM，缺省本月）的已用、剩余和月末预测
+func GetBudgetStatus(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	now := time.Now()
+	month := now
+	if m := c.Query("month"); m != "" {
+		t, err := time.ParseInLocation(monthLayout, m, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid month format, use YYYY-MM"})
+			return
+		}
+		month = t
+	}
+
+	var budgets []models.Budget
+	if err := database.GetDB().Where("user_id = ?", userID).Order("category ASC").Find(&budgets).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	spending, err := monthlySpending(userID, month)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	statuses := make([]BudgetStatus, 0, len(budgets))
+	for _, b := range budgets {
+		statuses = append(statuses, computeBudgetStatus(b, spending[b.Category], month, now))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"month":   month.Format(monthLayout),
+		"spent":   round2(spending[""]),
+		"budgets": statuses,
+	})
+}
+
+func CreateBudget(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req CreateBudgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	var count int64
+	if err := database.GetDB().Model(&models.Budget{}).Where("user_id = ? AND category = ?", userID, req.Category).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "budget already exists"})
+		return
+	}
+
+	budget := models.Budget{
+		UserID:   userID,
+		Category: req.Category,
+		Amount:   req.Amount,
+	}
+
+	if err := database.GetDB().Create(&budget).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, budget)
+}
+
+// UpdateBudget 修改预算金额，并清除已触发的阈值记录以便按新金额重新提醒
+func UpdateBudget(c *gin.Context) {
+	budget, ok := loadOwnedBudget(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateBudgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(budget).Update("amount", req.Amount).Error; err != nil {
+			return err
+		}
+		return tx.Where("budget_id = ?", budget.ID).Delete(&models.BudgetAlert{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, budget)
+}
+
+func DeleteBudget(c *gin.Context) {
+	budget, ok := loadOwnedBudget(c)
+	if !ok {
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("budget_id = ?", budget.ID).Delete(&models.BudgetAlert{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(budget).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "budget deleted"})
+}
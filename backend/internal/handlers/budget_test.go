@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"daily-planner-backend/internal/models"
+
+	"gorm.io/gorm"
+	"pgregory.net/rapid"
+)
+
+func TestComputeBudgetStatus(t *testing.T) {
+	budget := models.Budget{Category: "餐饮", Amount: 3000}
+	april := time.Date(2024, 4, 1, 0, 0, 0, 0, time.Local)
+
+	// 4 月 10 日花了 1200，按 30 天推算月末 3600
+	mid := computeBudgetStatus(budget, 1200, april, time.Date(2024, 4, 10, 18, 0, 0, 0, time.Local))
+	if mid.Remaining != 1800 || mid.Percent != 40 || mid.Projected != 3600 || mid.Exceeded {
+		t.Errorf("Unexpected mid-month status: %+v", mid)
+	}
+
+	// 已过去的月份不做推算
+	past := computeBudgetStatus(budget, 3300, april, time.Date(2024, 5, 3, 0, 0, 0, 0, time.Local))
+	if past.Projected != 3300 || past.Remaining != -300 || !past.Exceeded {
+		t.Errorf("Unexpected past-month status: %+v", past)
+	}
+}
+
+func TestCrossedThresholds(t *testing.T) {
+	cases := []struct {
+		spent float64
+		want  []int
+	}{
+		{0, nil},
+		{799.99, nil},
+		{800, []int{80}},
+		{1000, []int{80, 100}},
+		{1500, []int{80, 100}},
+	}
+	for _, tc := range cases {
+		if got := crossedThresholds(1000, tc.spent); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("crossedThresholds(1000, %v) = %v, want %v", tc.spent, got, tc.want)
+		}
+	}
+}
+
+// **Feature: budgets, Property 1: Spent plus remaining always equals the budget amount**
+func TestBudgetStatusBalances(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		amount := float64(rapid.IntRange(1, 1000000).Draw(t, "amount")) / 100
+		spent := float64(rapid.IntRange(0, 1000000).Draw(t, "spent")) / 100
+		day := rapid.IntRange(1, 31).Draw(t, "day")
+
+		month := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+		s := computeBudgetStatus(models.Budget{Amount: amount}, spent, month, month.AddDate(0, 0, day-1))
+		if round2(s.Spent+s.Remaining) != amount {
+			t.Fatalf("Spent %v + remaining %v != amount %v", s.Spent, s.Remaining, amount)
+		}
+		if s.Projected < s.Spent {
+			t.Fatalf("Projected %v is less than spent %v", s.Projected, s.Spent)
+		}
+	})
+}
+
+func TestSpendingQueriesCountOwnLedgerShare(t *testing.T) {
+	db, _ := dryRunDB(t)
+
+	var sql []string
+	for _, q := range spendingQueries(db, 7, time.Date(2024, 4, 1, 0, 0, 0, 0, time.Local)) {
+		var rows []map[string]interface{}
+		sql = append(sql, q.Session(&gorm.Session{}).Find(&rows).Statement.SQL.String())
+	}
+	if len(sql) != 2 {
+		t.Fatalf("Expected own and shared spending queries, got %v", sql)
+	}
+
+	// 非共享支出按全额计入付款人
+	if own := sql[0]; !strings.Contains(own, "FROM `expenses`") || !strings.Contains(own, "user_id = ?") || !strings.Contains(own, "ledger_id IS NULL") {
+		t.Errorf("Expected own spending to exclude ledger expenses, got %s", own)
+	}
+
+	// 共享支出只计当前用户的分摊金额，不论付款人是谁
+	shared := sql[1]
+	for _, want := range []string{"expense_splits.amount", "JOIN expenses ON expenses.id = expense_splits.expense_id", "expense_splits.user_id = ?", "expenses.ledger_id IS NOT NULL"} {
+		if !strings.Contains(shared, want) {
+			t.Errorf("Expected shared spending query to contain %q, got %s", want, shared)
+		}
+	}
+	if strings.Contains(shared, "expenses.user_id") {
+		t.Errorf("Expected shared spending not to filter by payer, got %s", shared)
+	}
+}
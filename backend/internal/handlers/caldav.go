@@ -0,0 +1,648 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"daily-planner-backend/internal/database"
+	"daily-planner-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CalDAV 相关命名空间
+const (
+	nsDAV          = "DAV:"
+	nsCalDAV       = "urn:ietf:params:xml:ns:caldav"
+	nsCalendarSrv  = "http://calendarserver.org/ns/"
+	caldavRoot     = "/caldav/"
+	caldavPlansDir = "plans"
+)
+
+var davPrefixes = map[string]string{
+	nsDAV:         "D",
+	nsCalDAV:      "C",
+	nsCalendarSrv: "CS",
+}
+
+// davPropRequest PROPFIND / REPORT 请求体中的 prop 列表
+type davPropRequest struct {
+	Names []struct {
+		XMLName xml.Name
+	} `xml:",any"`
+}
+
+type propfindRequest struct {
+	AllProp  *struct{}       `xml:"DAV: allprop"`
+	PropName *struct{}       `xml:"DAV: propname"`
+	Prop     *davPropRequest `xml:"DAV: prop"`
+}
+
+type calCompFilter struct {
+	Name      string `xml:"name,attr"`
+	TimeRange *struct {
+		Start string `xml:"start,attr"`
+		End   string `xml:"end,attr"`
+	} `xml:"urn:ietf:params:xml:ns:caldav time-range"`
+	Comps []calCompFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+type reportRequest struct {
+	XMLName xml.Name
+	Prop    *davPropRequest `xml:"DAV: prop"`
+	Hrefs   []string        `xml:"DAV: href"`
+	Filter  *struct {
+		Comp calCompFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	} `xml:"urn:ietf:params:xml:ns:caldav filter"`
+}
+
+// davProps 单个资源可返回的属性，值为属性元素内部的 XML
+type davProps map[xml.Name]string
+
+// davMultistatus 构造 207 Multi-Status 响应
+type davMultistatus struct {
+	b strings.Builder
+}
+
+func newDavMultistatus() *davMultistatus {
+	m := &davMultistatus{}
+	m.b.WriteString(`<?xml version="1.0" encoding="utf-8"?>`)
+	m.b.WriteString(`<D:multistatus xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav" xmlns:CS="http://calendarserver.org/ns/">`)
+	return m
+}
+
+// addResponse 按请求的属性名输出 propstat，请求为空时返回全部属性
+func (m *davMultistatus) addResponse(href string, props davProps, requested []xml.Name) {
+	m.b.WriteString("<D:response><D:href>")
+	xml.EscapeText(&m.b, []byte(href))
+	m.b.WriteString("</D:href>")
+
+	var found, missing []xml.Name
+	if len(requested) == 0 {
+		for name := range props {
+			found = append(found, name)
+		}
+	} else {
+		for _, name := range requested {
+			if _, ok := props[name]; ok {
+				found = append(found, name)
+			} else {
+				missing = append(missing, name)
+			}
+		}
+	}
+
+	if len(found) > 0 {
+		m.b.WriteString("<D:propstat><D:prop>")
+		for _, name := range found {
+			m.b.WriteString(davElement(name, props[name]))
+		}
+		m.b.WriteString("</D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat>")
+	}
+	if len(missing) > 0 {
+		m.b.WriteString("<D:propstat><D:prop>")
+		for _, name := range missing {
+			m.b.WriteString(davElement(name, ""))
+		}
+		m.b.WriteString("</D:prop><D:status>HTTP/1.1 404 Not Found</D:status></D:propstat>")
+	}
+	m.b.WriteString("</D:response>")
+}
+
+func (m *davMultistatus) addStatus(href string, status int) {
+	m.b.WriteString("<D:response><D:href>")
+	xml.EscapeText(&m.b, []byte(href))
+	m.b.WriteString("</D:href><D:status>")
+	m.b.WriteString(fmt.Sprintf("HTTP/1.1 %d %s", status, http.StatusText(status)))
+	m.b.WriteString("</D:status></D:response>")
+}
+
+func (m *davMultistatus) write(c *gin.Context) {
+	m.b.WriteString("</D:multistatus>")
+	c.Data(http.StatusMultiStatus, "application/xml; charset=utf-8", []byte(m.b.String()))
+}
+
+func davElement(name xml.Name, inner string) string {
+	prefix, ok := davPrefixes[name.Space]
+	if !ok {
+		if inner == "" {
+			return fmt.Sprintf(`<x:%s xmlns:x="%s"/>`, name.Local, xmlEscape(name.Space))
+		}
+		return fmt.Sprintf(`<x:%s xmlns:x="%s">%s</x:%s>`, name.Local, xmlEscape(name.Space), inner, name.Local)
+	}
+	if inner == "" {
+		return fmt.Sprintf("<%s:%s/>", prefix, name.Local)
+	}
+	return fmt.Sprintf("<%s:%s>%s</%s:%s>", prefix, name.Local, inner, prefix, name.Local)
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func davHref(href string) string {
+	return "<D:href>" + xmlEscape(href) + "</D:href>"
+}
+
+func requestedPropNames(p *davPropRequest) []xml.Name {
+	if p == nil {
+		return nil
+	}
+	names := make([]xml.Name, 0, len(p.Names))
+	for _, n := range p.Names {
+		names = append(names, n.XMLName)
+	}
+	return names
+}
+
+func caldavHomeHref(user *models.User) string {
+	return caldavRoot + url.PathEscape(user.Username) + "/"
+}
+
+func caldavPlansHref(user *models.User) string {
+	return caldavHomeHref(user) + caldavPlansDir + "/"
+}
+
+func planResourceName(plan *models.Plan) string {
+	if plan.CalendarHref != "" {
+		return plan.CalendarHref
+	}
+	return fmt.Sprintf("plan-%d.ics", plan.ID)
+}
+
+// planETag 基于更新时间生成强 ETag，计划需为数据库中重新读取的值
+func planETag(plan *models.Plan) string {
+	return fmt.Sprintf(`"%d-%d"`, plan.ID, plan.UpdatedAt.UnixMilli())
+}
+
+// caldavUser 获取认证用户，并校验路径中的用户名与之一致
+func caldavUser(c *gin.Context) (*models.User, bool) {
+	var user models.User
+	if err := database.GetDB().First(&user, c.GetUint("userID")).Error; err != nil {
+		c.Status(http.StatusUnauthorized)
+		return nil, false
+	}
+	if username := c.Param("username"); username != "" && username != user.Username {
+		c.Status(http.StatusForbidden)
+		return nil, false
+	}
+	return &user, true
+}
+
+func parsePropfind(c *gin.Context) ([]xml.Name, bool) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return nil, false
+	}
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return nil, true
+	}
+	var req propfindRequest
+	if err := xml.Unmarshal(body, &req); err != nil {
+		c.Status(http.StatusBadRequest)
+		return nil, false
+	}
+	if req.Prop != nil {
+		return requestedPropNames(req.Prop), true
+	}
+	return nil, true
+}
+
+func principalProps(user *models.User) davProps {
+	home := caldavHomeHref(user)
+	return davProps{
+		{Space: nsDAV, Local: "resourcetype"}:                 "<D:collection/>",
+		{Space: nsDAV, Local: "displayname"}:                  xmlEscape(user.Username),
+		{Space: nsDAV, Local: "current-user-principal"}:       davHref(home),
+		{Space: nsDAV, Local: "principal-URL"}:                davHref(home),
+		{Space: nsCalDAV, Local: "calendar-home-set"}:         davHref(home),
+		{Space: nsCalDAV, Local: "calendar-user-address-set"}: davHref("mailto:" + user.Username + "@daily-planner"),
+	}
+}
+
+func plansCollectionProps(user *models.User) (davProps, error) {
+	var stat struct {
+		Count     int64
+		UpdatedAt *time.Time
+	}
+	if err := database.GetDB().Model(&models.Plan{}).
+		Select("COUNT(*) AS count, MAX(updated_at) AS updated_at").
+		Where("user_id = ?", user.ID).Scan(&stat).Error; err != nil {
+		return nil, err
+	}
+	var ctag string
+	if stat.UpdatedAt != nil {
+		ctag = fmt.Sprintf("%d-%d", stat.Count, stat.UpdatedAt.UnixMilli())
+	} else {
+		ctag = "0"
+	}
+
+	return davProps{
+		{Space: nsDAV, Local: "resourcetype"}:                        "<D:collection/><C:calendar/>",
+		{Space: nsDAV, Local: "displayname"}:                         "计划",
+		{Space: nsDAV, Local: "current-user-principal"}:              davHref(caldavHomeHref(user)),
+		{Space: nsDAV, Local: "owner"}:                               davHref(caldavHomeHref(user)),
+		{Space: nsDAV, Local: "current-user-privilege-set"}:          "<D:privilege><D:read/></D:privilege><D:privilege><D:write/></D:privilege>",
+		{Space: nsDAV, Local: "supported-report-set"}:                "<D:supported-report><D:report><C:calendar-query/></D:report></D:supported-report><D:supported-report><D:report><C:calendar-multiget/></D:report></D:supported-report>",
+		{Space: nsCalDAV, Local: "supported-calendar-component-set"}: `<C:comp name="VEVENT"/>`,
+		{Space: nsCalendarSrv, Local: "getctag"}:                     xmlEscape(ctag),
+	}, nil
+}
+
+func planResourceProps(plan *models.Plan, withData bool) davProps {
+	props := davProps{
+		{Space: nsDAV, Local: "resourcetype"}:   "",
+		{Space: nsDAV, Local: "getetag"}:        xmlEscape(planETag(plan)),
+		{Space: nsDAV, Local: "getcontenttype"}: "text/calendar; charset=utf-8; component=vevent",
+	}
+	if withData {
+		props[xml.Name{Space: nsCalDAV, Local: "calendar-data"}] = xmlEscape(encodePlanICal(plan))
+	}
+	return props
+}
+
+// findPlanByResource 按资源名查找计划，兼容未经 CalDAV 创建的 plan-<id>.ics
+func findPlanByResource(userID uint, resource string) (*models.Plan, error) {
+	var plan models.Plan
+	err := database.GetDB().Where("user_id = ? AND calendar_href = ?", userID, resource).First(&plan).Error
+	if err == nil {
+		return &plan, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var id uint
+	if _, scanErr := fmt.Sscanf(resource, "plan-%d.ics", &id); scanErr != nil || planResourceName(&models.Plan{ID: id}) != resource {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err := database.GetDB().Where("id = ? AND user_id = ? AND calendar_href = ''", id, userID).First(&plan).Error; err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// CalDAVWellKnown 处理 /.well-known/caldav 服务发现
+func CalDAVWellKnown(c *gin.Context) {
+	c.Redirect(http.StatusMovedPermanently, caldavRoot)
+}
+
+// CalDAVOptions 声明支持的 DAV 能力
+func CalDAVOptions(c *gin.Context) {
+	c.Header("DAV", "1, 3, calendar-access")
+	c.Header("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT")
+	c.Status(http.StatusOK)
+}
+
+// CalDAVRootPropfind 根路径，客户端据此发现当前用户的 principal
+func CalDAVRootPropfind(c *gin.Context) {
+	user, ok := caldavUser(c)
+	if !ok {
+		return
+	}
+	requested, ok := parsePropfind(c)
+	if !ok {
+		return
+	}
+
+	ms := newDavMultistatus()
+	props := principalProps(user)
+	props[xml.Name{Space: nsDAV, Local: "displayname"}] = "Daily Planner"
+	ms.addResponse(caldavRoot, props, requested)
+	ms.write(c)
+}
+
+// CalDAVHomePropfind 用户 principal 兼日历主目录
+func CalDAVHomePropfind(c *gin.Context) {
+	user, ok := caldavUser(c)
+	if !ok {
+		return
+	}
+	requested, ok := parsePropfind(c)
+	if !ok {
+		return
+	}
+
+	ms := newDavMultistatus()
+	ms.addResponse(caldavHomeHref(user), principalProps(user), requested)
+
+	if c.GetHeader("Depth") != "0" {
+		props, err := plansCollectionProps(user)
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		ms.addResponse(caldavPlansHref(user), props, requested)
+	}
+	ms.write(c)
+}
+
+// CalDAVPlansPropfind 计划日历集合，Depth: 1 时列出全部计划资源
+func CalDAVPlansPropfind(c *gin.Context) {
+	user, ok := caldavUser(c)
+	if !ok {
+		return
+	}
+	requested, ok := parsePropfind(c)
+	if !ok {
+		return
+	}
+
+	props, err := plansCollectionProps(user)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	ms := newDavMultistatus()
+	ms.addResponse(caldavPlansHref(user), props, requested)
+
+	if c.GetHeader("Depth") != "0" {
+		var plans []models.Plan
+		if err := database.GetDB().Where("user_id = ?", user.ID).Order("execution_date ASC").Find(&plans).Error; err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		for i := range plans {
+			ms.addResponse(caldavPlansHref(user)+url.PathEscape(planResourceName(&plans[i])), planResourceProps(&plans[i], false), requested)
+		}
+	}
+	ms.write(c)
+}
+
+// CalDAVPlanPropfind 单个计划资源的属性
+func CalDAVPlanPropfind(c *gin.Context) {
+	user, ok := caldavUser(c)
+	if !ok {
+		return
+	}
+	requested, ok := parsePropfind(c)
+	if !ok {
+		return
+	}
+
+	plan, err := findPlanByResource(user.ID, c.Param("resource"))
+	if err != nil {
+		c.Status(davErrorStatus(err))
+		return
+	}
+
+	ms := newDavMultistatus()
+	ms.addResponse(caldavPlansHref(user)+url.PathEscape(planResourceName(plan)), planResourceProps(plan, false), requested)
+	ms.write(c)
+}
+
+// CalDAVPlansReport 处理 calendar-query 和 calendar-multiget
+func CalDAVPlansReport(c *gin.Context) {
+	user, ok := caldavUser(c)
+	if !ok {
+		return
+	}
+
+	var req reportRequest
+	if err := xml.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	requested := requestedPropNames(req.Prop)
+	withData := len(requested) == 0
+	for _, name := range requested {
+		if name.Space == nsCalDAV && name.Local == "calendar-data" {
+			withData = true
+		}
+	}
+
+	base := caldavPlansHref(user)
+	ms := newDavMultistatus()
+
+	switch {
+	case req.XMLName.Space == nsCalDAV && req.XMLName.Local == "calendar-multiget":
+		for _, href := range req.Hrefs {
+			href = strings.TrimSpace(href)
+			if u, err := url.Parse(href); err == nil {
+				href = u.Path
+			}
+			if path.Dir(href)+"/" != base {
+				ms.addStatus(href, http.StatusNotFound)
+				continue
+			}
+			plan, err := findPlanByResource(user.ID, path.Base(href))
+			if err != nil {
+				ms.addStatus(href, davErrorStatus(err))
+				continue
+			}
+			ms.addResponse(base+url.PathEscape(planResourceName(plan)), planResourceProps(plan, withData), requested)
+		}
+
+	case req.XMLName.Space == nsCalDAV && req.XMLName.Local == "calendar-query":
+		query := database.GetDB().Where("user_id = ?", user.ID)
+		if req.Filter != nil {
+			comp, ok := calendarQueryComponent(&req.Filter.Comp)
+			if !ok {
+				ms.write(c)
+				return
+			}
+			if comp != nil && comp.TimeRange != nil {
+				if start, err := time.Parse("20060102T150405Z", comp.TimeRange.Start); err == nil {
+					// 全天事件在结束日前一天仍与区间相交
+					query = query.Where("execution_date >= ?", start.AddDate(0, 0, -1))
+				}
+				if end, err := time.Parse("20060102T150405Z", comp.TimeRange.End); err == nil {
+					query = query.Where("execution_date < ?", end)
+				}
+			}
+		}
+
+		var plans []models.Plan
+		if err := query.Order("execution_date ASC").Find(&plans).Error; err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		for i := range plans {
+			ms.addResponse(base+url.PathEscape(planResourceName(&plans[i])), planResourceProps(&plans[i], withData), requested)
+		}
+
+	default:
+		c.Status(http.StatusForbidden)
+		return
+	}
+
+	ms.write(c)
+}
+
+// calendarQueryComponent 取 VCALENDAR 下的组件过滤器；只有 VEVENT 能匹配到计划
+func calendarQueryComponent(root *calCompFilter) (*calCompFilter, bool) {
+	if !strings.EqualFold(root.Name, "VCALENDAR") {
+		return nil, false
+	}
+	if len(root.Comps) == 0 {
+		return nil, true
+	}
+	for i := range root.Comps {
+		if strings.EqualFold(root.Comps[i].Name, "VEVENT") {
+			return &root.Comps[i], true
+		}
+	}
+	return nil, false
+}
+
+func davErrorStatus(err error) int {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// CalDAVGetPlan 以 iCalendar 格式返回单个计划
+func CalDAVGetPlan(c *gin.Context) {
+	user, ok := caldavUser(c)
+	if !ok {
+		return
+	}
+
+	plan, err := findPlanByResource(user.ID, c.Param("resource"))
+	if err != nil {
+		c.Status(davErrorStatus(err))
+		return
+	}
+
+	etag := planETag(plan)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Header("ETag", etag)
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(encodePlanICal(plan)))
+}
+
+// CalDAVPutPlan 创建或更新计划，支持 If-Match / If-None-Match 条件请求
+func CalDAVPutPlan(c *gin.Context) {
+	user, ok := caldavUser(c)
+	if !ok {
+		return
+	}
+	resource := c.Param("resource")
+
+	existing, err := findPlanByResource(user.ID, resource)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch == "*" && existing != nil {
+		c.Status(http.StatusPreconditionFailed)
+		return
+	}
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" {
+		if existing == nil || (ifMatch != "*" && ifMatch != planETag(existing)) {
+			c.Status(http.StatusPreconditionFailed)
+			return
+		}
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	parsed, err := parsePlanICal(string(body))
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	db := database.GetDB()
+	status := http.StatusNoContent
+	var plan models.Plan
+
+	if existing == nil {
+		// 同一 UID 只能对应一个资源
+		var count int64
+		if err := db.Model(&models.Plan{}).Where("user_id = ? AND calendar_uid = ?", user.ID, parsed.UID).Count(&count).Error; err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		if count > 0 {
+			c.String(http.StatusConflict, "UID already exists")
+			return
+		}
+
+		plan = models.Plan{
+			UserID:        user.ID,
+			Content:       parsed.Summary,
+			ExecutionDate: parsed.ExecutionDate,
+			Status:        "pending",
+			CalendarUID:   parsed.UID,
+			CalendarHref:  resource,
+		}
+		if parsed.Status != "" {
+			plan.Status = parsed.Status
+		}
+		if err := db.Create(&plan).Error; err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		status = http.StatusCreated
+	} else {
+		plan = *existing
+		updates := map[string]interface{}{
+			"content":        parsed.Summary,
+			"execution_date": parsed.ExecutionDate,
+			"calendar_uid":   parsed.UID,
+		}
+		if parsed.Status != "" {
+			updates["status"] = parsed.Status
+		}
+		if err := db.Model(&plan).Updates(updates).Error; err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// 重新读取以获得数据库精度下的 updated_at
+	if err := db.First(&plan, plan.ID).Error; err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("ETag", planETag(&plan))
+	c.Status(status)
+}
+
+// CalDAVDeletePlan 删除计划资源及其附件
+func CalDAVDeletePlan(c *gin.Context) {
+	user, ok := caldavUser(c)
+	if !ok {
+		return
+	}
+
+	plan, err := findPlanByResource(user.ID, c.Param("resource"))
+	if err != nil {
+		c.Status(davErrorStatus(err))
+		return
+	}
+
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" && ifMatch != "*" && ifMatch != planETag(plan) {
+		c.Status(http.StatusPreconditionFailed)
+		return
+	}
+
+	if err := deletePlan(c.Request.Context(), plan); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
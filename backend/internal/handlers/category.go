@@ -0,0 +1,458 @@
+package handlers
+
+import (
+	"net/http"
+
+	"daily-planner-backend/internal/database"
+	"daily-planner-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 收支类型
+const (
+	transactionExpense = "expense"
+	transactionIncome  = "income"
+)
+
+// fallbackCategory 未分类支出使用的分类
+const fallbackCategory = "其他"
+
+// fallbackIncomeCategory 未分类收入使用的分类，分类名在收支之间不能重复
+const fallbackIncomeCategory = "其他收入"
+
+// defaultCategories 新用户的默认分类，支出分类与客户端原先内置的分类一致
+var defaultCategories = []models.ExpenseCategory{
+	{Name: "餐饮", Type: transactionExpense, Icon: "cart", Color: "#F59E0B", SortOrder: 0},
+	{Name: "交通", Type: transactionExpense, Icon: "car", Color: "#3B82F6", SortOrder: 1},
+	{Name: "购物", Type: transactionExpense, Icon: "bag", Color: "#EC4899", SortOrder: 2},
+	{Name: "娱乐", Type: transactionExpense, Icon: "game_controller", Color: "#8B5CF6", SortOrder: 3},
+	{Name: fallbackCategory, Type: transactionExpense, Icon: "ellipsis", Color: "#64748B", SortOrder: 99},
+	{Name: "工资", Type: transactionIncome, Icon: "briefcase", Color: "#10B981", SortOrder: 0},
+	{Name: "奖金", Type: transactionIncome, Icon: "gift", Color: "#F97316", SortOrder: 1},
+	{Name: "理财", Type: transactionIncome, Icon: "chart", Color: "#0EA5E9", SortOrder: 2},
+	{Name: fallbackIncomeCategory, Type: transactionIncome, Icon: "ellipsis", Color: "#64748B", SortOrder: 99},
+}
+
+type CreateCategoryRequest struct {
+	Name      string `json:"name" binding:"required,max=50"`
+	Type      string `json:"type" binding:"omitempty,oneof=expense income"` // 缺省为 expense
+	Icon      string `json:"icon" binding:"max=50"`
+	Color     string `json:"color" binding:"omitempty,hexcolor,len=7"`
+	SortOrder int    `json:"sort_order"`
+	ParentID  *uint  `json:"parent_id"`
+}
+
+type UpdateCategoryRequest struct {
+	Name      string  `json:"name" binding:"max=50"`
+	Icon      *string `json:"icon" binding:"omitempty,max=50"`
+	Color     *string `json:"color" binding:"omitempty,hexcolor,len=7"`
+	SortOrder *int    `json:"sort_order"`
+	ParentID  *uint   `json:"parent_id"` // 0 表示移到顶层
+	Archived  *bool   `json:"archived"`
+}
+
+type MergeCategoryRequest struct {
+	TargetID uint `json:"target_id" binding:"required"`
+}
+
+// ensureDefaultCategories 用户首次使用某类分类时写入该类的默认分类，支出分类还会补齐已有支出中用到的分类
+func ensureDefaultCategories(userID uint) error {
+	db := database.GetDB()
+
+	var existing []models.ExpenseCategory
+	if err := db.Select("name", "type").Where("user_id = ?", userID).Find(&existing).Error; err != nil {
+		return err
+	}
+	hasType := make(map[string]bool)
+	seen := make(map[string]bool)
+	for _, c := range existing {
+		hasType[c.Type] = true
+		seen[c.Name] = true
+	}
+
+	var categories []models.ExpenseCategory
+	for _, c := range defaultCategories {
+		if !hasType[c.Type] && !seen[c.Name] {
+			c.UserID = userID
+			categories = append(categories, c)
+			seen[c.Name] = true
+		}
+	}
+
+	for _, typ := range []string{transactionExpense, transactionIncome} {
+		if hasType[typ] {
+			continue
+		}
+		var used []string
+		if err := db.Model(&models.Expense{}).Where("user_id = ? AND type = ?", userID, typ).Distinct().Pluck("category", &used).Error; err != nil {
+			return err
+		}
+		for i, name := range used {
+			if name != "" && !seen[name] {
+				categories = append(categories, models.ExpenseCategory{UserID: userID, Name: name, Type: typ, SortOrder: 10 + i})
+				seen[name] = true
+			}
+		}
+	}
+
+	if len(categories) == 0 {
+		return nil
+	}
+	return db.Create(&categories).Error
+}
+
+func loadOwnedCategory(c *gin.Context, id interface{}) (*models.ExpenseCategory, bool) {
+	var category models.ExpenseCategory
+	if err := database.GetDB().First(&category, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return nil, false
+	}
+
+	if category.UserID != c.GetUint("userID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return nil, false
+	}
+
+	return &category, true
+}
+
+// validateCategoryParent 父分类须属于同一用户、收支类型相同且本身为顶层分类（只支持两级）
+func validateCategoryParent(c *gin.Context, category *models.ExpenseCategory, typ string, parentID uint) bool {
+	parent, ok := loadOwnedCategory(c, parentID)
+	if !ok {
+		return false
+	}
+	if parent.Type != typ {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "parent must be of the same type"})
+		return false
+	}
+	if parent.ParentID != nil || (category != nil && parent.ID == category.ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "parent must be a top-level category other than itself"})
+		return false
+	}
+	if category != nil {
+		var children int64
+		database.GetDB().Model(&models.ExpenseCategory{}).Where("parent_id = ?", category.ID).Count(&children)
+		if children > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a category with subcategories cannot have a parent"})
+			return false
+		}
+	}
+	return true
+}
+
+func categoryNameTaken(userID uint, name string, excludeID uint) (bool, error) {
+	var count int64
+	err := database.GetDB().Model(&models.ExpenseCategory{}).
+		Where("user_id = ? AND name = ? AND id <> ?", userID, name, excludeID).Count(&count).Error
+	return count > 0, err
+}
+
+func GetCategories(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	if err := ensureDefaultCategories(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	query := database.GetDB().Where("user_id = ?", userID)
+	if typ := c.Query("type"); typ != "" {
+		query = query.Where("type = ?", typ)
+	}
+	if c.Query("archived") != "true" {
+		query = query.Where("archived = ?", false)
+	}
+
+	var categories []models.ExpenseCategory
+	if err := query.Order("sort_order ASC, id ASC").Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, categories)
+}
+
+func CreateCategory(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req CreateCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	if err := ensureDefaultCategories(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	taken, err := categoryNameTaken(userID, req.Name, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if taken {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "category already exists"})
+		return
+	}
+
+	if req.Type == "" {
+		req.Type = transactionExpense
+	}
+	if req.ParentID != nil && !validateCategoryParent(c, nil, req.Type, *req.ParentID) {
+		return
+	}
+
+	category := models.ExpenseCategory{
+		UserID:    userID,
+		Name:      req.Name,
+		Type:      req.Type,
+		Icon:      req.Icon,
+		Color:     req.Color,
+		SortOrder: req.SortOrder,
+		ParentID:  req.ParentID,
+	}
+
+	if err := database.GetDB().Create(&category).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, category)
+}
+
+// UpdateCategory 更新分类，改名时同步迁移使用旧名称的收支记录、预算、周期记账、自动分类规则和分期计划
+func UpdateCategory(c *gin.Context) {
+	userID := c.GetUint("userID")
+	category, ok := loadOwnedCategory(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req UpdateCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	renamed := req.Name != "" && req.Name != category.Name
+	if renamed {
+		taken, err := categoryNameTaken(userID, req.Name, category.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		if taken {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "category already exists, use merge instead"})
+			return
+		}
+		updates["name"] = req.Name
+	}
+	if req.Icon != nil {
+		updates["icon"] = *req.Icon
+	}
+	if req.Color != nil {
+		updates["color"] = *req.Color
+	}
+	if req.SortOrder != nil {
+		updates["sort_order"] = *req.SortOrder
+	}
+	if req.ParentID != nil {
+		if *req.ParentID == 0 {
+			updates["parent_id"] = nil
+		} else {
+			if !validateCategoryParent(c, category, category.Type, *req.ParentID) {
+				return
+			}
+			updates["parent_id"] = *req.ParentID
+		}
+	}
+	if req.Archived != nil {
+		updates["archived"] = *req.Archived
+	}
+
+	oldName := category.Name
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(category).Updates(updates).Error; err != nil {
+			return err
+		}
+		if renamed {
+			_, err := renameCategoryReferences(tx, userID, oldName, req.Name)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// categoryReferenceModels 除收支记录和预算外按名称引用分类的表，分类改名或合并时一并更新
+var categoryReferenceModels = []interface{}{
+	&models.RecurringExpense{},
+	&models.CategoryRule{},
+	&models.InstallmentPlan{},
+}
+
+// budgetMove 分类改名或合并时预算的处理方式
+type budgetMove struct {
+	RenameID uint    // 直接改名的预算
+	MergeID  uint    // 金额并入的目标预算
+	Amount   float64 // 并入后目标预算的金额
+	DeleteID uint    // 并入后删除的原预算
+}
+
+// planBudgetMove 新名称已有预算时把原预算金额并入后删除原预算，避免 idx_user_budget 冲突；否则直接改名
+func planBudgetMove(source, target *models.Budget) budgetMove {
+	switch {
+	case source == nil:
+		return budgetMove{}
+	case target == nil:
+		return budgetMove{RenameID: source.ID}
+	}
+	return budgetMove{
+		MergeID:  target.ID,
+		Amount:   fromCents(toCents(source.Amount) + toCents(target.Amount)),
+		DeleteID: source.ID,
+	}
+}
+
+// renameCategoryReferences 将收支记录、预算、周期记账、自动分类规则和分期计划中的分类名改为新名称，返回迁移的收支记录数
+func renameCategoryReferences(tx *gorm.DB, userID uint, oldName, newName string) (int64, error) {
+	result := tx.Model(&models.Expense{}).Where("user_id = ? AND category = ?", userID, oldName).Update("category", newName)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	for _, model := range categoryReferenceModels {
+		if err := tx.Model(model).Where("user_id = ? AND category = ?", userID, oldName).Update("category", newName).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	var budgets []models.Budget
+	if err := tx.Where("user_id = ? AND category IN ?", userID, []string{oldName, newName}).Find(&budgets).Error; err != nil {
+		return 0, err
+	}
+	var source, target *models.Budget
+	for i := range budgets {
+		if budgets[i].Category == oldName {
+			source = &budgets[i]
+		} else {
+			target = &budgets[i]
+		}
+	}
+
+	move := planBudgetMove(source, target)
+	if move.RenameID != 0 {
+		if err := tx.Model(&models.Budget{}).Where("id = ?", move.RenameID).Update("category", newName).Error; err != nil {
+			return 0, err
+		}
+	}
+	if move.MergeID != 0 {
+		if err := tx.Model(&models.Budget{}).Where("id = ?", move.MergeID).Update("amount", move.Amount).Error; err != nil {
+			return 0, err
+		}
+		if err := tx.Where("budget_id = ?", move.DeleteID).Delete(&models.BudgetAlert{}).Error; err != nil {
+			return 0, err
+		}
+		if err := tx.Delete(&models.Budget{}, move.DeleteID).Error; err != nil {
+			return 0, err
+		}
+	}
+	return result.RowsAffected, nil
+}
+
+// MergeCategory 将分类合并到目标分类：迁移收支记录、预算、周期记账、自动分类规则、分期计划和子分类后删除原分类
+func MergeCategory(c *gin.Context) {
+	userID := c.GetUint("userID")
+	source, ok := loadOwnedCategory(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req MergeCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	target, ok := loadOwnedCategory(c, req.TargetID)
+	if !ok {
+		return
+	}
+	if target.Type != source.Type {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot merge income and expense categories"})
+		return
+	}
+	if target.ID == source.ID || (target.ParentID != nil && *target.ParentID == source.ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot merge a category into itself or its subcategory"})
+		return
+	}
+
+	var moved int64
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var err error
+		if moved, err = renameCategoryReferences(tx, userID, source.Name, target.Name); err != nil {
+			return err
+		}
+
+		// 子分类挂到目标分类下；目标本身是子分类时提升为顶层
+		newParent := interface{}(target.ID)
+		if target.ParentID != nil {
+			newParent = nil
+		}
+		if err := tx.Model(&models.ExpenseCategory{}).Where("parent_id = ?", source.ID).Update("parent_id", newParent).Error; err != nil {
+			return err
+		}
+		return tx.Delete(source).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "category merged",
+		"target":         target,
+		"moved_expenses": moved,
+	})
+}
+
+// DeleteCategory 删除未被收支记录使用的分类，已使用的分类需要归档或合并
+func DeleteCategory(c *gin.Context) {
+	userID := c.GetUint("userID")
+	category, ok := loadOwnedCategory(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var used int64
+	if err := database.GetDB().Model(&models.Expense{}).Where("user_id = ? AND category = ?", userID, category.Name).Count(&used).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if used > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "category is used by expenses, archive or merge it instead", "expenses": used})
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.ExpenseCategory{}).Where("parent_id = ?", category.ID).Update("parent_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(category).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "category deleted"})
+}
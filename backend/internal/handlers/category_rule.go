@@ -0,0 +1,418 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"daily-planner-backend/internal/database"
+	"daily-planner-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateCategoryRuleRequest struct {
+	Type      string   `json:"type" binding:"omitempty,oneof=expense income"` // 缺省为支出
+	Keyword   string   `json:"keyword" binding:"max=100"`
+	Pattern   string   `json:"pattern" binding:"max=255"`
+	MinAmount *float64 `json:"min_amount"`
+	MaxAmount *float64 `json:"max_amount"`
+	Category  string   `json:"category" binding:"required,max=50"`
+	Tags      string   `json:"tags"`
+	Priority  int      `json:"priority"`
+}
+
+type UpdateCategoryRuleRequest struct {
+	Keyword   *string  `json:"keyword" binding:"omitempty,max=100"`
+	Pattern   *string  `json:"pattern" binding:"omitempty,max=255"`
+	MinAmount *float64 `json:"min_amount"` // 负数表示取消下限
+	MaxAmount *float64 `json:"max_amount"` // 负数表示取消上限
+	Category  string   `json:"category" binding:"max=50"`
+	Tags      *string  `json:"tags"`
+	Priority  *int     `json:"priority"`
+	Enabled   *bool    `json:"enabled"`
+}
+
+// CategorySuggestion 分类建议，source 为 rule（用户规则）、history（历史记录）或 keyword（内置关键字）
+type CategorySuggestion struct {
+	Category   string  `json:"category"`
+	Tags       string  `json:"tags,omitempty"`
+	Source     string  `json:"source"`
+	Confidence float64 `json:"confidence"`
+	RuleID     uint    `json:"rule_id,omitempty"`
+}
+
+// categoryUsage 历史记录中某个备注/商户与分类组合的使用次数
+type categoryUsage struct {
+	Note     string
+	Merchant string
+	Category string
+	Count    int
+}
+
+// historySuggestionLimit 最多返回的历史分类建议数
+const historySuggestionLimit = 3
+
+// compiledRule 预编译正则后的规则
+type compiledRule struct {
+	models.CategoryRule
+	keywords []string
+	pattern  *regexp.Regexp
+}
+
+func compileRules(rules []models.CategoryRule) []compiledRule {
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, r := range rules {
+		cr := compiledRule{CategoryRule: r}
+		for _, kw := range splitTags(normalizeTags(r.Keyword)) {
+			cr.keywords = append(cr.keywords, strings.ToLower(kw))
+		}
+		if r.Pattern != "" {
+			re, err := regexp.Compile("(?i)" + r.Pattern)
+			if err != nil {
+				continue // 保存时已校验，正则失效的规则直接跳过
+			}
+			cr.pattern = re
+		}
+		compiled = append(compiled, cr)
+	}
+	return compiled
+}
+
+// matches 判断规则是否命中，没有任何条件的规则不命中
+func (r compiledRule) matches(note, merchant string, amount float64) bool {
+	if len(r.keywords) == 0 && r.pattern == nil && r.MinAmount == nil && r.MaxAmount == nil {
+		return false
+	}
+	text := note + " " + merchant
+	if len(r.keywords) > 0 && !containsAny(strings.ToLower(text), r.keywords) {
+		return false
+	}
+	if r.pattern != nil && !r.pattern.MatchString(note) && !r.pattern.MatchString(merchant) {
+		return false
+	}
+	if r.MinAmount != nil && amount < *r.MinAmount {
+		return false
+	}
+	if r.MaxAmount != nil && amount > *r.MaxAmount {
+		return false
+	}
+	return true
+}
+
+// matchRule 按优先级返回第一条命中的规则，rules 需已按优先级排序
+func matchRule(rules []compiledRule, typ, note, merchant string, amount float64) (*models.CategoryRule, bool) {
+	for i := range rules {
+		if rules[i].Type == typ && rules[i].matches(note, merchant, amount) {
+			return &rules[i].CategoryRule, true
+		}
+	}
+	return nil, false
+}
+
+// loadCategoryRules 读取用户启用的规则，按优先级从高到低、创建顺序排列
+func loadCategoryRules(userID uint) ([]compiledRule, error) {
+	var rules []models.CategoryRule
+	err := database.GetDB().Where("user_id = ? AND enabled = ?", userID, true).
+		Order("priority DESC, id ASC").Find(&rules).Error
+	if err != nil {
+		return nil, err
+	}
+	return compileRules(rules), nil
+}
+
+// applyCategoryRule 用命中的规则设置分类并合并标签，返回是否命中
+func applyCategoryRule(rules []compiledRule, expense *models.Expense) bool {
+	rule, ok := matchRule(rules, expense.Type, expense.Note, expense.Merchant, expense.Amount)
+	if !ok {
+		return false
+	}
+	expense.Category = rule.Category
+	expense.Tags = normalizeTags(expense.Tags + "," + rule.Tags)
+	return true
+}
+
+// normalizeSuggestText 规范化用于比较的备注和商户
+func normalizeSuggestText(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), ""))
+}
+
+// suggestFromHistory 根据历史记录推荐分类：备注或商户完全相同的记录权重为 3，
+// 互相包含（至少 2 个字）的记录权重为 1，按次数加权后计算各分类的占比
+func suggestFromHistory(history []categoryUsage, note, merchant string) []CategorySuggestion {
+	note, merchant = normalizeSuggestText(note), normalizeSuggestText(merchant)
+	if note == "" && merchant == "" {
+		return nil
+	}
+
+	related := func(input, past string) int {
+		switch {
+		case input == "" || past == "":
+			return 0
+		case input == past:
+			return 3
+		case len([]rune(input)) >= 2 && len([]rune(past)) >= 2 && (strings.Contains(input, past) || strings.Contains(past, input)):
+			return 1
+		}
+		return 0
+	}
+
+	scores := make(map[string]int)
+	var total int
+	for _, h := range history {
+		weight := related(note, normalizeSuggestText(h.Note))
+		if w := related(merchant, normalizeSuggestText(h.Merchant)); w > weight {
+			weight = w
+		}
+		if weight == 0 {
+			continue
+		}
+		scores[h.Category] += weight * h.Count
+		total += weight * h.Count
+	}
+
+	suggestions := make([]CategorySuggestion, 0, len(scores))
+	for category, score := range scores {
+		suggestions = append(suggestions, CategorySuggestion{
+			Category:   category,
+			Source:     "history",
+			Confidence: round2(float64(score) / float64(total)),
+		})
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Confidence != suggestions[j].Confidence {
+			return suggestions[i].Confidence > suggestions[j].Confidence
+		}
+		return suggestions[i].Category < suggestions[j].Category
+	})
+	if len(suggestions) > historySuggestionLimit {
+		suggestions = suggestions[:historySuggestionLimit]
+	}
+	return suggestions
+}
+
+// validateRuleConditions 校验正则和金额区间，且至少要有一个条件；失败时已写入响应
+func validateRuleConditions(c *gin.Context, rule *models.CategoryRule) bool {
+	if rule.Pattern != "" {
+		if _, err := regexp.Compile("(?i)" + rule.Pattern); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid pattern", "details": err.Error()})
+			return false
+		}
+	}
+	if rule.MinAmount != nil && rule.MaxAmount != nil && *rule.MinAmount > *rule.MaxAmount {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "min_amount must not exceed max_amount"})
+		return false
+	}
+	if normalizeTags(rule.Keyword) == "" && rule.Pattern == "" && rule.MinAmount == nil && rule.MaxAmount == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "rule needs at least one of keyword, pattern, min_amount, max_amount"})
+		return false
+	}
+	return true
+}
+
+func loadOwnedCategoryRule(c *gin.Context) (*models.CategoryRule, bool) {
+	var rule models.CategoryRule
+	if err := database.GetDB().First(&rule, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return nil, false
+	}
+
+	if rule.UserID != c.GetUint("userID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return nil, false
+	}
+
+	return &rule, true
+}
+
+func GetCategoryRules(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var rules []models.CategoryRule
+	if err := database.GetDB().Where("user_id = ?", userID).Order("priority DESC, id ASC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+func CreateCategoryRule(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req CreateCategoryRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	if req.Type == "" {
+		req.Type = transactionExpense
+	}
+
+	rule := models.CategoryRule{
+		UserID:    userID,
+		Type:      req.Type,
+		Keyword:   normalizeTags(req.Keyword),
+		Pattern:   req.Pattern,
+		MinAmount: req.MinAmount,
+		MaxAmount: req.MaxAmount,
+		Category:  req.Category,
+		Tags:      normalizeTags(req.Tags),
+		Priority:  req.Priority,
+		Enabled:   true,
+	}
+	if !validateRuleConditions(c, &rule) {
+		return
+	}
+
+	if err := database.GetDB().Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+func UpdateCategoryRule(c *gin.Context) {
+	rule, ok := loadOwnedCategoryRule(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateCategoryRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Keyword != nil {
+		rule.Keyword = normalizeTags(*req.Keyword)
+		updates["keyword"] = rule.Keyword
+	}
+	if req.Pattern != nil {
+		rule.Pattern = *req.Pattern
+		updates["pattern"] = rule.Pattern
+	}
+	if req.MinAmount != nil {
+		if *req.MinAmount < 0 {
+			rule.MinAmount = nil
+		} else {
+			rule.MinAmount = req.MinAmount
+		}
+		updates["min_amount"] = rule.MinAmount
+	}
+	if req.MaxAmount != nil {
+		if *req.MaxAmount < 0 {
+			rule.MaxAmount = nil
+		} else {
+			rule.MaxAmount = req.MaxAmount
+		}
+		updates["max_amount"] = rule.MaxAmount
+	}
+	if !validateRuleConditions(c, rule) {
+		return
+	}
+	if req.Category != "" {
+		updates["category"] = req.Category
+	}
+	if req.Tags != nil {
+		updates["tags"] = normalizeTags(*req.Tags)
+	}
+	if req.Priority != nil {
+		updates["priority"] = *req.Priority
+	}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+
+	if err := database.GetDB().Model(rule).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func DeleteCategoryRule(c *gin.Context) {
+	rule, ok := loadOwnedCategoryRule(c)
+	if !ok {
+		return
+	}
+
+	if err := database.GetDB().Delete(rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "category rule deleted"})
+}
+
+// SuggestExpenseCategory 根据 note、merchant、amount 推荐分类：先匹配用户规则，
+// 再参考历史记录中相同或相近的备注/商户，都没有时使用内置关键字
+func SuggestExpenseCategory(c *gin.Context) {
+	userID := c.GetUint("userID")
+	note, merchant := c.Query("note"), c.Query("merchant")
+
+	typ := c.DefaultQuery("type", transactionExpense)
+	if typ != transactionExpense && typ != transactionIncome {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be one of expense, income"})
+		return
+	}
+
+	var amount float64
+	if s := c.Query("amount"); s != "" {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid amount"})
+			return
+		}
+		amount = v
+	}
+
+	suggestions := make([]CategorySuggestion, 0, historySuggestionLimit+1)
+
+	rules, err := loadCategoryRules(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if rule, ok := matchRule(rules, typ, note, merchant, amount); ok {
+		suggestions = append(suggestions, CategorySuggestion{
+			Category:   rule.Category,
+			Tags:       rule.Tags,
+			Source:     "rule",
+			Confidence: 1,
+			RuleID:     rule.ID,
+		})
+	}
+
+	// 只取最常用的组合，避免历史记录过多时全部加载
+	var history []categoryUsage
+	err = database.GetDB().Model(&models.Expense{}).
+		Select("note, merchant, category, COUNT(*) AS count").
+		Where("user_id = ? AND type = ?", userID, typ).
+		Group("note, merchant, category").Order("count DESC").Limit(2000).
+		Scan(&history).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	for _, s := range suggestFromHistory(history, note, merchant) {
+		if len(suggestions) > 0 && suggestions[0].Category == s.Category {
+			continue
+		}
+		suggestions = append(suggestions, s)
+	}
+
+	if len(suggestions) == 0 && typ == transactionExpense {
+		if category := guessCategory("", merchant, note); category != fallbackCategory {
+			suggestions = append(suggestions, CategorySuggestion{Category: category, Source: "keyword", Confidence: 0.5})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"suggestions": suggestions})
+}
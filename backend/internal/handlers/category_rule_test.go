@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"testing"
+
+	"daily-planner-backend/internal/models"
+)
+
+func floatPtr(v float64) *float64 { return &v }
+
+func TestMatchRule(t *testing.T) {
+	rules := compileRules([]models.CategoryRule{
+		{ID: 1, Type: transactionExpense, Keyword: "美团,饿了么", MaxAmount: floatPtr(100), Category: "餐饮", Tags: "外卖"},
+		{ID: 2, Type: transactionExpense, Pattern: `^didi|滴滴`, Category: "交通"},
+		{ID: 3, Type: transactionExpense, MinAmount: floatPtr(1000), Category: "大额"},
+		{ID: 4, Type: transactionIncome, Keyword: "工资", Category: "工资"},
+		{ID: 5, Type: transactionExpense, Pattern: `([`, Category: "无效"},
+		{ID: 6, Type: transactionExpense, Category: "无条件"},
+	})
+
+	cases := []struct {
+		typ, note, merchant string
+		amount              float64
+		want                uint
+	}{
+		{transactionExpense, "美团外卖", "", 35, 1},
+		{transactionExpense, "", "饿了么", 100, 1},
+		{transactionExpense, "美团酒店", "", 1200, 3}, // 超过关键字规则的金额上限
+		{transactionExpense, "DiDi Express", "", 20, 2},
+		{transactionExpense, "打车", "滴滴出行", 20, 2},
+		{transactionIncome, "十月工资", "", 8000, 4},
+		{transactionExpense, "十月工资", "", 80, 0}, // 收入规则不用于支出
+		{transactionExpense, "超市", "", 50, 0},
+	}
+	for _, tc := range cases {
+		rule, ok := matchRule(rules, tc.typ, tc.note, tc.merchant, tc.amount)
+		if tc.want == 0 {
+			if ok {
+				t.Errorf("matchRule(%q, %q, %v) matched rule %d, want none", tc.note, tc.merchant, tc.amount, rule.ID)
+			}
+			continue
+		}
+		if !ok || rule.ID != tc.want {
+			t.Errorf("matchRule(%q, %q, %v) = %v, want rule %d", tc.note, tc.merchant, tc.amount, rule, tc.want)
+		}
+	}
+}
+
+func TestApplyCategoryRuleMergesTags(t *testing.T) {
+	rules := compileRules([]models.CategoryRule{{ID: 1, Type: transactionExpense, Keyword: "星巴克", Category: "餐饮", Tags: "咖啡,工作日"}})
+
+	expense := models.Expense{Type: transactionExpense, Note: "星巴克拿铁", Amount: 32, Tags: "工作日,报销"}
+	if !applyCategoryRule(rules, &expense) {
+		t.Fatal("Expected rule to match")
+	}
+	if expense.Category != "餐饮" || expense.Tags != "工作日,报销,咖啡" {
+		t.Errorf("Unexpected result: category=%s tags=%s", expense.Category, expense.Tags)
+	}
+}
+
+func TestSuggestFromHistory(t *testing.T) {
+	history := []categoryUsage{
+		{Note: "美团外卖", Category: "餐饮", Count: 10},
+		{Note: "美团外卖", Category: "购物", Count: 1},
+		{Note: "美团 单车", Category: "交通", Count: 4},
+		{Merchant: "滴滴出行", Category: "交通", Count: 6},
+		{Note: "超市", Category: "购物", Count: 3},
+	}
+
+	got := suggestFromHistory(history, "美团外卖", "")
+	if len(got) == 0 || got[0].Category != "餐饮" || got[0].Source != "history" {
+		t.Fatalf("Unexpected suggestions: %+v", got)
+	}
+	// 完全相同权重 3：餐饮 30、购物 3；“美团单车”与“美团外卖”互不包含
+	if got[0].Confidence != 0.91 || len(got) != 2 {
+		t.Errorf("Unexpected confidence: %+v", got)
+	}
+
+	if got := suggestFromHistory(history, "", "滴滴"); len(got) != 1 || got[0].Category != "交通" {
+		t.Errorf("Expected merchant substring to suggest 交通, got %+v", got)
+	}
+	if got := suggestFromHistory(history, "美", ""); len(got) != 0 {
+		t.Errorf("Expected single rune input not to match substrings, got %+v", got)
+	}
+	if got := suggestFromHistory(history, "", ""); got != nil {
+		t.Errorf("Expected no suggestions for empty input, got %+v", got)
+	}
+}
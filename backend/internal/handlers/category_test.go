@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"daily-planner-backend/internal/models"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"pgregory.net/rapid"
+)
+
+// sqlRecorder 记录 DryRun 模式下生成的 SQL
+type sqlRecorder struct {
+	logger.Interface
+	statements []string
+}
+
+func (r *sqlRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	r.statements = append(r.statements, sql)
+}
+
+// dryRunDB 不连接数据库、只生成 SQL 的 gorm 实例
+func dryRunDB(t *testing.T) (*gorm.DB, *sqlRecorder) {
+	rec := &sqlRecorder{Interface: logger.Discard}
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "user:pass@tcp(127.0.0.1:1)/test", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true, Logger: rec})
+	if err != nil {
+		t.Fatalf("Failed to open dry-run database: %v", err)
+	}
+	return db, rec
+}
+
+func TestRenameCategoryReferencesUpdatesAllTables(t *testing.T) {
+	db, rec := dryRunDB(t)
+
+	if _, err := renameCategoryReferences(db, 1, "吃饭", "餐饮"); err != nil {
+		t.Fatalf("Unexpected error: %v %v", err, rec.statements)
+	}
+
+	for _, table := range []string{"expenses", "recurring_expenses", "category_rules", "installment_plans"} {
+		found := false
+		for _, sql := range rec.statements {
+			if strings.HasPrefix(sql, "UPDATE `"+table+"` SET `category`='餐饮'") && strings.Contains(sql, "category = '吃饭'") {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected %s to be renamed, got %v", table, rec.statements)
+		}
+	}
+
+	found := false
+	for _, sql := range rec.statements {
+		if strings.HasPrefix(sql, "SELECT * FROM `budgets`") && strings.Contains(sql, "('吃饭','餐饮')") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected budgets of both names to be loaded, got %v", rec.statements)
+	}
+}
+
+func TestPlanBudgetMove(t *testing.T) {
+	if move := planBudgetMove(nil, &models.Budget{ID: 2}); move != (budgetMove{}) {
+		t.Errorf("Expected no change without a source budget: %+v", move)
+	}
+
+	if move := planBudgetMove(&models.Budget{ID: 1, Amount: 300}, nil); move != (budgetMove{RenameID: 1}) {
+		t.Errorf("Expected source budget to be renamed: %+v", move)
+	}
+
+	// 新名称已有预算时合并金额，避免唯一索引冲突
+	move := planBudgetMove(&models.Budget{ID: 1, Amount: 300.1}, &models.Budget{ID: 2, Amount: 500.2})
+	if move.RenameID != 0 || move.MergeID != 2 || move.DeleteID != 1 || move.Amount != 800.3 {
+		t.Errorf("Expected source budget to be merged into target: %+v", move)
+	}
+}
+
+// **Feature: categories, Property 1: Merging budgets keeps the combined amount and never renames into a collision**
+func TestPlanBudgetMoveConservesAmount(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		source := models.Budget{ID: 1, Amount: float64(rapid.IntRange(1, 10000000).Draw(t, "sourceCents")) / 100}
+		target := models.Budget{ID: 2, Amount: float64(rapid.IntRange(1, 10000000).Draw(t, "targetCents")) / 100}
+
+		move := planBudgetMove(&source, &target)
+		if move.RenameID != 0 {
+			t.Fatalf("Source budget must not be renamed onto an existing budget: %+v", move)
+		}
+		if toCents(move.Amount) != toCents(source.Amount)+toCents(target.Amount) {
+			t.Fatalf("Expected merged amount %.2f + %.2f, got %+v", source.Amount, target.Amount, move)
+		}
+	})
+}
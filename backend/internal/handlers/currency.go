@@ -0,0 +1,486 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"daily-planner-backend/internal/database"
+	"daily-planner-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// rateReferenceCurrency 汇率表的基准币种，汇率均为 1 单位外币折合的人民币
+const rateReferenceCurrency = "CNY"
+
+// domesticCurrency 支付宝、微信账单和银行短信中的金额均为人民币
+const domesticCurrency = "CNY"
+
+var errInvalidRateCSV = errors.New("csv must have date, currency and rate columns")
+
+// errMissingRates 需要保存的换算结果缺少汇率
+var errMissingRates = errors.New("missing exchange rates")
+
+// rateCSVColumns 汇率 CSV 的列名（不区分大小写）
+var rateCSVColumns = map[string][]string{
+	"date":     {"date", "日期"},
+	"currency": {"currency", "币种", "货币"},
+	"rate":     {"rate", "汇率"},
+}
+
+type ExchangeRateInput struct {
+	Currency string  `json:"currency" binding:"required,iso4217"`
+	Date     string  `json:"date" binding:"required"`
+	Rate     float64 `json:"rate" binding:"required,gt=0"`
+}
+
+type UpsertExchangeRatesRequest struct {
+	Rates []ExchangeRateInput `json:"rates" binding:"required,min=1,max=1000,dive"`
+}
+
+// ratePoint 某个币种自某日起生效的汇率
+type ratePoint struct {
+	Date time.Time
+	Rate float64
+}
+
+// currencyConverter 按消费日期的汇率把金额换算为用户的本位币，
+// missing 记录缺少汇率、未能换算的币种
+type currencyConverter struct {
+	base    string
+	rates   map[string][]ratePoint // 按日期升序
+	missing map[string]bool
+}
+
+func newCurrencyConverter(base string, rates []models.ExchangeRate) *currencyConverter {
+	conv := &currencyConverter{base: base, rates: make(map[string][]ratePoint), missing: make(map[string]bool)}
+	for _, r := range rates {
+		conv.rates[r.Currency] = append(conv.rates[r.Currency], ratePoint{Date: r.Date, Rate: r.Rate})
+	}
+	for _, points := range conv.rates {
+		sort.Slice(points, func(i, j int) bool { return points[i].Date.Before(points[j].Date) })
+	}
+	return conv
+}
+
+// rate 返回 day 当天生效的汇率：取不晚于当天的最近一条，早于全部记录时取最早一条
+func (conv *currencyConverter) rate(currency string, day time.Time) (float64, bool) {
+	if currency == "" || currency == rateReferenceCurrency {
+		return 1, true
+	}
+	points := conv.rates[currency]
+	if len(points) == 0 {
+		return 0, false
+	}
+	i := sort.Search(len(points), func(i int) bool { return points[i].Date.After(day) })
+	if i == 0 {
+		return points[0].Rate, true
+	}
+	return points[i-1].Rate, true
+}
+
+// convert 按 day 的汇率把金额换算为本位币，缺少汇率时返回 false 并记录该币种
+func (conv *currencyConverter) convert(amount float64, currency string, day time.Time) (float64, bool) {
+	if currency == conv.base || (currency == "" && conv.base == rateReferenceCurrency) {
+		return amount, true
+	}
+	from, ok := conv.rate(currency, day)
+	if !ok {
+		conv.missing[currency] = true
+		return 0, false
+	}
+	to, ok := conv.rate(conv.base, day)
+	if !ok {
+		conv.missing[conv.base] = true
+		return 0, false
+	}
+	return amount * from / to, true
+}
+
+// missingCurrencies 缺少汇率的币种，按字母排序
+func (conv *currencyConverter) missingCurrencies() []string {
+	list := make([]string, 0, len(conv.missing))
+	for c := range conv.missing {
+		list = append(list, c)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// userBaseCurrency 用户的本位币
+func userBaseCurrency(userID uint) (string, error) {
+	var user models.User
+	if err := database.GetDB().Select("id, base_currency").First(&user, userID).Error; err != nil {
+		return "", err
+	}
+	if user.BaseCurrency == "" {
+		return rateReferenceCurrency, nil
+	}
+	return user.BaseCurrency, nil
+}
+
+// loadCurrencyConverter 读取用户的本位币及其记账用到的各币种汇率，extra 为额外需要的币种
+func loadCurrencyConverter(userID uint, extra ...string) (*currencyConverter, error) {
+	base, err := userBaseCurrency(userID)
+	if err != nil {
+		return nil, err
+	}
+	return loadCurrencyConverterTo(userID, base, extra...)
+}
+
+// loadCurrencyConverterTo 与 loadCurrencyConverter 相同，但换算为指定的 base 而非用户的本位币
+func loadCurrencyConverterTo(userID uint, base string, extra ...string) (*currencyConverter, error) {
+	var currencies []string
+	if err := database.GetDB().Model(&models.Expense{}).Where("user_id = ?", userID).
+		Distinct("currency").Pluck("currency", &currencies).Error; err != nil {
+		return nil, err
+	}
+	needed := []string{base}
+	for _, c := range append(currencies, extra...) {
+		if c != "" && c != base && c != rateReferenceCurrency && !containsString(needed, c) {
+			needed = append(needed, c)
+		}
+	}
+
+	var rates []models.ExchangeRate
+	if len(needed) > 1 || base != rateReferenceCurrency {
+		if err := database.GetDB().Where("currency IN ?", needed).Find(&rates).Error; err != nil {
+			return nil, err
+		}
+	}
+	return newCurrencyConverter(base, rates), nil
+}
+
+// convertedGroup 换算为本位币后的分组合计
+type convertedGroup struct {
+	Key   string
+	Total float64
+	Count int64
+}
+
+// convertedTotals 按 keyExpr 分组汇总并换算为本位币：SQL 先按分组键、币种和消费日期汇总，再逐日换算。
+// 结果按金额从高到低排列
+func convertedTotals(query *gorm.DB, keyExpr string, conv *currencyConverter) ([]convertedGroup, error) {
+	var rows []struct {
+		Key      string
+		Currency string
+		Day      string
+		Total    float64
+		Count    int64
+	}
+	err := query.Session(&gorm.Session{}).
+		Select(keyExpr + " AS `key`, currency, DATE_FORMAT(spent_at, '%Y-%m-%d') AS day, " +
+			"COALESCE(SUM(amount), 0) AS total, COUNT(*) AS count").
+		Group("`key`, currency, day").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[string]*convertedGroup)
+	var groups []*convertedGroup
+	for _, r := range rows {
+		g, ok := byKey[r.Key]
+		if !ok {
+			g = &convertedGroup{Key: r.Key}
+			byKey[r.Key] = g
+			groups = append(groups, g)
+		}
+		g.Count += r.Count
+		day, _ := time.ParseInLocation(dayLayout, r.Day, time.Local)
+		if v, ok := conv.convert(r.Total, r.Currency, day); ok {
+			g.Total += v
+		}
+	}
+
+	result := make([]convertedGroup, 0, len(groups))
+	for _, g := range groups {
+		g.Total = round2(g.Total)
+		result = append(result, *g)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Total != result[j].Total {
+			return result[i].Total > result[j].Total
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result, nil
+}
+
+// convertedSum 查询范围内全部记录换算为本位币后的合计和笔数
+func convertedSum(query *gorm.DB, conv *currencyConverter) (float64, int64, error) {
+	groups, err := convertedTotals(query, "''", conv)
+	if err != nil || len(groups) == 0 {
+		return 0, 0, err
+	}
+	return groups[0].Total, groups[0].Count, nil
+}
+
+// parseExchangeRateCSV 解析汇率 CSV（表头包含日期、币种、汇率列），
+// 返回有效的汇率和各错误行的说明
+func parseExchangeRateCSV(r io.Reader) ([]models.ExchangeRate, []string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, errInvalidRateCSV
+	}
+	index := make(map[string]int)
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		for field, aliases := range rateCSVColumns {
+			for _, a := range aliases {
+				if h == a {
+					index[field] = i
+				}
+			}
+		}
+	}
+	if len(index) != len(rateCSVColumns) {
+		return nil, nil, errInvalidRateCSV
+	}
+
+	var rates []models.ExchangeRate
+	var problems []string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		cell := func(field string) string {
+			if i := index[field]; i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		if strings.Join(record, "") == "" {
+			continue
+		}
+
+		rate, err := parseExchangeRate(cell("currency"), cell("date"), cell("rate"))
+		if err != nil {
+			line, _ := reader.FieldPos(0)
+			problems = append(problems, fmt.Sprintf("line %d: %v", line, err))
+			continue
+		}
+		rate.Source = "csv"
+		rates = append(rates, rate)
+	}
+	return rates, problems, nil
+}
+
+// parseExchangeRate 校验并构造一条汇率记录
+func parseExchangeRate(currency, date, rate string) (models.ExchangeRate, error) {
+	currency = strings.ToUpper(currency)
+	if len(currency) != 3 || strings.Trim(currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return models.ExchangeRate{}, fmt.Errorf("invalid currency %q", currency)
+	}
+	if currency == rateReferenceCurrency {
+		return models.ExchangeRate{}, fmt.Errorf("%s is the reference currency", currency)
+	}
+	d, err := time.ParseInLocation(dayLayout, date, time.Local)
+	if err != nil {
+		return models.ExchangeRate{}, fmt.Errorf("invalid date %q", date)
+	}
+	v, err := strconv.ParseFloat(rate, 64)
+	if err != nil || v <= 0 {
+		return models.ExchangeRate{}, fmt.Errorf("invalid rate %q", rate)
+	}
+	return models.ExchangeRate{Currency: currency, Date: d, Rate: v}, nil
+}
+
+// saveExchangeRates 写入汇率，同一币种同一天已有汇率时覆盖
+func saveExchangeRates(rates []models.ExchangeRate) error {
+	if len(rates) == 0 {
+		return nil
+	}
+	return database.GetDB().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "currency"}, {Name: "date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "source", "updated_at"}),
+	}).CreateInBatches(&rates, 500).Error
+}
+
+// AdminGetExchangeRates 汇率列表，可按 currency、from、to 过滤
+func AdminGetExchangeRates(c *gin.Context) {
+	query := database.GetDB().Model(&models.ExchangeRate{})
+	if currency := c.Query("currency"); currency != "" {
+		query = query.Where("currency = ?", strings.ToUpper(currency))
+	}
+	if s := c.Query("from"); s != "" {
+		d, err := time.ParseInLocation(dayLayout, s, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+			return
+		}
+		query = query.Where("date >= ?", d)
+	}
+	if s := c.Query("to"); s != "" {
+		d, err := time.ParseInLocation(dayLayout, s, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+			return
+		}
+		query = query.Where("date <= ?", d)
+	}
+
+	var rates []models.ExchangeRate
+	if err := query.Order("currency ASC, date DESC").Limit(5000).Find(&rates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rates)
+}
+
+// AdminUpsertExchangeRates 批量设置汇率
+func AdminUpsertExchangeRates(c *gin.Context) {
+	var req UpsertExchangeRatesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	rates := make([]models.ExchangeRate, 0, len(req.Rates))
+	for _, in := range req.Rates {
+		rate, err := parseExchangeRate(in.Currency, in.Date, strconv.FormatFloat(in.Rate, 'f', -1, 64))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+			return
+		}
+		rate.Source = "manual"
+		rates = append(rates, rate)
+	}
+
+	if err := saveExchangeRates(rates); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"saved": len(rates)})
+}
+
+// AdminImportExchangeRates 从 CSV 文件（multipart 字段 file）导入汇率，格式错误的行跳过并返回原因
+func AdminImportExchangeRates(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, uploadMaxBytes+1<<20)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": "multipart field file is required"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file"})
+		return
+	}
+	defer file.Close()
+
+	rates, problems, err := parseExchangeRateCSV(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := saveExchangeRates(rates); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"imported": len(rates), "errors": problems})
+}
+
+func AdminDeleteExchangeRate(c *gin.Context) {
+	result := database.GetDB().Delete(&models.ExchangeRate{}, c.Param("id"))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "exchange rate deleted"})
+}
+
+// GetExchangeRates 指定日期（缺省今天）各币种折合本位币的汇率
+func GetExchangeRates(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	day := today()
+	if s := c.Query("date"); s != "" {
+		d, err := time.ParseInLocation(dayLayout, s, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+			return
+		}
+		day = d
+	}
+
+	base, err := userBaseCurrency(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	// 每个币种只取当天生效的一条
+	latest := database.GetDB().Model(&models.ExchangeRate{}).Select("currency, MAX(date)").Where("date <= ?", day).Group("currency")
+	var rates []models.ExchangeRate
+	if err := database.GetDB().Where("(currency, date) IN (?)", latest).Find(&rates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	conv := newCurrencyConverter(base, rates)
+	result := make(map[string]float64)
+	for _, currency := range append([]string{rateReferenceCurrency}, keysOfRates(conv.rates)...) {
+		if currency == base {
+			continue
+		}
+		if v, ok := conv.convert(1, currency, day); ok {
+			result[currency] = round6(v)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"base":  base,
+		"date":  day.Format(dayLayout),
+		"rates": result,
+	})
+}
+
+func keysOfRates(rates map[string][]ratePoint) []string {
+	keys := make([]string, 0, len(rates))
+	for k := range rates {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func round6(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"daily-planner-backend/internal/models"
+)
+
+func day(s string) time.Time {
+	t, _ := time.ParseInLocation(dayLayout, s, time.Local)
+	return t
+}
+
+func TestCurrencyConverterRateOnDate(t *testing.T) {
+	conv := newCurrencyConverter("CNY", []models.ExchangeRate{
+		{Currency: "USD", Date: day("2024-03-01"), Rate: 7.2},
+		{Currency: "USD", Date: day("2024-01-01"), Rate: 7.1},
+		{Currency: "JPY", Date: day("2024-01-01"), Rate: 0.05},
+	})
+
+	cases := []struct {
+		currency, date string
+		want           float64
+	}{
+		{"USD", "2023-12-01", 7.1}, // 早于全部记录时用最早的汇率
+		{"USD", "2024-01-01", 7.1},
+		{"USD", "2024-02-29", 7.1},
+		{"USD", "2024-03-01", 7.2},
+		{"USD", "2025-01-01", 7.2},
+		{"CNY", "2024-01-01", 1},
+	}
+	for _, tc := range cases {
+		if got, ok := conv.rate(tc.currency, day(tc.date)); !ok || got != tc.want {
+			t.Errorf("rate(%s, %s) = %v, %v; want %v", tc.currency, tc.date, got, ok, tc.want)
+		}
+	}
+}
+
+func TestCurrencyConverterConvert(t *testing.T) {
+	rates := []models.ExchangeRate{
+		{Currency: "USD", Date: day("2024-01-01"), Rate: 7.2},
+		{Currency: "JPY", Date: day("2024-01-01"), Rate: 0.048},
+	}
+
+	conv := newCurrencyConverter("CNY", rates)
+	if got, _ := conv.convert(100, "USD", day("2024-05-01")); math.Abs(got-720) > 1e-9 {
+		t.Errorf("Expected 100 USD = 720 CNY, got %v", got)
+	}
+	if got, _ := conv.convert(88, "", day("2024-05-01")); got != 88 {
+		t.Errorf("Expected legacy amounts without currency to be CNY, got %v", got)
+	}
+
+	// 本位币为美元时经人民币交叉换算
+	conv = newCurrencyConverter("USD", rates)
+	if got, _ := conv.convert(15000, "JPY", day("2024-05-01")); math.Abs(got-100) > 1e-9 {
+		t.Errorf("Expected 15000 JPY = 100 USD, got %v", got)
+	}
+	if got, _ := conv.convert(72, "CNY", day("2024-05-01")); math.Abs(got-10) > 1e-9 {
+		t.Errorf("Expected 72 CNY = 10 USD, got %v", got)
+	}
+
+	if _, ok := conv.convert(10, "EUR", day("2024-05-01")); ok {
+		t.Error("Expected conversion without a rate to fail")
+	}
+	if missing := conv.missingCurrencies(); len(missing) != 1 || missing[0] != "EUR" {
+		t.Errorf("Expected EUR to be reported missing, got %v", missing)
+	}
+}
+
+func TestParseExchangeRateCSV(t *testing.T) {
+	data := "\ufeff日期,币种,汇率\n" +
+		"2024-01-02,usd,7.1\n" +
+		"2024-01-02,JPY,0.0485\n" +
+		"\n" +
+		"2024/01/03,USD,7.2\n" +
+		"2024-01-03,CNY,1\n" +
+		"2024-01-03,EUR,-1\n"
+
+	rates, problems, err := parseExchangeRateCSV(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("parseExchangeRateCSV failed: %v", err)
+	}
+	if len(rates) != 2 || rates[0].Currency != "USD" || rates[0].Rate != 7.1 || rates[1].Currency != "JPY" || rates[0].Source != "csv" {
+		t.Errorf("Unexpected rates: %+v", rates)
+	}
+	if len(problems) != 3 || !strings.HasPrefix(problems[0], "line 5:") {
+		t.Errorf("Unexpected problems: %v", problems)
+	}
+
+	if _, _, err := parseExchangeRateCSV(strings.NewReader("date,rate\n2024-01-01,7\n")); err != errInvalidRateCSV {
+		t.Errorf("Expected missing column to be rejected, got %v", err)
+	}
+}
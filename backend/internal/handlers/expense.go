@@ -1,46 +1,196 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"daily-planner-backend/internal/database"
 	"daily-planner-backend/internal/models"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type CreateExpenseRequest struct {
-	Amount   float64 `json:"amount" binding:"required"`
-	Category string  `json:"category" binding:"required"`
-	Note     string  `json:"note"`
+	Type      string  `json:"type" binding:"omitempty,oneof=expense income"` // 缺省为支出
+	Amount    float64 `json:"amount" binding:"required"`
+	Currency  string  `json:"currency" binding:"omitempty,iso4217"` // 缺省为用户的本位币，记入共享账本时为账本币种
+	Category  string  `json:"category"`                             // 为空时按自动分类规则设置
+	Note      string  `json:"note"`
+	Merchant  string  `json:"merchant" binding:"max=100"`
+	Tags      string  `json:"tags"` // 逗号分隔
+	AccountID uint    `json:"account_id"`
+	GoalID    uint    `json:"goal_id"`
+	SpentAt   string  `json:"spent_at"` // 缺省为当前时间
+
+	Reimbursable bool `json:"reimbursable"` // 公司需报销的垫付支出，状态为待报销
+
+	LedgerID uint          `json:"ledger_id"` // 记入共享账本，当前用户为付款人
+	Split    *SplitRequest `json:"split"`     // 缺省由全体成员平均分摊
 }
 
 type UpdateExpenseRequest struct {
-	Amount   float64 `json:"amount"`
-	Category string  `json:"category"`
-	Note     string  `json:"note"`
+	Type      string  `json:"type" binding:"omitempty,oneof=expense income"`
+	Amount    float64 `json:"amount"`
+	Currency  string  `json:"currency" binding:"omitempty,iso4217"`
+	Category  string  `json:"category"`
+	Note      string  `json:"note"`
+	Merchant  string  `json:"merchant" binding:"max=100"`
+	Tags      *string `json:"tags"`
+	AccountID *uint   `json:"account_id"` // 0 表示取消关联账户
+	GoalID    *uint   `json:"goal_id"`    // 0 表示取消关联目标
+	SpentAt   string  `json:"spent_at"`
+
+	Reimbursable *bool `json:"reimbursable"` // 已加入报销单的支出不能取消报销标记
+
+	Split *SplitRequest `json:"split"` // 重新分摊共享支出；只改金额时按原有份数重算
+}
+
+// expenseFilter 支出列表的查询条件，to 转换为次日零点作为开区间上界
+type expenseFilter struct {
+	Type            string // 空表示全部类型
+	From            *time.Time
+	Before          *time.Time
+	Category        string
+	ReimburseStatus string
+	Page            int
+	PageSize        int // 0 表示不分页
+}
+
+// parseExpenseFilter 解析支出列表的查询参数。未传分页参数时返回全部，兼容旧客户端；
+// 只传 page 时每页 20 条
+func parseExpenseFilter(q url.Values) (expenseFilter, error) {
+	f := expenseFilter{Page: 1}
+
+	typ := q.Get("type")
+	switch typ {
+	case "":
+		f.Type = transactionExpense
+	case transactionExpense, transactionIncome:
+		f.Type = typ
+	case "all":
+	default:
+		return f, errors.New("type must be one of expense, income, all")
+	}
+
+	if from := q.Get("from"); from != "" {
+		t, err := time.ParseInLocation(dayLayout, from, time.Local)
+		if err != nil {
+			return f, errors.New("invalid date format, use YYYY-MM-DD")
+		}
+		f.From = &t
+	}
+	if to := q.Get("to"); to != "" {
+		t, err := time.ParseInLocation(dayLayout, to, time.Local)
+		if err != nil {
+			return f, errors.New("invalid date format, use YYYY-MM-DD")
+		}
+		before := t.AddDate(0, 0, 1)
+		f.Before = &before
+	}
+	f.Category = q.Get("category")
+	f.ReimburseStatus = q.Get("reimburse_status")
+
+	if s := q.Get("page_size"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 200 {
+			return f, errors.New("page_size must be between 1 and 200")
+		}
+		f.PageSize = n
+	}
+	if s := q.Get("page"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return f, errors.New("page must be a positive integer")
+		}
+		f.Page = n
+		if f.PageSize == 0 {
+			f.PageSize = 20
+		}
+	}
+	return f, nil
 }
 
+// apply 将过滤条件（不含分页）加到查询上
+func (f expenseFilter) apply(query *gorm.DB) *gorm.DB {
+	if f.Type != "" {
+		query = query.Where("type = ?", f.Type)
+	}
+	if f.From != nil {
+		query = query.Where("spent_at >= ?", *f.From)
+	}
+	if f.Before != nil {
+		query = query.Where("spent_at < ?", *f.Before)
+	}
+	if f.Category != "" {
+		query = query.Where("category = ?", f.Category)
+	}
+	if f.ReimburseStatus != "" {
+		query = query.Where("reimbursable = ? AND reimburse_status = ?", true, f.ReimburseStatus)
+	}
+	return query
+}
+
+// GetExpenses 支出列表，支持 from/to（按消费日期，均包含）、category、reimburse_status 过滤和分页；
+// 默认只返回支出，type=income 返回收入，type=all 返回全部。
+// total 为过滤后全部记录按消费日期汇率换算为本位币（currency）后的合计，不受分页影响
 func GetExpenses(c *gin.Context) {
 	userID := c.GetUint("userID")
 
-	var expenses []models.Expense
-	if err := database.GetDB().Where("user_id = ?", userID).Order("created_at DESC").Find(&expenses).Error; err != nil {
+	filter, err := parseExpenseFilter(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query := filter.apply(database.GetDB().Model(&models.Expense{}).Where("user_id = ?", userID))
+
+	conv, err := loadCurrencyConverter(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	total, count, err := convertedSum(query, conv)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
 
-	var total float64
-	for _, e := range expenses {
-		total += e.Amount
+	list := query.Session(&gorm.Session{}).Preload("Attachments").Order("spent_at DESC, id DESC")
+	if filter.PageSize > 0 {
+		list = list.Offset((filter.Page - 1) * filter.PageSize).Limit(filter.PageSize)
+	}
+
+	var expenses []models.Expense
+	if err := list.Find(&expenses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"total":    total,
-		"expenses": expenses,
+		"total":         total,
+		"count":         count,
+		"currency":      conv.base,
+		"missing_rates": conv.missingCurrencies(),
+		"page":          filter.Page,
+		"page_size":     filter.PageSize,
+		"expenses":      expenses,
 	})
 }
 
+// parseSpentAt 解析消费时间，支持 RFC3339、"YYYY-MM-DD HH:MM:SS" 和 "YYYY-MM-DD"
+func parseSpentAt(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", s, time.Local)
+}
+
 func CreateExpense(c *gin.Context) {
 	userID := c.GetUint("userID")
 
@@ -50,21 +200,114 @@ func CreateExpense(c *gin.Context) {
 		return
 	}
 
+	spentAt := time.Now()
+	if req.SpentAt != "" {
+		t, err := parseSpentAt(req.SpentAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid spent_at, use RFC3339 or YYYY-MM-DD"})
+			return
+		}
+		spentAt = t
+	}
+
+	if req.Type == "" {
+		req.Type = transactionExpense
+	}
+	if req.Reimbursable && req.Type != transactionExpense {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "only expenses can be reimbursable"})
+		return
+	}
+
+	if req.Currency == "" && req.LedgerID == 0 {
+		base, err := userBaseCurrency(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		req.Currency = base
+	}
+
 	expense := models.Expense{
 		UserID:   userID,
+		Type:     req.Type,
 		Amount:   req.Amount,
+		Currency: req.Currency,
 		Category: req.Category,
 		Note:     req.Note,
+		Merchant: req.Merchant,
+		Tags:     normalizeTags(req.Tags),
+		SpentAt:  spentAt,
+	}
+	if req.Reimbursable {
+		expense.Reimbursable = true
+		expense.ReimburseStatus = reimbursePending
+	}
+
+	// 未指定分类时依次使用自动分类规则、内置关键字，都未命中时归入“其他”
+	if expense.Category == "" {
+		rules, err := loadCategoryRules(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		if !applyCategoryRule(rules, &expense) {
+			if expense.Type == transactionIncome {
+				expense.Category = fallbackIncomeCategory
+			} else {
+				expense.Category = guessCategory("", expense.Merchant, expense.Note)
+			}
+		}
+	}
+
+	if !validateGoalLink(c, userID, req.GoalID) || !validateAccountLink(c, userID, req.AccountID) {
+		return
+	}
+	if req.GoalID != 0 {
+		expense.GoalID = &req.GoalID
+	}
+	if req.AccountID != 0 {
+		expense.AccountID = &req.AccountID
 	}
 
+	if req.LedgerID != 0 {
+		ledger, ok := loadLedgerForMember(c, req.LedgerID)
+		if !ok {
+			return
+		}
+		if expense.Type != transactionExpense {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "only expenses can be recorded in a shared ledger"})
+			return
+		}
+		if expense.Currency == "" {
+			expense.Currency = ledger.Currency
+		} else if expense.Currency != ledger.Currency {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expense currency must match ledger currency"})
+			return
+		}
+		splits, ok := buildExpenseSplits(c, ledger, expense.Amount, req.Split)
+		if !ok {
+			return
+		}
+		expense.LedgerID = &ledger.ID
+		expense.Splits = splits
+	}
+
+	// 分摊记录随支出一并创建
 	if err := database.GetDB().Create(&expense).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
 
+	if expense.Type == transactionExpense {
+		checkExpenseBudgets(&expense)
+	} else {
+		autoSetAside(&expense)
+	}
+
 	c.JSON(http.StatusCreated, expense)
 }
 
+// UpdateExpense 修改收支，共享账本中的支出也可由账本成员修改
 func UpdateExpense(c *gin.Context) {
 	userID := c.GetUint("userID")
 	expenseID := c.Param("id")
@@ -75,7 +318,12 @@ func UpdateExpense(c *gin.Context) {
 		return
 	}
 
-	if expense.UserID != userID {
+	allowed, err := canAccessLedgerExpense(&expense, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if !allowed {
 		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
 		return
 	}
@@ -86,25 +334,183 @@ func UpdateExpense(c *gin.Context) {
 		return
 	}
 
+	// 账户和目标属于付款人，账本其他成员不能修改
+	if expense.UserID != userID && (req.AccountID != nil || req.GoalID != nil) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the payer can change account or goal"})
+		return
+	}
+	if expense.LedgerID != nil && req.Type == transactionIncome {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "only expenses can be recorded in a shared ledger"})
+		return
+	}
+	// 共享账本内的支出使用账本币种，改币种会使分摊和结算失衡
+	if expense.LedgerID != nil && req.Currency != "" && req.Currency != expense.Currency {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expense currency must match ledger currency"})
+		return
+	}
+	if expense.ClaimID != nil && (req.Type == transactionIncome || (req.Reimbursable != nil && !*req.Reimbursable)) {
+		c.JSON(http.StatusConflict, gin.H{"error": "expense is part of a reimbursement claim"})
+		return
+	}
+	amountChanged := (req.Amount != 0 && req.Amount != expense.Amount) || (req.Currency != "" && req.Currency != expense.Currency)
+	if expense.ClaimID != nil && amountChanged && expense.ReimburseStatus == reimburseReimbursed {
+		c.JSON(http.StatusConflict, gin.H{"error": "reimbursed expenses cannot change their amount"})
+		return
+	}
+	if expense.LedgerID == nil && req.Split != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "split is only available for shared ledger expenses"})
+		return
+	}
+
 	updates := make(map[string]interface{})
+	if req.Type != "" {
+		updates["type"] = req.Type
+	}
 	if req.Amount != 0 {
 		updates["amount"] = req.Amount
 	}
+	if req.Currency != "" {
+		updates["currency"] = req.Currency
+	}
 	if req.Category != "" {
 		updates["category"] = req.Category
 	}
 	if req.Note != "" {
 		updates["note"] = req.Note
 	}
+	if req.Merchant != "" {
+		updates["merchant"] = req.Merchant
+	}
+	if req.Tags != nil {
+		updates["tags"] = normalizeTags(*req.Tags)
+	}
+	if req.Type == transactionIncome && expense.Reimbursable && req.Reimbursable == nil {
+		// 改为收入时一并取消报销标记
+		f := false
+		req.Reimbursable = &f
+	}
+	if req.Reimbursable != nil && *req.Reimbursable != expense.Reimbursable {
+		if *req.Reimbursable && (req.Type == transactionIncome || (req.Type == "" && expense.Type == transactionIncome)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "only expenses can be reimbursable"})
+			return
+		}
+		updates["reimbursable"] = *req.Reimbursable
+		if *req.Reimbursable {
+			updates["reimburse_status"] = reimbursePending
+		} else {
+			updates["reimburse_status"] = ""
+		}
+	}
+	if req.GoalID != nil {
+		if !validateGoalLink(c, expense.UserID, *req.GoalID) {
+			return
+		}
+		updates["goal_id"] = goalIDValue(*req.GoalID)
+	}
+	if req.AccountID != nil {
+		if !validateAccountLink(c, expense.UserID, *req.AccountID) {
+			return
+		}
+		if *req.AccountID == 0 {
+			updates["account_id"] = nil
+		} else {
+			updates["account_id"] = *req.AccountID
+		}
+	}
+	if req.SpentAt != "" {
+		t, err := parseSpentAt(req.SpentAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid spent_at, use RFC3339 or YYYY-MM-DD"})
+			return
+		}
+		updates["spent_at"] = t
+	}
 
-	if err := database.GetDB().Model(&expense).Updates(updates).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	var splits []models.ExpenseSplit
+	if expense.LedgerID != nil && (req.Split != nil || (req.Amount != 0 && req.Amount != expense.Amount)) {
+		amount := expense.Amount
+		if req.Amount != 0 {
+			amount = req.Amount
+		}
+		if req.Split != nil {
+			var ledger models.Ledger
+			if err := database.GetDB().First(&ledger, *expense.LedgerID).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+				return
+			}
+			var ok bool
+			if splits, ok = buildExpenseSplits(c, &ledger, amount, req.Split); !ok {
+				return
+			}
+		} else {
+			var current []models.ExpenseSplit
+			if err := database.GetDB().Where("expense_id = ?", expense.ID).Order("id ASC").Find(&current).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+				return
+			}
+			if splits, err = resplitByShares(current, amount); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "split must be provided when changing the amount of an exact split"})
+				return
+			}
+		}
+	}
+
+	// 报销单合计按消费日期换算，金额、币种或日期变化时都要重新计算
+	var conv *currencyConverter
+	if expense.ClaimID != nil && (req.Amount != 0 || req.Currency != "" || req.SpentAt != "") {
+		if conv, err = loadCurrencyConverter(expense.UserID, req.Currency); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+	}
+
+	resetSetAside := resetsSetAside(&expense, &req)
+
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&expense).Updates(updates).Error; err != nil {
+			return err
+		}
+		if resetSetAside {
+			if err := removeSetAside(tx, expense.ID); err != nil {
+				return err
+			}
+		}
+		if conv != nil {
+			if err := refreshClaimTotal(tx, &models.ReimbursementClaim{ID: *expense.ClaimID}, conv); err != nil {
+				return err
+			}
+		}
+		if splits == nil {
+			return nil
+		}
+		if err := tx.Where("expense_id = ?", expense.ID).Delete(&models.ExpenseSplit{}).Error; err != nil {
+			return err
+		}
+		for i := range splits {
+			splits[i].ExpenseID = expense.ID
+		}
+		return tx.Create(&splits).Error
+	})
+	if err != nil {
+		writeClaimError(c, err, conv)
 		return
 	}
 
+	if expense.LedgerID != nil {
+		database.GetDB().Where("expense_id = ?", expense.ID).Order("id ASC").Find(&expense.Splits)
+	}
+
+	if expense.Type == transactionExpense {
+		checkExpenseBudgets(&expense)
+	}
+	if resetSetAside {
+		autoSetAside(&expense)
+	}
+
 	c.JSON(http.StatusOK, expense)
 }
 
+// DeleteExpense 删除收支及其分摊记录和由收入自动存入的储蓄记录，共享账本中的支出也可由账本成员删除
 func DeleteExpense(c *gin.Context) {
 	userID := c.GetUint("userID")
 	expenseID := c.Param("id")
@@ -115,15 +521,40 @@ func DeleteExpense(c *gin.Context) {
 		return
 	}
 
-	if expense.UserID != userID {
+	allowed, err := canAccessLedgerExpense(&expense, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if !allowed {
 		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
 		return
 	}
 
-	if err := database.GetDB().Delete(&expense).Error; err != nil {
+	if expense.ClaimID != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "expense is part of a reimbursement claim"})
+		return
+	}
+
+	var keys []string
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expense_id = ?", expense.ID).Delete(&models.ExpenseSplit{}).Error; err != nil {
+			return err
+		}
+		if err := removeSetAside(tx, expense.ID); err != nil {
+			return err
+		}
+		if keys, err = deleteAttachmentRows(tx, attachmentOwnerExpense, expense.ID); err != nil {
+			return err
+		}
+		return tx.Delete(&expense).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
 
+	removeAttachmentFiles(c.Request.Context(), keys)
+
 	c.JSON(http.StatusOK, gin.H{"message": "expense deleted"})
 }
@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"net/url"
+	"strconv"
 	"testing"
+	"time"
 
 	"pgregory.net/rapid"
 )
@@ -61,3 +64,64 @@ func TestExpenseAmountValidation(t *testing.T) {
 		}
 	})
 }
+
+func TestParseExpenseFilter(t *testing.T) {
+	f, err := parseExpenseFilter(url.Values{})
+	if err != nil || f.Type != transactionExpense || f.Page != 1 || f.PageSize != 0 || f.From != nil || f.Before != nil {
+		t.Errorf("Unexpected default filter: %+v, %v", f, err)
+	}
+
+	// to 包含当天，转换为次日零点的开区间上界
+	f, err = parseExpenseFilter(url.Values{"type": {"all"}, "from": {"2024-03-01"}, "to": {"2024-03-31"}, "category": {"餐饮"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if f.Type != "" || f.Category != "餐饮" {
+		t.Errorf("Unexpected filter: %+v", f)
+	}
+	if !f.From.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)) || !f.Before.Equal(time.Date(2024, 4, 1, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("Unexpected date range: %v - %v", f.From, f.Before)
+	}
+
+	f, err = parseExpenseFilter(url.Values{"page": {"3"}})
+	if err != nil || f.Page != 3 || f.PageSize != 20 {
+		t.Errorf("Expected page without page_size to default to 20 per page: %+v, %v", f, err)
+	}
+
+	f, err = parseExpenseFilter(url.Values{"page_size": {"50"}})
+	if err != nil || f.Page != 1 || f.PageSize != 50 {
+		t.Errorf("Expected page_size alone to start at page 1: %+v, %v", f, err)
+	}
+
+	invalid := []url.Values{
+		{"type": {"transfer"}},
+		{"from": {"2024/03/01"}},
+		{"to": {"2024-02-30"}},
+		{"page": {"0"}},
+		{"page": {"x"}},
+		{"page_size": {"0"}},
+		{"page_size": {"201"}},
+	}
+	for _, q := range invalid {
+		if _, err := parseExpenseFilter(q); err == nil {
+			t.Errorf("Expected error for %v", q)
+		}
+	}
+}
+
+// **Feature: expense-filters, Property 1: Valid page sizes are accepted and out-of-range ones rejected**
+func TestExpenseFilterPageSizeRange(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		size := rapid.IntRange(-10, 300).Draw(t, "pageSize")
+		page := rapid.IntRange(1, 1000).Draw(t, "page")
+
+		f, err := parseExpenseFilter(url.Values{"page_size": {strconv.Itoa(size)}, "page": {strconv.Itoa(page)}})
+		valid := size >= 1 && size <= 200
+		if valid && (err != nil || f.PageSize != size || f.Page != page) {
+			t.Fatalf("Expected page %d size %d to be accepted: %+v, %v", page, size, f, err)
+		}
+		if !valid && err == nil {
+			t.Fatalf("Expected page_size %d to be rejected", size)
+		}
+	})
+}
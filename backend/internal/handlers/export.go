@@ -0,0 +1,416 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"daily-planner-backend/internal/database"
+	"daily-planner-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"gorm.io/gorm"
+)
+
+// beancountAccountPattern 合法的 Beancount 账户名
+var beancountAccountPattern = regexp.MustCompile(`^(Assets|Liabilities|Equity|Income|Expenses)(:[^\s:"]+)+$`)
+
+// beancountCategoryAccounts 默认分类对应的 Beancount 子账户
+var beancountCategoryAccounts = map[string]string{
+	"餐饮": "Food",
+	"交通": "Transport",
+	"购物": "Shopping",
+	"娱乐": "Entertainment",
+	"其他": "Misc",
+}
+
+// expenseExporter 按格式逐条写出支出
+type expenseExporter interface {
+	ContentType() string
+	Extension() string
+	Begin(w io.Writer) error
+	Write(w io.Writer, e *models.Expense) error
+	End(w io.Writer) error
+}
+
+// csvExporter 导出 CSV，默认带 BOM 的 UTF-8，便于 Excel 直接打开
+type csvExporter struct {
+	gbk bool
+	cw  *csv.Writer
+}
+
+// csvSafe 防止以公式字符开头的文本在电子表格中被当作公式执行
+func csvSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func (x *csvExporter) ContentType() string {
+	if x.gbk {
+		return "text/csv; charset=gbk"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+func (x *csvExporter) Extension() string { return "csv" }
+
+func (x *csvExporter) Begin(w io.Writer) error {
+	if x.gbk {
+		w = encoding.ReplaceUnsupported(simplifiedchinese.GBK.NewEncoder()).Writer(w)
+	} else if _, err := io.WriteString(w, "\xef\xbb\xbf"); err != nil {
+		return err
+	}
+	x.cw = csv.NewWriter(w)
+	return x.cw.Write([]string{"消费时间", "金额", "分类", "商户", "备注", "币种"})
+}
+
+func (x *csvExporter) Write(_ io.Writer, e *models.Expense) error {
+	return x.cw.Write([]string{
+		e.SpentAt.Format("2006-01-02 15:04:05"),
+		strconv.FormatFloat(e.Amount, 'f', 2, 64),
+		csvSafe(e.Category),
+		csvSafe(e.Merchant),
+		csvSafe(e.Note),
+		e.Currency,
+	})
+}
+
+func (x *csvExporter) End(_ io.Writer) error {
+	x.cw.Flush()
+	return x.cw.Error()
+}
+
+// ofxExporter 导出 OFX 2.1.1 银行对账单，支出记为 DEBIT。
+// 其他币种的支出保留原金额并附带折合 currency 的汇率，余额按折合后的金额累计
+type ofxExporter struct {
+	userID   uint
+	from, to time.Time
+	currency string
+	conv     *currencyConverter // 换算为 currency
+	total    float64
+}
+
+const ofxTimeLayout = "20060102150405"
+
+func ofxEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func (x *ofxExporter) ContentType() string { return "application/x-ofx; charset=utf-8" }
+
+func (x *ofxExporter) Extension() string { return "ofx" }
+
+func (x *ofxExporter) Begin(w io.Writer) error {
+	now := time.Now().Format(ofxTimeLayout)
+	_, err := fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<?OFX OFXHEADER="200" VERSION="211" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+<OFX>
+<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS><DTSERVER>%s</DTSERVER><LANGUAGE>CHI</LANGUAGE></SONRS></SIGNONMSGSRSV1>
+<BANKMSGSRSV1><STMTTRNRS><TRNUID>1</TRNUID><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>
+<STMTRS><CURDEF>%s</CURDEF>
+<BANKACCTFROM><BANKID>DAILYPLANNER</BANKID><ACCTID>%d</ACCTID><ACCTTYPE>CHECKING</ACCTTYPE></BANKACCTFROM>
+<BANKTRANLIST><DTSTART>%s</DTSTART><DTEND>%s</DTEND>
+`, now, x.currency, x.userID, x.from.Format(ofxTimeLayout), x.to.Format(ofxTimeLayout))
+	return err
+}
+
+func (x *ofxExporter) Write(w io.Writer, e *models.Expense) error {
+	var foreign string
+	if e.Currency != "" && e.Currency != x.currency {
+		rate, ok := x.conv.convert(1, e.Currency, e.SpentAt)
+		if !ok {
+			return errMissingRates
+		}
+		x.total += e.Amount * rate
+		foreign = fmt.Sprintf("<CURRENCY><CURRATE>%s</CURRATE><CURSYM>%s</CURSYM></CURRENCY>",
+			strconv.FormatFloat(rate, 'f', 6, 64), e.Currency)
+	} else {
+		x.total += e.Amount
+	}
+	name := e.Merchant
+	if name == "" {
+		name = e.Category
+	}
+	_, err := fmt.Fprintf(w, "<STMTTRN><TRNTYPE>DEBIT</TRNTYPE><DTPOSTED>%s</DTPOSTED><TRNAMT>%s</TRNAMT><FITID>%d</FITID><NAME>%s</NAME><MEMO>%s</MEMO>%s</STMTTRN>\n",
+		e.SpentAt.Format(ofxTimeLayout), strconv.FormatFloat(-e.Amount, 'f', 2, 64), e.ID,
+		ofxEscape(truncateRunes(name, 32)), ofxEscape(truncateRunes(strings.TrimSpace(e.Category+" "+e.Note), 255)), foreign)
+	return err
+}
+
+func (x *ofxExporter) End(w io.Writer) error {
+	_, err := fmt.Fprintf(w, `</BANKTRANLIST>
+<LEDGERBAL><BALAMT>%s</BALAMT><DTASOF>%s</DTASOF></LEDGERBAL>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`, strconv.FormatFloat(-x.total, 'f', 2, 64), x.to.Format(ofxTimeLayout))
+	return err
+}
+
+// beancountExporter 导出 Beancount 交易，每笔支出从付款账户转入分类对应的费用账户
+type beancountExporter struct {
+	expenseRoot    string            // 费用账户前缀
+	paymentAccount string            // 付款账户
+	overrides      map[string]string // 分类到账户的自定义映射
+	currency       string            // 付款账户的币种
+	conv           *currencyConverter
+	openDate       time.Time
+	categories     []string
+}
+
+// beancountString 转义 Beancount 字符串中的反斜杠和双引号
+func beancountString(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", " ", "\r", " ").Replace(s)
+	return `"` + s + `"`
+}
+
+// beancountComponent 将分类名转换为合法的账户名片段：去掉空白和冒号，首字母大写
+func beancountComponent(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case b.Len() > 0:
+			b.WriteRune('-')
+		}
+	}
+	s := strings.TrimRight(b.String(), "-")
+	if s == "" {
+		return "Misc"
+	}
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+func (x *beancountExporter) account(category string) string {
+	if a, ok := x.overrides[category]; ok {
+		return a
+	}
+	if a, ok := beancountCategoryAccounts[category]; ok {
+		return x.expenseRoot + ":" + a
+	}
+	return x.expenseRoot + ":" + beancountComponent(category)
+}
+
+func (x *beancountExporter) ContentType() string { return "text/plain; charset=utf-8" }
+
+func (x *beancountExporter) Extension() string { return "beancount" }
+
+func (x *beancountExporter) Begin(w io.Writer) error {
+	date := x.openDate.Format(dayLayout)
+	if _, err := fmt.Fprintf(w, "%s open %s\n", date, x.paymentAccount); err != nil {
+		return err
+	}
+	opened := map[string]bool{x.paymentAccount: true}
+	for _, c := range x.categories {
+		a := x.account(c)
+		if opened[a] {
+			continue
+		}
+		opened[a] = true
+		if _, err := fmt.Fprintf(w, "%s open %s\n", date, a); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func (x *beancountExporter) Write(w io.Writer, e *models.Expense) error {
+	// 其他币种的支出保留原币种金额，用 @@ 标注折合付款账户币种的总价
+	currency, price := x.currency, ""
+	if e.Currency != "" && e.Currency != x.currency {
+		rate, ok := x.conv.convert(1, e.Currency, e.SpentAt)
+		if !ok {
+			return errMissingRates
+		}
+		currency = e.Currency
+		price = fmt.Sprintf(" @@ %s %s", strconv.FormatFloat(round2(e.Amount*rate), 'f', 2, 64), x.currency)
+	}
+	_, err := fmt.Fprintf(w, "%s * %s %s\n  %s  %s %s%s\n  %s\n\n",
+		e.SpentAt.Format(dayLayout), beancountString(e.Merchant), beancountString(e.Note),
+		x.account(e.Category), strconv.FormatFloat(e.Amount, 'f', 2, 64), currency, price,
+		x.paymentAccount)
+	return err
+}
+
+func (x *beancountExporter) End(_ io.Writer) error { return nil }
+
+// ExportExpenses 按 format=csv|ofx|beancount 流式导出 from/to 范围内的支出。
+// csv 支持 encoding=gbk；beancount 支持 expense_account、payment_account 和 accounts[分类]=账户；
+// ofx 和 beancount 以 currency（缺省为本位币）为账户币种，其他币种的支出按消费日期的汇率折合
+func ExportExpenses(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	query := database.GetDB().Model(&models.Expense{}).Where("user_id = ? AND type = ?", userID, transactionExpense)
+
+	from, to := time.Time{}, today()
+	if s := c.Query("from"); s != "" {
+		t, err := time.ParseInLocation(dayLayout, s, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+			return
+		}
+		from = t
+		query = query.Where("spent_at >= ?", t)
+	}
+	if s := c.Query("to"); s != "" {
+		t, err := time.ParseInLocation(dayLayout, s, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+			return
+		}
+		to = t
+		query = query.Where("spent_at < ?", t.AddDate(0, 0, 1))
+	}
+
+	currency := strings.ToUpper(c.Query("currency"))
+	if currency == "" {
+		base, err := userBaseCurrency(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		currency = base
+	}
+	if len(currency) != 3 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "currency must be a 3-letter code"})
+		return
+	}
+
+	// 响应开始后无法再报错，先确认导出范围内的币种都能折合为 currency
+	var conv *currencyConverter
+	if format := c.Query("format"); format == "ofx" || format == "beancount" {
+		var currencies []string
+		if err := query.Session(&gorm.Session{}).Distinct().Pluck("currency", &currencies).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		var err error
+		if conv, err = loadCurrencyConverterTo(userID, currency); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		for _, cur := range currencies {
+			if cur != "" && cur != currency {
+				conv.convert(1, cur, to)
+			}
+		}
+		if missing := conv.missingCurrencies(); len(missing) > 0 {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": errMissingRates.Error(), "missing_rates": missing})
+			return
+		}
+	}
+
+	var exporter expenseExporter
+	switch format := c.DefaultQuery("format", "csv"); format {
+	case "csv":
+		exporter = &csvExporter{gbk: strings.EqualFold(c.Query("encoding"), "gbk")}
+	case "ofx":
+		exporter = &ofxExporter{userID: userID, from: from, to: to, currency: currency, conv: conv}
+	case "beancount":
+		bx := &beancountExporter{
+			expenseRoot:    c.DefaultQuery("expense_account", "Expenses"),
+			paymentAccount: c.DefaultQuery("payment_account", "Assets:Cash"),
+			overrides:      c.QueryMap("accounts"),
+			currency:       currency,
+			conv:           conv,
+			openDate:       from,
+		}
+		accounts := []string{bx.paymentAccount}
+		for _, a := range bx.overrides {
+			accounts = append(accounts, a)
+		}
+		for _, a := range accounts {
+			if !beancountAccountPattern.MatchString(a) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid beancount account: " + a})
+				return
+			}
+		}
+		if !strings.HasPrefix(bx.expenseRoot+":", "Expenses:") || !beancountAccountPattern.MatchString(bx.expenseRoot+":X") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expense_account must start with Expenses"})
+			return
+		}
+
+		// 账户需要先 open，预先查出涉及的分类和最早日期
+		var first struct {
+			First *time.Time
+		}
+		if err := query.Session(&gorm.Session{}).Select("MIN(spent_at) AS first").Scan(&first).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		if first.First != nil && (bx.openDate.IsZero() || first.First.Before(bx.openDate)) {
+			bx.openDate = *first.First
+		}
+		if bx.openDate.IsZero() {
+			bx.openDate = to
+		}
+		if err := query.Session(&gorm.Session{}).Distinct().Order("category").Pluck("category", &bx.categories).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		exporter = bx
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be one of csv, ofx, beancount"})
+		return
+	}
+
+	rows, err := query.Session(&gorm.Session{}).Order("spent_at ASC, id ASC").Rows()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	defer rows.Close()
+
+	filename := "expenses"
+	if !from.IsZero() {
+		filename += "-" + from.Format("20060102")
+	}
+	filename += "-" + to.Format("20060102") + "." + exporter.Extension()
+	c.Header("Content-Type", exporter.ContentType())
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Status(http.StatusOK)
+
+	// 响应头已发出，之后的错误只能记录日志
+	w := bufio.NewWriter(c.Writer)
+	if err := exporter.Begin(w); err != nil {
+		log.Printf("Failed to export expenses of user %d: %v", userID, err)
+		return
+	}
+	for rows.Next() {
+		var e models.Expense
+		if err := database.GetDB().ScanRows(rows, &e); err != nil {
+			log.Printf("Failed to export expenses of user %d: %v", userID, err)
+			return
+		}
+		if err := exporter.Write(w, &e); err != nil {
+			log.Printf("Failed to export expenses of user %d: %v", userID, err)
+			return
+		}
+	}
+	if err := exporter.End(w); err != nil {
+		log.Printf("Failed to export expenses of user %d: %v", userID, err)
+		return
+	}
+	if err := w.Flush(); err != nil {
+		log.Printf("Failed to export expenses of user %d: %v", userID, err)
+	}
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"daily-planner-backend/internal/models"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func exportSample(t *testing.T, x expenseExporter, expenses ...models.Expense) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := x.Begin(&buf); err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	for i := range expenses {
+		if err := x.Write(&buf, &expenses[i]); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := x.End(&buf); err != nil {
+		t.Fatalf("End failed: %v", err)
+	}
+	return buf.String()
+}
+
+var exportExpense = models.Expense{
+	ID:       7,
+	Amount:   32.5,
+	Currency: "CNY",
+	Category: "餐饮",
+	Merchant: `"老王"面馆, 总店`,
+	Note:     "=HYPERLINK(\"x\")",
+	SpentAt:  time.Date(2024, 3, 5, 12, 30, 0, 0, time.Local),
+}
+
+func TestCSVExportEscaping(t *testing.T) {
+	out := exportSample(t, &csvExporter{}, exportExpense)
+	if !strings.HasPrefix(out, "\xef\xbb\xbf消费时间,金额,分类,商户,备注,币种\n") {
+		t.Errorf("Expected BOM and header, got %q", out)
+	}
+	want := `2024-03-05 12:30:00,32.50,餐饮,"""老王""面馆, 总店","'=HYPERLINK(""x"")",CNY` + "\n"
+	if !strings.HasSuffix(out, want) {
+		t.Errorf("Expected escaped row %q, got %q", want, out)
+	}
+}
+
+func TestCSVExportGBK(t *testing.T) {
+	out := exportSample(t, &csvExporter{gbk: true}, exportExpense)
+	decoded, err := simplifiedchinese.GBK.NewDecoder().String(out)
+	if err != nil {
+		t.Fatalf("Output is not valid GBK: %v", err)
+	}
+	if !strings.HasPrefix(decoded, "消费时间,") || !strings.Contains(decoded, "老王") {
+		t.Errorf("Unexpected decoded output: %q", decoded)
+	}
+}
+
+func TestBeancountExport(t *testing.T) {
+	x := &beancountExporter{
+		expenseRoot:    "Expenses:Daily",
+		paymentAccount: "Assets:Alipay",
+		overrides:      map[string]string{"交通": "Expenses:Commute"},
+		currency:       "CNY",
+		openDate:       time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local),
+		categories:     []string{"交通", "餐饮", "book club"},
+	}
+	out := exportSample(t, x, exportExpense)
+
+	for _, want := range []string{
+		"2024-03-01 open Assets:Alipay\n",
+		"2024-03-01 open Expenses:Commute\n",
+		"2024-03-01 open Expenses:Daily:Food\n",
+		"2024-03-01 open Expenses:Daily:Book-club\n",
+		"2024-03-05 * \"\\\"老王\\\"面馆, 总店\" \"=HYPERLINK(\\\"x\\\")\"\n  Expenses:Daily:Food  32.50 CNY\n  Assets:Alipay\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestOFXExport(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+	x := &ofxExporter{userID: 1, from: from, to: from.AddDate(0, 0, 30), currency: "CNY"}
+	out := exportSample(t, x, exportExpense, exportExpense)
+
+	for _, want := range []string{
+		"<TRNAMT>-32.50</TRNAMT><FITID>7</FITID><NAME>&#34;老王&#34;面馆, 总店</NAME>",
+		"<BALAMT>-65.00</BALAMT>",
+		"<CURDEF>CNY</CURDEF>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+// exportRates 3 月 1 日起 1 美元折合 7.2 元
+var exportRates = []models.ExchangeRate{{Currency: "USD", Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local), Rate: 7.2}}
+
+func TestOFXExportForeignCurrency(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+	x := &ofxExporter{userID: 1, from: from, to: from.AddDate(0, 0, 30), currency: "CNY", conv: newCurrencyConverter("CNY", exportRates)}
+	foreign := exportExpense
+	foreign.ID, foreign.Amount, foreign.Currency = 8, 10, "USD"
+	out := exportSample(t, x, exportExpense, foreign)
+
+	for _, want := range []string{
+		"<TRNAMT>-32.50</TRNAMT><FITID>7</FITID>",
+		"<TRNAMT>-10.00</TRNAMT><FITID>8</FITID>",
+		"<CURRENCY><CURRATE>7.200000</CURRATE><CURSYM>USD</CURSYM></CURRENCY></STMTTRN>",
+		"<BALAMT>-104.50</BALAMT>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "<CURRENCY>") != 1 {
+		t.Errorf("Expected only the foreign row to carry a currency, got:\n%s", out)
+	}
+
+	missing := exportExpense
+	missing.Currency = "EUR"
+	if err := x.Write(&bytes.Buffer{}, &missing); !errors.Is(err, errMissingRates) {
+		t.Errorf("Expected errMissingRates for a currency without rates, got %v", err)
+	}
+}
+
+func TestBeancountExportCurrency(t *testing.T) {
+	x := &beancountExporter{
+		expenseRoot:    "Expenses",
+		paymentAccount: "Assets:Bank",
+		currency:       "USD",
+		conv:           newCurrencyConverter("USD", exportRates),
+		openDate:       time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local),
+	}
+	dollars := exportExpense
+	dollars.Amount, dollars.Currency = 12, "USD"
+	out := exportSample(t, x, exportExpense, dollars)
+
+	// 付款账户为美元时人民币支出标注折合的美元总价
+	for _, want := range []string{
+		"  Expenses:Food  32.50 CNY @@ 4.51 USD\n  Assets:Bank\n",
+		"  Expenses:Food  12.00 USD\n  Assets:Bank\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"daily-planner-backend/internal/database"
+	"daily-planner-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 专注会话状态
+const (
+	focusStatusActive  = "active"
+	focusStatusPaused  = "paused"
+	focusStatusStopped = "stopped"
+)
+
+type StartFocusRequest struct {
+	PlanID uint `json:"plan_id" binding:"required"`
+}
+
+// FocusSessionView 会话及截至当前的有效专注时长
+type FocusSessionView struct {
+	models.FocusSession
+	FocusedSeconds int64 `json:"focused_seconds"`
+}
+
+// FocusReportItem 专注统计项，Key 为计划 ID、标签或日期
+type FocusReportItem struct {
+	Key      string  `json:"key"`
+	PlanID   uint    `json:"plan_id,omitempty"`
+	Label    string  `json:"label"`
+	Seconds  int64   `json:"seconds"`
+	Minutes  float64 `json:"minutes"`
+	Sessions int64   `json:"sessions"`
+}
+
+// focusedSeconds 计算会话有效专注秒数：总时长减去已累计和当前进行中的暂停时长
+func focusedSeconds(s *models.FocusSession, now time.Time) int64 {
+	if s.Status == focusStatusStopped {
+		return s.DurationSeconds
+	}
+	elapsed := now.Sub(s.StartedAt)
+	if s.PausedAt != nil {
+		elapsed -= now.Sub(*s.PausedAt)
+	}
+	seconds := int64(elapsed/time.Second) - s.PausedSeconds
+	if seconds < 0 {
+		return 0
+	}
+	return seconds
+}
+
+func focusView(s *models.FocusSession) FocusSessionView {
+	return FocusSessionView{FocusSession: *s, FocusedSeconds: focusedSeconds(s, time.Now())}
+}
+
+// loadCurrentFocus 读取当前用户进行中或暂停的会话，不存在时已写入响应
+func loadCurrentFocus(c *gin.Context) (*models.FocusSession, bool) {
+	var session models.FocusSession
+	err := database.GetDB().Where("active_user_id = ?", c.GetUint("userID")).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no active focus session"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return nil, false
+	}
+	return &session, true
+}
+
+// GetCurrentFocus 当前进行中的专注会话
+func GetCurrentFocus(c *gin.Context) {
+	session, ok := loadCurrentFocus(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, focusView(session))
+}
+
+// StartFocus 开始专注，每个用户同时只能有一个进行中的会话
+func StartFocus(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req StartFocusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	var plan models.Plan
+	if err := database.GetDB().First(&plan, req.PlanID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return
+	}
+	role, err := planRole(&plan, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if role == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return
+	}
+
+	var count int64
+	if err := database.GetDB().Model(&models.FocusSession{}).Where("active_user_id = ?", userID).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "another focus session is already active"})
+		return
+	}
+
+	session := models.FocusSession{
+		UserID:       userID,
+		PlanID:       plan.ID,
+		ActiveUserID: &userID,
+		Status:       focusStatusActive,
+		StartedAt:    time.Now(),
+	}
+
+	// 并发开始时由唯一索引兜底
+	if err := database.GetDB().Create(&session).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "another focus session is already active"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, focusView(&session))
+}
+
+func PauseFocus(c *gin.Context) {
+	session, ok := loadCurrentFocus(c)
+	if !ok {
+		return
+	}
+	if session.Status != focusStatusActive {
+		c.JSON(http.StatusConflict, gin.H{"error": "focus session is not running"})
+		return
+	}
+
+	now := time.Now()
+	if err := database.GetDB().Model(session).Updates(map[string]interface{}{
+		"status":    focusStatusPaused,
+		"paused_at": now,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	session.Status = focusStatusPaused
+	session.PausedAt = &now
+
+	c.JSON(http.StatusOK, focusView(session))
+}
+
+func ResumeFocus(c *gin.Context) {
+	session, ok := loadCurrentFocus(c)
+	if !ok {
+		return
+	}
+	if session.Status != focusStatusPaused || session.PausedAt == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "focus session is not paused"})
+		return
+	}
+
+	pausedSeconds := session.PausedSeconds + int64(time.Since(*session.PausedAt)/time.Second)
+	if err := database.GetDB().Model(session).Updates(map[string]interface{}{
+		"status":         focusStatusActive,
+		"paused_at":      nil,
+		"paused_seconds": pausedSeconds,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	session.Status = focusStatusActive
+	session.PausedAt = nil
+	session.PausedSeconds = pausedSeconds
+
+	c.JSON(http.StatusOK, focusView(session))
+}
+
+// StopFocus 结束会话并固化有效专注时长
+func StopFocus(c *gin.Context) {
+	session, ok := loadCurrentFocus(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	duration := focusedSeconds(session, now)
+	pausedSeconds := session.PausedSeconds
+	if session.PausedAt != nil {
+		pausedSeconds += int64(now.Sub(*session.PausedAt) / time.Second)
+	}
+
+	if err := database.GetDB().Model(session).Updates(map[string]interface{}{
+		"status":           focusStatusStopped,
+		"active_user_id":   nil,
+		"paused_at":        nil,
+		"paused_seconds":   pausedSeconds,
+		"ended_at":         now,
+		"duration_seconds": duration,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	session.Status = focusStatusStopped
+	session.ActiveUserID = nil
+	session.PausedAt = nil
+	session.PausedSeconds = pausedSeconds
+	session.EndedAt = &now
+	session.DurationSeconds = duration
+
+	c.JSON(http.StatusOK, focusView(session))
+}
+
+// parseDateRange 解析 from/to（YYYY-MM-DD，均包含），缺省为截至今天的 defaultDays 天
+func parseDateRange(c *gin.Context, defaultDays int) (time.Time, time.Time, bool) {
+	now := time.Now()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	from := to.AddDate(0, 0, -(defaultDays - 1))
+
+	if s := c.Query("from"); s != "" {
+		t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+			return time.Time{}, time.Time{}, false
+		}
+		from = t
+	}
+	if s := c.Query("to"); s != "" {
+		t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+			return time.Time{}, time.Time{}, false
+		}
+		to = t
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must not be before from"})
+		return time.Time{}, time.Time{}, false
+	}
+
+	// 返回左闭右开区间
+	return from, to.AddDate(0, 0, 1), true
+}
+
+// GetFocusSessions 专注会话列表，可按计划过滤
+func GetFocusSessions(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	from, to, ok := parseDateRange(c, 7)
+	if !ok {
+		return
+	}
+
+	query := database.GetDB().Where("user_id = ? AND started_at >= ? AND started_at < ?", userID, from, to)
+	if planID := c.Query("plan_id"); planID != "" {
+		query = query.Where("plan_id = ?", planID)
+	}
+
+	var sessions []models.FocusSession
+	if err := query.Order("started_at DESC").Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	views := make([]FocusSessionView, len(sessions))
+	for i := range sessions {
+		views[i] = focusView(&sessions[i])
+	}
+
+	c.JSON(http.StatusOK, views)
+}
+
+func focusMinutes(seconds int64) float64 {
+	return math.Round(float64(seconds)/60*10) / 10
+}
+
+// GetFocusReport 按计划、标签或日期汇总已结束会话的专注分钟数
+func GetFocusReport(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	from, to, ok := parseDateRange(c, 7)
+	if !ok {
+		return
+	}
+
+	groupBy := c.DefaultQuery("group_by", "day")
+	base := database.GetDB().Model(&models.FocusSession{}).
+		Where("focus_sessions.user_id = ? AND focus_sessions.status = ? AND focus_sessions.started_at >= ? AND focus_sessions.started_at < ?",
+			userID, focusStatusStopped, from, to)
+
+	items := []FocusReportItem{}
+	var totalSeconds int64
+
+	switch groupBy {
+	case "day":
+		var rows []struct {
+			Day      time.Time
+			Seconds  int64
+			Sessions int64
+		}
+		if err := base.Select("DATE(started_at) AS day, SUM(duration_seconds) AS seconds, COUNT(*) AS sessions").
+			Group("DATE(started_at)").Order("day ASC").Scan(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		for _, r := range rows {
+			day := r.Day.Format("2006-01-02")
+			totalSeconds += r.Seconds
+			items = append(items, FocusReportItem{Key: day, Label: day, Seconds: r.Seconds, Minutes: focusMinutes(r.Seconds), Sessions: r.Sessions})
+		}
+
+	case "plan", "tag":
+		var rows []struct {
+			PlanID   uint
+			Content  string
+			Tags     string
+			Seconds  int64
+			Sessions int64
+		}
+		if err := base.Select("focus_sessions.plan_id, plans.content, plans.tags, SUM(focus_sessions.duration_seconds) AS seconds, COUNT(*) AS sessions").
+			Joins("JOIN plans ON plans.id = focus_sessions.plan_id").
+			Group("focus_sessions.plan_id, plans.content, plans.tags").Scan(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+
+		for _, r := range rows {
+			totalSeconds += r.Seconds
+		}
+
+		if groupBy == "plan" {
+			for _, r := range rows {
+				items = append(items, FocusReportItem{Key: strconv.FormatUint(uint64(r.PlanID), 10), PlanID: r.PlanID, Label: r.Content, Seconds: r.Seconds, Minutes: focusMinutes(r.Seconds), Sessions: r.Sessions})
+			}
+		} else {
+			// 多标签的计划计入每个标签，未打标签的计入空标签
+			byTag := make(map[string]*FocusReportItem)
+			for _, r := range rows {
+				tags := splitTags(r.Tags)
+				if len(tags) == 0 {
+					tags = []string{""}
+				}
+				for _, tag := range tags {
+					item, ok := byTag[tag]
+					if !ok {
+						item = &FocusReportItem{Key: tag, Label: tag}
+						byTag[tag] = item
+					}
+					item.Seconds += r.Seconds
+					item.Sessions += r.Sessions
+				}
+			}
+			for _, item := range byTag {
+				item.Minutes = focusMinutes(item.Seconds)
+				items = append(items, *item)
+			}
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i].Seconds > items[j].Seconds })
+
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid group_by, use plan, tag or day"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"group_by":      groupBy,
+		"from":          from.Format("2006-01-02"),
+		"to":            to.AddDate(0, 0, -1).Format("2006-01-02"),
+		"total_seconds": totalSeconds,
+		"total_minutes": focusMinutes(totalSeconds),
+		"items":         items,
+	})
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"daily-planner-backend/internal/models"
+
+	"pgregory.net/rapid"
+)
+
+// **Feature: focus-sessions, Property 1: Focused time excludes all paused time**
+func TestFocusedSecondsExcludesPauses(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+		focus1 := rapid.Int64Range(0, 3600).Draw(t, "focus1")
+		pause1 := rapid.Int64Range(0, 3600).Draw(t, "pause1")
+		focus2 := rapid.Int64Range(0, 3600).Draw(t, "focus2")
+		pause2 := rapid.Int64Range(0, 3600).Draw(t, "pause2")
+
+		// 专注 focus1 → 暂停 pause1（已恢复）→ 专注 focus2 → 当前仍暂停 pause2
+		pausedAt := start.Add(time.Duration(focus1+pause1+focus2) * time.Second)
+		now := pausedAt.Add(time.Duration(pause2) * time.Second)
+		session := models.FocusSession{
+			Status:        focusStatusPaused,
+			StartedAt:     start,
+			PausedAt:      &pausedAt,
+			PausedSeconds: pause1,
+		}
+
+		if got := focusedSeconds(&session, now); got != focus1+focus2 {
+			t.Fatalf("Expected %d focused seconds, got %d", focus1+focus2, got)
+		}
+
+		// Property: stopped sessions report the stored duration
+		session.Status = focusStatusStopped
+		session.DurationSeconds = focus1
+		if got := focusedSeconds(&session, now.Add(time.Hour)); got != focus1 {
+			t.Fatalf("Stopped session should report stored duration %d, got %d", focus1, got)
+		}
+	})
+}
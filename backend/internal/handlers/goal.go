@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"daily-planner-backend/internal/database"
+	"daily-planner-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 目标的度量方式
+const (
+	goalMetricPlans  = "plans"
+	goalMetricAmount = "amount"
+)
+
+// goalRiskTolerance 实际进度低于按时间线性推算的期望进度的这一比例时视为有风险
+const goalRiskTolerance = 0.9
+
+type CreateGoalRequest struct {
+	Name        string  `json:"name" binding:"required,max=100"`
+	Description string  `json:"description" binding:"max=500"`
+	Metric      string  `json:"metric" binding:"required,oneof=plans amount"`
+	AmountType  string  `json:"amount_type" binding:"omitempty,oneof=expense income"` // amount 目标累计支出还是收入，缺省为支出
+	TargetValue float64 `json:"target_value" binding:"required,gt=0"`
+	Unit        string  `json:"unit" binding:"max=20"`
+	StartDate   string  `json:"start_date"` // 缺省为今天
+	Deadline    string  `json:"deadline" binding:"required"`
+}
+
+type UpdateGoalRequest struct {
+	Name        string  `json:"name" binding:"max=100"`
+	Description *string `json:"description"`
+	TargetValue float64 `json:"target_value" binding:"omitempty,gt=0"`
+	Unit        *string `json:"unit"`
+	Deadline    string  `json:"deadline"`
+	Archived    *bool   `json:"archived"`
+}
+
+// GoalProgress 目标进度
+type GoalProgress struct {
+	Current   float64 `json:"current"`
+	Target    float64 `json:"target"`
+	Percent   float64 `json:"percent"`
+	Expected  float64 `json:"expected"`  // 按时间线性推算的当前应达进度
+	Projected float64 `json:"projected"` // 按当前速度推算截止时的进度
+	DaysLeft  int     `json:"days_left"`
+	Achieved  bool    `json:"achieved"`
+	AtRisk    bool    `json:"at_risk"`
+}
+
+// GoalView 目标及进度
+type GoalView struct {
+	models.Goal
+	Progress GoalProgress `json:"progress"`
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// computeGoalProgress 根据当前累计值和时间进度计算完成度及风险
+func computeGoalProgress(goal *models.Goal, current float64, now time.Time) GoalProgress {
+	p := GoalProgress{
+		Current:  round2(current),
+		Target:   goal.TargetValue,
+		Achieved: current >= goal.TargetValue,
+	}
+	if goal.TargetValue > 0 {
+		p.Percent = round2(math.Min(current/goal.TargetValue, 1) * 100)
+	}
+
+	// 截止日当天仍计入，按天计算
+	end := goal.Deadline.AddDate(0, 0, 1)
+	total := end.Sub(goal.StartDate).Hours() / 24
+	elapsed := now.Sub(goal.StartDate).Hours() / 24
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	if elapsed > total {
+		elapsed = total
+	}
+	p.DaysLeft = int(math.Ceil(end.Sub(now).Hours() / 24))
+	if p.DaysLeft < 0 {
+		p.DaysLeft = 0
+	}
+
+	if total > 0 {
+		p.Expected = round2(goal.TargetValue * elapsed / total)
+		if elapsed > 0 {
+			p.Projected = round2(current / elapsed * total)
+		}
+	}
+
+	if !p.Achieved {
+		p.AtRisk = p.DaysLeft == 0 || current < p.Expected*goalRiskTolerance
+	}
+	return p
+}
+
+// goalCurrentValue 从目标所有者关联的已完成计划或收支中累计当前值，金额换算为用户的本位币
+func goalCurrentValue(goal *models.Goal) (float64, error) {
+	db := database.GetDB()
+	switch goal.Metric {
+	case goalMetricPlans:
+		var count int64
+		err := db.Model(&models.Plan{}).Where("user_id = ? AND goal_id = ? AND status = ?", goal.UserID, goal.ID, "completed").Count(&count).Error
+		return float64(count), err
+	case goalMetricAmount:
+		conv, err := loadCurrencyConverter(goal.UserID)
+		if err != nil {
+			return 0, err
+		}
+		sum, _, err := convertedSum(goalAmountQuery(db, goal), conv)
+		return sum, err
+	}
+	return 0, nil
+}
+
+// goalAmountQuery 金额目标累计的记录：关联到目标、且类型与目标的 AmountType 一致的收支
+func goalAmountQuery(db *gorm.DB, goal *models.Goal) *gorm.DB {
+	typ := goal.AmountType
+	if typ == "" {
+		typ = transactionExpense
+	}
+	return db.Model(&models.Expense{}).Where("user_id = ? AND goal_id = ? AND type = ?", goal.UserID, goal.ID, typ)
+}
+
+func goalView(goal *models.Goal) (GoalView, error) {
+	current, err := goalCurrentValue(goal)
+	if err != nil {
+		return GoalView{}, err
+	}
+	return GoalView{Goal: *goal, Progress: computeGoalProgress(goal, current, time.Now())}, nil
+}
+
+// validateGoalLink 校验要关联的目标属于 ownerID（被关联记录的所有者），goalID 为 0 表示取消关联；失败时已写入响应
+func validateGoalLink(c *gin.Context, ownerID, goalID uint) bool {
+	if goalID == 0 {
+		return true
+	}
+	var goal models.Goal
+	if err := database.GetDB().First(&goal, goalID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "goal not found"})
+		return false
+	}
+	if goal.UserID != ownerID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return false
+	}
+	return true
+}
+
+// goalIDValue 将请求中的目标 ID 转换为字段值，0 表示清空
+func goalIDValue(goalID uint) interface{} {
+	if goalID == 0 {
+		return nil
+	}
+	return goalID
+}
+
+func loadOwnedGoal(c *gin.Context) (*models.Goal, bool) {
+	var goal models.Goal
+	if err := database.GetDB().First(&goal, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return nil, false
+	}
+
+	if goal.UserID != c.GetUint("userID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return nil, false
+	}
+
+	return &goal, true
+}
+
+func GetGoals(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	query := database.GetDB().Where("user_id = ?", userID)
+	if c.Query("archived") != "true" {
+		query = query.Where("archived = ?", false)
+	}
+
+	var goals []models.Goal
+	if err := query.Order("deadline ASC").Find(&goals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	views := make([]GoalView, 0, len(goals))
+	for i := range goals {
+		view, err := goalView(&goals[i])
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		views = append(views, view)
+	}
+
+	c.JSON(http.StatusOK, views)
+}
+
+// GetGoal 目标详情，附带目标所有者关联的计划和支出
+func GetGoal(c *gin.Context) {
+	goal, ok := loadOwnedGoal(c)
+	if !ok {
+		return
+	}
+
+	view, err := goalView(goal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	var plans []models.Plan
+	var expenses []models.Expense
+	if err := database.GetDB().Where("user_id = ? AND goal_id = ?", goal.UserID, goal.ID).Order("execution_date ASC").Find(&plans).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if err := database.GetDB().Where("user_id = ? AND goal_id = ?", goal.UserID, goal.ID).Order("spent_at DESC").Find(&expenses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"goal":     view,
+		"plans":    plans,
+		"expenses": expenses,
+	})
+}
+
+func CreateGoal(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req CreateGoalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	startDate := today()
+	if req.StartDate != "" {
+		d, err := time.ParseInLocation(dayLayout, req.StartDate, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+			return
+		}
+		startDate = d
+	}
+	deadline, err := time.ParseInLocation(dayLayout, req.Deadline, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+		return
+	}
+	if deadline.Before(startDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "deadline must not be before start_date"})
+		return
+	}
+
+	if req.AmountType == "" {
+		req.AmountType = transactionExpense
+	}
+
+	goal := models.Goal{
+		UserID:      userID,
+		Name:        req.Name,
+		Description: req.Description,
+		Metric:      req.Metric,
+		AmountType:  req.AmountType,
+		TargetValue: req.TargetValue,
+		Unit:        req.Unit,
+		StartDate:   startDate,
+		Deadline:    deadline,
+	}
+
+	if err := database.GetDB().Create(&goal).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, GoalView{Goal: goal, Progress: computeGoalProgress(&goal, 0, time.Now())})
+}
+
+func UpdateGoal(c *gin.Context) {
+	goal, ok := loadOwnedGoal(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateGoalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Name != "" {
+		updates["name"] = req.Name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.TargetValue != 0 {
+		updates["target_value"] = req.TargetValue
+	}
+	if req.Unit != nil {
+		updates["unit"] = *req.Unit
+	}
+	if req.Deadline != "" {
+		deadline, err := time.ParseInLocation(dayLayout, req.Deadline, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+			return
+		}
+		if deadline.Before(goal.StartDate) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "deadline must not be before start_date"})
+			return
+		}
+		updates["deadline"] = deadline
+	}
+	if req.Archived != nil {
+		updates["archived"] = *req.Archived
+	}
+
+	if err := database.GetDB().Model(goal).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	view, err := goalView(goal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, view)
+}
+
+// DeleteGoal 删除目标并解除计划和支出的关联
+func DeleteGoal(c *gin.Context) {
+	goal, ok := loadOwnedGoal(c)
+	if !ok {
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Plan{}).Where("user_id = ? AND goal_id = ?", goal.UserID, goal.ID).Update("goal_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Expense{}).Where("user_id = ? AND goal_id = ?", goal.UserID, goal.ID).Update("goal_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(goal).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "goal deleted"})
+}
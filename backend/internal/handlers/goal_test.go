@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"daily-planner-backend/internal/models"
+
+	"gorm.io/gorm"
+	"pgregory.net/rapid"
+)
+
+func TestComputeGoalProgress(t *testing.T) {
+	goal := models.Goal{
+		TargetValue: 30,
+		StartDate:   time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local),
+		Deadline:    time.Date(2024, 3, 30, 0, 0, 0, 0, time.Local),
+	}
+	// 第 16 天开始时已过去一半
+	halfway := time.Date(2024, 3, 16, 0, 0, 0, 0, time.Local)
+
+	onPace := computeGoalProgress(&goal, 15, halfway)
+	if onPace.Expected != 15 || onPace.Projected != 30 || onPace.AtRisk || onPace.Percent != 50 {
+		t.Errorf("Unexpected on-pace progress: %+v", onPace)
+	}
+
+	behind := computeGoalProgress(&goal, 10, halfway)
+	if !behind.AtRisk || behind.Projected != 20 {
+		t.Errorf("Expected goal behind pace to be at risk: %+v", behind)
+	}
+
+	overdue := computeGoalProgress(&goal, 29, time.Date(2024, 4, 2, 0, 0, 0, 0, time.Local))
+	if !overdue.AtRisk || overdue.DaysLeft != 0 {
+		t.Errorf("Expected unfinished goal past deadline to be at risk: %+v", overdue)
+	}
+}
+
+// **Feature: goals, Property 1: Achieved goals are never at risk and report 100%**
+func TestAchievedGoalNeverAtRisk(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		target := rapid.Float64Range(1, 10000).Draw(t, "target")
+		extra := rapid.Float64Range(0, 10000).Draw(t, "extra")
+		length := rapid.IntRange(0, 365).Draw(t, "length")
+		elapsed := rapid.IntRange(-30, 400).Draw(t, "elapsed")
+
+		start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+		goal := models.Goal{TargetValue: target, StartDate: start, Deadline: start.AddDate(0, 0, length)}
+
+		p := computeGoalProgress(&goal, target+extra, start.AddDate(0, 0, elapsed))
+		if !p.Achieved || p.AtRisk || p.Percent != 100 {
+			t.Fatalf("Achieved goal reported incorrectly: %+v", p)
+		}
+	})
+}
+
+func TestGoalAmountQueryFiltersType(t *testing.T) {
+	db, _ := dryRunDB(t)
+
+	// 同一目标下既有收入也有支出时，只累计目标跟踪的类型
+	cases := map[string]string{"": transactionExpense, transactionExpense: transactionExpense, transactionIncome: transactionIncome}
+	for amountType, want := range cases {
+		goal := models.Goal{ID: 3, UserID: 7, Metric: goalMetricAmount, AmountType: amountType}
+		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			var rows []models.Expense
+			return goalAmountQuery(tx, &goal).Find(&rows)
+		})
+		if !strings.Contains(sql, "user_id = 7 AND goal_id = 3 AND type = '"+want+"'") {
+			t.Errorf("Expected %q goal to sum %s rows only, got %s", amountType, want, sql)
+		}
+	}
+}
@@ -0,0 +1,457 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"daily-planner-backend/internal/database"
+	"daily-planner-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const dayLayout = "2006-01-02"
+
+type CreateHabitRequest struct {
+	Name          string  `json:"name" binding:"required,max=100"`
+	TargetPerWeek int     `json:"target_per_week" binding:"omitempty,min=1,max=7"`
+	DailyTarget   float64 `json:"daily_target" binding:"omitempty,gt=0"`
+	Unit          string  `json:"unit" binding:"max=20"`
+	GraceDays     int     `json:"grace_days" binding:"min=0,max=7"`
+}
+
+type UpdateHabitRequest struct {
+	Name          string  `json:"name" binding:"max=100"`
+	TargetPerWeek int     `json:"target_per_week" binding:"omitempty,min=1,max=7"`
+	DailyTarget   float64 `json:"daily_target" binding:"omitempty,gt=0"`
+	Unit          *string `json:"unit"`
+	GraceDays     *int    `json:"grace_days" binding:"omitempty,min=0,max=7"`
+	Archived      *bool   `json:"archived"`
+}
+
+type CheckInRequest struct {
+	Day      string  `json:"day"` // YYYY-MM-DD，缺省为今天
+	Quantity float64 `json:"quantity" binding:"omitempty,gt=0"`
+	Note     string  `json:"note" binding:"max=255"`
+}
+
+// HabitStats 习惯的连续打卡统计
+type HabitStats struct {
+	CurrentStreak  int  `json:"current_streak"`
+	LongestStreak  int  `json:"longest_streak"`
+	WeekStreak     int  `json:"week_streak"`
+	ThisWeekCount  int  `json:"this_week_count"`
+	CompletedToday bool `json:"completed_today"`
+}
+
+// HabitView 习惯及其统计
+type HabitView struct {
+	models.Habit
+	Stats HabitStats `json:"stats"`
+}
+
+func today() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// weekStart 返回所在周的周一
+func weekStart(day time.Time) time.Time {
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+func daysBetween(a, b time.Time) int {
+	return int(math.Round(b.Sub(a).Hours() / 24))
+}
+
+// habitMaxGap 连续打卡允许的最大中断天数：宽限天数加上每周目标允许的休息天数
+func habitMaxGap(habit *models.Habit) int {
+	return habit.GraceDays + (7 - habit.TargetPerWeek)
+}
+
+// computeHabitStats 根据达标日期计算连续天数和连续达标周数
+func computeHabitStats(completed []time.Time, targetPerWeek, maxGap int, now time.Time) HabitStats {
+	var stats HabitStats
+	if len(completed) == 0 {
+		return stats
+	}
+
+	days := append([]time.Time(nil), completed...)
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	run := 0
+	for i, d := range days {
+		if i > 0 && daysBetween(days[i-1], d)-1 > maxGap {
+			run = 0
+		}
+		run++
+		if run > stats.LongestStreak {
+			stats.LongestStreak = run
+		}
+	}
+
+	last := days[len(days)-1]
+	stats.CompletedToday = daysBetween(last, now) == 0
+	// 今天尚未打卡不算中断
+	if daysBetween(last, now)-1 <= maxGap {
+		stats.CurrentStreak = run
+	}
+
+	weekCounts := make(map[time.Time]int)
+	for _, d := range days {
+		weekCounts[weekStart(d)]++
+	}
+	week := weekStart(now)
+	stats.ThisWeekCount = weekCounts[week]
+	// 本周尚未达标时从上周开始计算
+	if stats.ThisWeekCount < targetPerWeek {
+		week = week.AddDate(0, 0, -7)
+	}
+	for weekCounts[week] >= targetPerWeek {
+		stats.WeekStreak++
+		week = week.AddDate(0, 0, -7)
+	}
+
+	return stats
+}
+
+// habitStats 读取打卡记录并计算统计
+func habitStats(habit *models.Habit) (HabitStats, error) {
+	var checkIns []models.HabitCheckIn
+	if err := database.GetDB().Where("habit_id = ? AND quantity >= ?", habit.ID, habit.DailyTarget).Find(&checkIns).Error; err != nil {
+		return HabitStats{}, err
+	}
+
+	completed := make([]time.Time, 0, len(checkIns))
+	for _, ci := range checkIns {
+		if d, err := time.ParseInLocation(dayLayout, ci.Day, time.Local); err == nil {
+			completed = append(completed, d)
+		}
+	}
+	return computeHabitStats(completed, habit.TargetPerWeek, habitMaxGap(habit), today()), nil
+}
+
+// loadOwnedHabit 读取习惯并校验归属，失败时已写入响应
+func loadOwnedHabit(c *gin.Context) (*models.Habit, bool) {
+	var habit models.Habit
+	if err := database.GetDB().First(&habit, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return nil, false
+	}
+
+	if habit.UserID != c.GetUint("userID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return nil, false
+	}
+
+	return &habit, true
+}
+
+func GetHabits(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	query := database.GetDB().Where("user_id = ?", userID)
+	if c.Query("archived") != "true" {
+		query = query.Where("archived = ?", false)
+	}
+
+	var habits []models.Habit
+	if err := query.Order("created_at ASC").Find(&habits).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	views := make([]HabitView, 0, len(habits))
+	for i := range habits {
+		stats, err := habitStats(&habits[i])
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		views = append(views, HabitView{Habit: habits[i], Stats: stats})
+	}
+
+	c.JSON(http.StatusOK, views)
+}
+
+func CreateHabit(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req CreateHabitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	habit := models.Habit{
+		UserID:        userID,
+		Name:          req.Name,
+		TargetPerWeek: req.TargetPerWeek,
+		DailyTarget:   req.DailyTarget,
+		Unit:          req.Unit,
+		GraceDays:     req.GraceDays,
+	}
+	if habit.TargetPerWeek == 0 {
+		habit.TargetPerWeek = 7
+	}
+	if habit.DailyTarget == 0 {
+		habit.DailyTarget = 1
+	}
+
+	if err := database.GetDB().Create(&habit).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, HabitView{Habit: habit})
+}
+
+func UpdateHabit(c *gin.Context) {
+	habit, ok := loadOwnedHabit(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateHabitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Name != "" {
+		updates["name"] = req.Name
+	}
+	if req.TargetPerWeek != 0 {
+		updates["target_per_week"] = req.TargetPerWeek
+	}
+	if req.DailyTarget != 0 {
+		updates["daily_target"] = req.DailyTarget
+	}
+	if req.Unit != nil {
+		updates["unit"] = *req.Unit
+	}
+	if req.GraceDays != nil {
+		updates["grace_days"] = *req.GraceDays
+	}
+	if req.Archived != nil {
+		updates["archived"] = *req.Archived
+	}
+
+	if err := database.GetDB().Model(habit).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	stats, err := habitStats(habit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, HabitView{Habit: *habit, Stats: stats})
+}
+
+func DeleteHabit(c *gin.Context) {
+	habit, ok := loadOwnedHabit(c)
+	if !ok {
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("habit_id = ?", habit.ID).Delete(&models.HabitCheckIn{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(habit).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "habit deleted"})
+}
+
+// CheckInHabit 打卡，同一天多次打卡累加数量
+func CheckInHabit(c *gin.Context) {
+	habit, ok := loadOwnedHabit(c)
+	if !ok {
+		return
+	}
+
+	var req CheckInRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	day := today()
+	if req.Day != "" {
+		d, err := time.ParseInLocation(dayLayout, req.Day, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+			return
+		}
+		if d.After(day) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cannot check in for a future date"})
+			return
+		}
+		day = d
+	}
+	quantity := req.Quantity
+	if quantity == 0 {
+		quantity = 1
+	}
+
+	var checkIn models.HabitCheckIn
+	err := database.GetDB().Where("habit_id = ? AND day = ?", habit.ID, day.Format(dayLayout)).First(&checkIn).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		checkIn = models.HabitCheckIn{
+			HabitID:  habit.ID,
+			UserID:   habit.UserID,
+			Day:      day.Format(dayLayout),
+			Quantity: quantity,
+			Note:     req.Note,
+		}
+		err = database.GetDB().Create(&checkIn).Error
+	case err == nil:
+		updates := map[string]interface{}{"quantity": gorm.Expr("quantity + ?", quantity)}
+		if req.Note != "" {
+			updates["note"] = req.Note
+		}
+		if err = database.GetDB().Model(&checkIn).Updates(updates).Error; err == nil {
+			err = database.GetDB().First(&checkIn, checkIn.ID).Error
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	stats, err := habitStats(habit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"check_in": checkIn,
+		"stats":    stats,
+	})
+}
+
+// DeleteHabitCheckIn 撤销某天的打卡
+func DeleteHabitCheckIn(c *gin.Context) {
+	habit, ok := loadOwnedHabit(c)
+	if !ok {
+		return
+	}
+
+	result := database.GetDB().Where("habit_id = ? AND day = ?", habit.ID, c.Param("day")).Delete(&models.HabitCheckIn{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "check-in deleted"})
+}
+
+func GetHabitCheckIns(c *gin.Context) {
+	habit, ok := loadOwnedHabit(c)
+	if !ok {
+		return
+	}
+
+	from, to, ok := parseDateRange(c, 30)
+	if !ok {
+		return
+	}
+
+	var checkIns []models.HabitCheckIn
+	if err := database.GetDB().Where("habit_id = ? AND day >= ? AND day < ?", habit.ID, from.Format(dayLayout), to.Format(dayLayout)).
+		Order("day ASC").Find(&checkIns).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, checkIns)
+}
+
+// GetHabitHeatmap 返回一年中每天的完成度（数量/每日目标，上限 1），按日期顺序排列
+func GetHabitHeatmap(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	year := today().Year()
+	if s := c.Query("year"); s != "" {
+		y, err := strconv.Atoi(s)
+		if err != nil || y < 1970 || y > 9999 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid year"})
+			return
+		}
+		year = y
+	}
+	start := time.Date(year, 1, 1, 0, 0, 0, 0, time.Local)
+	end := start.AddDate(1, 0, 0)
+
+	// 未指定习惯时取全部未归档习惯的平均完成度
+	var habits []models.Habit
+	if c.Param("id") != "" {
+		habit, ok := loadOwnedHabit(c)
+		if !ok {
+			return
+		}
+		habits = []models.Habit{*habit}
+	} else if err := database.GetDB().Where("user_id = ? AND archived = ?", userID, false).Find(&habits).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	values := make([]float64, daysBetween(start, end))
+	if len(habits) > 0 {
+		targets := make(map[uint]float64, len(habits))
+		ids := make([]uint, 0, len(habits))
+		for _, h := range habits {
+			targets[h.ID] = h.DailyTarget
+			ids = append(ids, h.ID)
+		}
+
+		var checkIns []models.HabitCheckIn
+		if err := database.GetDB().Where("habit_id IN ? AND day >= ? AND day < ?", ids, start.Format(dayLayout), end.Format(dayLayout)).
+			Find(&checkIns).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+
+		for _, ci := range checkIns {
+			d, err := time.ParseInLocation(dayLayout, ci.Day, time.Local)
+			if err != nil {
+				continue
+			}
+			value := ci.Quantity / targets[ci.HabitID]
+			if value > 1 {
+				value = 1
+			}
+			values[daysBetween(start, d)] += value / float64(len(habits))
+		}
+		for i, v := range values {
+			values[i] = math.Round(v*100) / 100
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"year":   year,
+		"start":  start.Format(dayLayout),
+		"values": values,
+	})
+}
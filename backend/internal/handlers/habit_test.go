@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"pgregory.net/rapid"
+)
+
+func habitDays(base time.Time, offsets ...int) []time.Time {
+	days := make([]time.Time, len(offsets))
+	for i, o := range offsets {
+		days[i] = base.AddDate(0, 0, o)
+	}
+	return days
+}
+
+func TestComputeHabitStats(t *testing.T) {
+	// 2024-03-13 是周三
+	now := time.Date(2024, 3, 13, 0, 0, 0, 0, time.Local)
+
+	testCases := []struct {
+		name      string
+		offsets   []int
+		target    int
+		maxGap    int
+		current   int
+		longest   int
+		weekCount int
+	}{
+		{"today not yet checked in", []int{-3, -2, -1}, 7, 0, 3, 3, 2},
+		{"gap breaks streak", []int{-5, -4, -2, -1, 0}, 7, 0, 3, 3, 3},
+		{"grace day bridges gap", []int{-5, -4, -2, -1, 0}, 7, 1, 5, 5, 3},
+		{"stale streak", []int{-10, -9, -8}, 7, 1, 0, 3, 0},
+		{"no check-ins", nil, 7, 0, 0, 0, 0},
+	}
+
+	for _, tc := range testCases {
+		stats := computeHabitStats(habitDays(now, tc.offsets...), tc.target, tc.maxGap, now)
+		if stats.CurrentStreak != tc.current || stats.LongestStreak != tc.longest || stats.ThisWeekCount != tc.weekCount {
+			t.Errorf("%s: expected current=%d longest=%d week=%d, got %+v", tc.name, tc.current, tc.longest, tc.weekCount, stats)
+		}
+	}
+}
+
+// **Feature: habits, Property 1: Meeting the weekly target every week yields a matching week streak**
+func TestHabitWeekStreak(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		target := rapid.IntRange(1, 7).Draw(t, "target")
+		weeks := rapid.IntRange(1, 10).Draw(t, "weeks")
+
+		// 本周一，本周尚未达标
+		monday := time.Date(2024, 3, 11, 0, 0, 0, 0, time.Local)
+		now := monday.AddDate(0, 0, 2)
+
+		var days []time.Time
+		for w := 1; w <= weeks; w++ {
+			for d := 0; d < target; d++ {
+				days = append(days, monday.AddDate(0, 0, -7*w+d))
+			}
+		}
+
+		stats := computeHabitStats(days, target, 7-target, now)
+		if stats.WeekStreak != weeks {
+			t.Fatalf("Expected week streak %d, got %d", weeks, stats.WeekStreak)
+		}
+		if stats.LongestStreak < target {
+			t.Fatalf("Longest streak %d shorter than one week's target %d", stats.LongestStreak, target)
+		}
+	})
+}
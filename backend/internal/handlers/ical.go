@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"daily-planner-backend/internal/models"
+)
+
+const icalProdID = "-//Daily Planner//Plans//ZH"
+
+// icalPlan 从 iCalendar 数据中解析出的计划字段
+type icalPlan struct {
+	UID           string
+	Summary       string
+	ExecutionDate time.Time
+	Status        string // 为空表示日历数据未携带状态
+}
+
+// planCalendarUID 返回计划在日历中的 UID，未通过 CalDAV 创建的计划使用生成值
+func planCalendarUID(plan *models.Plan) string {
+	if plan.CalendarUID != "" {
+		return plan.CalendarUID
+	}
+	return fmt.Sprintf("plan-%d@daily-planner", plan.ID)
+}
+
+// encodePlanICal 将计划编码为包含单个全天 VEVENT 的 VCALENDAR
+func encodePlanICal(plan *models.Plan) string {
+	var b strings.Builder
+	writeICalLine(&b, "BEGIN:VCALENDAR")
+	writeICalLine(&b, "VERSION:2.0")
+	writeICalLine(&b, "PRODID:"+icalProdID)
+	writeICalLine(&b, "BEGIN:VEVENT")
+	writeICalLine(&b, "UID:"+escapeICalText(planCalendarUID(plan)))
+	writeICalLine(&b, "DTSTAMP:"+plan.UpdatedAt.UTC().Format("20060102T150405Z"))
+	writeICalLine(&b, "LAST-MODIFIED:"+plan.UpdatedAt.UTC().Format("20060102T150405Z"))
+	writeICalLine(&b, "DTSTART;VALUE=DATE:"+plan.ExecutionDate.Format("20060102"))
+	writeICalLine(&b, "DTEND;VALUE=DATE:"+plan.ExecutionDate.AddDate(0, 0, 1).Format("20060102"))
+	writeICalLine(&b, "SUMMARY:"+escapeICalText(plan.Content))
+	writeICalLine(&b, "X-PLANNER-STATUS:"+escapeICalText(plan.Status))
+	writeICalLine(&b, "END:VEVENT")
+	writeICalLine(&b, "END:VCALENDAR")
+	return b.String()
+}
+
+// writeICalLine 按 RFC 5545 在 75 字节处折行，且不拆分 UTF-8 字符
+func writeICalLine(b *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+func escapeICalText(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+func unescapeICalText(s string) string {
+	r := strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+	return r.Replace(s)
+}
+
+// unfoldICalLines 展开折行并拆分为逻辑行
+func unfoldICalLines(data string) []string {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	var lines []string
+	for _, raw := range strings.Split(data, "\n") {
+		if (strings.HasPrefix(raw, " ") || strings.HasPrefix(raw, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += raw[1:]
+			continue
+		}
+		if raw != "" {
+			lines = append(lines, raw)
+		}
+	}
+	return lines
+}
+
+// splitICalProperty 将 "NAME;PARAM=V:VALUE" 拆分为大写名称、参数和值
+func splitICalProperty(line string) (name string, params map[string]string, value string) {
+	colon := strings.Index(line, ":")
+	if colon < 0 {
+		return strings.ToUpper(line), nil, ""
+	}
+	head, value := line[:colon], line[colon+1:]
+	parts := strings.Split(head, ";")
+	params = make(map[string]string)
+	for _, p := range parts[1:] {
+		if eq := strings.Index(p, "="); eq >= 0 {
+			params[strings.ToUpper(p[:eq])] = strings.Trim(p[eq+1:], `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, value
+}
+
+// parseICalDate 解析 DATE 或 DATE-TIME，返回服务器本地时区下的日期
+func parseICalDate(value string, params map[string]string) (time.Time, error) {
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		return time.Parse("20060102", value)
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return time.Time{}, err
+		}
+		local := t.Local()
+		return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC), nil
+	}
+	if len(value) < 8 {
+		return time.Time{}, errors.New("invalid date value")
+	}
+	// 浮动时间或带 TZID 的时间直接取日期部分
+	return time.Parse("20060102", value[:8])
+}
+
+// parsePlanICal 解析客户端上传的日历对象，接受 VEVENT 或 VTODO
+func parsePlanICal(data string) (*icalPlan, error) {
+	var (
+		result    icalPlan
+		component string
+		hasDate   bool
+	)
+
+	for _, line := range unfoldICalLines(data) {
+		name, params, value := splitICalProperty(line)
+		switch name {
+		case "BEGIN":
+			v := strings.ToUpper(value)
+			if component == "" && (v == "VEVENT" || v == "VTODO") {
+				component = v
+			} else if component != "" {
+				// 忽略 VALARM 等嵌套组件
+				component = component + "/" + v
+			}
+			continue
+		case "END":
+			if i := strings.LastIndex(component, "/"); i >= 0 {
+				component = component[:i]
+			} else if component != "" {
+				return finishICalPlan(&result, hasDate)
+			}
+			continue
+		}
+
+		if component != "VEVENT" && component != "VTODO" {
+			continue
+		}
+
+		switch name {
+		case "UID":
+			result.UID = unescapeICalText(value)
+		case "SUMMARY":
+			result.Summary = unescapeICalText(value)
+		case "DTSTART", "DUE":
+			if name == "DUE" && hasDate {
+				continue
+			}
+			date, err := parseICalDate(value, params)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", name, err)
+			}
+			result.ExecutionDate = date
+			hasDate = true
+		case "X-PLANNER-STATUS":
+			result.Status = unescapeICalText(value)
+		case "STATUS":
+			if component == "VTODO" && result.Status == "" {
+				if strings.EqualFold(value, "COMPLETED") {
+					result.Status = "completed"
+				} else {
+					result.Status = "pending"
+				}
+			}
+		}
+	}
+
+	return nil, errors.New("no VEVENT or VTODO component found")
+}
+
+func finishICalPlan(result *icalPlan, hasDate bool) (*icalPlan, error) {
+	if result.UID == "" {
+		return nil, errors.New("missing UID")
+	}
+	if !hasDate {
+		return nil, errors.New("missing DTSTART")
+	}
+	if strings.TrimSpace(result.Summary) == "" {
+		return nil, errors.New("missing SUMMARY")
+	}
+	return result, nil
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"daily-planner-backend/internal/models"
+
+	"pgregory.net/rapid"
+)
+
+// **Feature: caldav, Property 1: iCalendar round trip preserves plan fields**
+func TestPlanICalRoundTrip(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		content := rapid.StringMatching(`[a-zA-Z0-9 ,;\\\n\x{4e00}-\x{9fa5}]{1,120}`).Draw(t, "content")
+		if strings.TrimSpace(content) == "" {
+			content = "计划"
+		}
+		days := rapid.IntRange(0, 3650).Draw(t, "days")
+		status := rapid.SampledFrom([]string{"pending", "completed"}).Draw(t, "status")
+
+		plan := models.Plan{
+			ID:            rapid.UintRange(1, 100000).Draw(t, "id"),
+			Content:       content,
+			ExecutionDate: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, days),
+			Status:        status,
+			UpdatedAt:     time.Now(),
+		}
+
+		data := encodePlanICal(&plan)
+
+		// Property: no physical line exceeds 75 octets
+		for _, line := range strings.Split(strings.TrimSuffix(data, "\r\n"), "\r\n") {
+			if len(line) > 75 {
+				t.Fatalf("line longer than 75 octets: %q", line)
+			}
+		}
+
+		parsed, err := parsePlanICal(data)
+		if err != nil {
+			t.Fatalf("Failed to parse encoded plan: %v", err)
+		}
+		if parsed.Summary != plan.Content {
+			t.Fatalf("Summary mismatch: expected %q, got %q", plan.Content, parsed.Summary)
+		}
+		if !parsed.ExecutionDate.Equal(plan.ExecutionDate) {
+			t.Fatalf("Date mismatch: expected %v, got %v", plan.ExecutionDate, parsed.ExecutionDate)
+		}
+		if parsed.Status != plan.Status {
+			t.Fatalf("Status mismatch: expected %q, got %q", plan.Status, parsed.Status)
+		}
+		if parsed.UID != planCalendarUID(&plan) {
+			t.Fatalf("UID mismatch: expected %q, got %q", planCalendarUID(&plan), parsed.UID)
+		}
+	})
+}
+
+func TestParsePlanICalFromClients(t *testing.T) {
+	testCases := []struct {
+		name   string
+		data   string
+		date   string
+		status string
+	}{
+		{
+			name: "thunderbird vtodo",
+			data: "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nUID:abc-1\r\nSUMMARY:买菜\r\nDUE;VALUE=DATE:20240305\r\nSTATUS:COMPLETED\r\nEND:VTODO\r\nEND:VCALENDAR\r\n",
+			date: "2024-03-05", status: "completed",
+		},
+		{
+			name: "ios vevent with alarm",
+			data: "BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:ios-2\nDTSTART;TZID=Asia/Shanghai:20240306T090000\nSUMMARY:Dentist\nBEGIN:VALARM\nTRIGGER:-PT15M\nEND:VALARM\nEND:VEVENT\nEND:VCALENDAR\n",
+			date: "2024-03-06", status: "",
+		},
+		{
+			name: "folded summary",
+			data: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:f-3\r\nDTSTART;VALUE=DATE:20240307\r\nSUMMARY:Taxi to\r\n  airport\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+			date: "2024-03-07", status: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		parsed, err := parsePlanICal(tc.data)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if got := parsed.ExecutionDate.Format("2006-01-02"); got != tc.date {
+			t.Errorf("%s: expected date %s, got %s", tc.name, tc.date, got)
+		}
+		if parsed.Status != tc.status {
+			t.Errorf("%s: expected status %q, got %q", tc.name, tc.status, parsed.Status)
+		}
+	}
+
+	if _, err := parsePlanICal("BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"); err == nil {
+		t.Error("Expected error for calendar without components")
+	}
+}
@@ -0,0 +1,435 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"daily-planner-backend/internal/database"
+	"daily-planner-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"gorm.io/gorm"
+)
+
+// 账单来源
+const (
+	billSourceAlipay = "alipay"
+	billSourceWechat = "wechat"
+)
+
+var errUnknownBillFormat = errors.New("unrecognized bill format, expected Alipay or WeChat Pay CSV export")
+
+// billColumns 账单表头别名，兼容支付宝新旧版和微信支付的导出格式
+var billColumns = map[string][]string{
+	"time":      {"交易时间", "付款时间", "交易创建时间"},
+	"amount":    {"金额", "金额(元)", "金额（元）"},
+	"direction": {"收/支"},
+	"merchant":  {"交易对方"},
+	"note":      {"商品说明", "商品", "商品名称"},
+	"id":        {"交易订单号", "交易单号", "交易号"},
+	"status":    {"交易状态", "当前状态"},
+	"category":  {"交易分类"},
+}
+
+// billSkipStatuses 未实际扣款的交易状态
+var billSkipStatuses = []string{"关闭", "失败", "全额退款", "撤销"}
+
+// alipayCategories 支付宝交易分类到默认分类的映射
+var alipayCategories = map[string]string{
+	"餐饮美食": "餐饮",
+	"交通出行": "交通",
+	"爱车养车": "交通",
+	"服饰装扮": "购物",
+	"日用百货": "购物",
+	"数码电器": "购物",
+	"家居家装": "购物",
+	"美容美发": "购物",
+	"母婴亲子": "购物",
+	"文化休闲": "娱乐",
+	"休闲娱乐": "娱乐",
+	"运动户外": "娱乐",
+}
+
+// categoryKeywords 按商户名或商品说明猜测分类的关键字
+var categoryKeywords = []struct {
+	category string
+	keywords []string
+}{
+	{"餐饮", []string{"餐", "饭", "食", "面馆", "咖啡", "奶茶", "茶饮", "美团", "饿了么", "肯德基", "麦当劳", "星巴克", "瑞幸", "喜茶"}},
+	{"交通", []string{"滴滴", "地铁", "公交", "出行", "打车", "高德", "铁路", "12306", "加油", "停车", "航空", "高速"}},
+	{"娱乐", []string{"电影", "影城", "游戏", "音乐", "视频", "KTV", "爱奇艺", "腾讯视频", "哔哩哔哩", "bilibili", "网易云"}},
+	{"购物", []string{"淘宝", "天猫", "京东", "拼多多", "超市", "便利店", "商城", "商场", "唯品会"}},
+}
+
+// importedExpense 从账单解析出的一笔支出
+type importedExpense struct {
+	ExternalID string    `json:"external_id"`
+	SpentAt    time.Time `json:"spent_at"`
+	Amount     float64   `json:"amount"`
+	Category   string    `json:"category"`
+	Merchant   string    `json:"merchant"`
+	Note       string    `json:"note"`
+	Tags       string    `json:"tags"`
+	Duplicate  bool      `json:"duplicate"`
+}
+
+// decodeBill 将账单内容转换为 UTF-8，非 UTF-8 内容按 GBK（GB18030）解码
+func decodeBill(data []byte) (string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if utf8.Valid(data) {
+		return string(data), nil
+	}
+	decoded, err := simplifiedchinese.GB18030.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", err
+	}
+	return string(decoded), nil
+}
+
+// cleanBillCell 去掉导出文件中单元格两侧的空白和制表符，"/" 表示无内容
+func cleanBillCell(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "/" {
+		return ""
+	}
+	return s
+}
+
+func parseBillTime(s string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006/1/2 15:04:05", "2006/1/2 15:04"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("invalid time")
+}
+
+func parseBillAmount(s string) (float64, error) {
+	s = strings.NewReplacer("¥", "", "￥", "", ",", "", " ", "").Replace(s)
+	return strconv.ParseFloat(s, 64)
+}
+
+// guessCategory 根据支付宝交易分类或商户名、商品说明中的关键字猜测分类
+func guessCategory(platformCategory, merchant, note string) string {
+	if c, ok := alipayCategories[platformCategory]; ok {
+		return c
+	}
+	text := strings.ToLower(merchant + " " + note)
+	for _, rule := range categoryKeywords {
+		for _, kw := range rule.keywords {
+			if strings.Contains(text, strings.ToLower(kw)) {
+				return rule.category
+			}
+		}
+	}
+	return fallbackCategory
+}
+
+// parseBill 解析支付宝或微信支付导出的账单，只保留成功的支出记录；
+// skipped 为无法解析或不计入支出的交易行数
+func parseBill(data []byte) (source string, rows []importedExpense, skipped int, err error) {
+	text, err := decodeBill(data)
+	if err != nil {
+		return "", nil, 0, errUnknownBillFormat
+	}
+
+	// 表头之前是账单说明，找到同时包含“收/支”和“金额”的表头行
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	start := -1
+	for i, line := range lines {
+		if strings.Contains(line, "收/支") && strings.Contains(line, "金额") {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return "", nil, 0, errUnknownBillFormat
+	}
+
+	reader := csv.NewReader(strings.NewReader(strings.Join(lines[start:], "\n")))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return "", nil, 0, errUnknownBillFormat
+	}
+	cols := make(map[string]int)
+	for i, h := range header {
+		h = cleanBillCell(h)
+		for key, aliases := range billColumns {
+			for priority, alias := range aliases {
+				if h != alias {
+					continue
+				}
+				// 多个别名同时存在时取靠前的
+				if prev, ok := cols[key]; !ok || priority < aliasPriority(key, header[prev]) {
+					cols[key] = i
+				}
+			}
+		}
+	}
+	for _, key := range []string{"time", "amount", "direction"} {
+		if _, ok := cols[key]; !ok {
+			return "", nil, 0, errUnknownBillFormat
+		}
+	}
+
+	source = billSourceAlipay
+	if _, ok := cols["status"]; ok && cleanBillCell(header[cols["status"]]) == "当前状态" {
+		source = billSourceWechat
+	}
+
+	cell := func(record []string, key string) string {
+		i, ok := cols[key]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return cleanBillCell(record[i])
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", nil, 0, errUnknownBillFormat
+		}
+		// 账单末尾的分隔线和汇总行
+		if len(record) <= cols["amount"] {
+			continue
+		}
+
+		if cell(record, "direction") != "支出" {
+			skipped++
+			continue
+		}
+		status := cell(record, "status")
+		if containsAny(status, billSkipStatuses) {
+			skipped++
+			continue
+		}
+
+		spentAt, err := parseBillTime(cell(record, "time"))
+		if err != nil {
+			skipped++
+			continue
+		}
+		amount, err := parseBillAmount(cell(record, "amount"))
+		if err != nil || amount <= 0 {
+			skipped++
+			continue
+		}
+
+		merchant := cell(record, "merchant")
+		note := cell(record, "note")
+		rows = append(rows, importedExpense{
+			ExternalID: cell(record, "id"),
+			SpentAt:    spentAt,
+			Amount:     amount,
+			Category:   guessCategory(cell(record, "category"), merchant, note),
+			Merchant:   truncateRunes(merchant, 100),
+			Note:       note,
+		})
+	}
+
+	return source, rows, skipped, nil
+}
+
+func aliasPriority(key, header string) int {
+	header = cleanBillCell(header)
+	for i, alias := range billColumns[key] {
+		if alias == header {
+			return i
+		}
+	}
+	return len(billColumns[key])
+}
+
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
+
+// markImportDuplicates 标记已导入过或文件内重复的交易号
+func markImportDuplicates(userID uint, source string, rows []importedExpense) error {
+	ids := make([]string, 0, len(rows))
+	for _, r := range rows {
+		if r.ExternalID != "" {
+			ids = append(ids, source+":"+r.ExternalID)
+		}
+	}
+
+	existing := make(map[string]bool, len(ids))
+	for i := 0; i < len(ids); i += 500 {
+		end := i + 500
+		if end > len(ids) {
+			end = len(ids)
+		}
+		var found []string
+		err := database.GetDB().Model(&models.Expense{}).
+			Where("user_id = ? AND external_id IN ?", userID, ids[i:end]).
+			Pluck("external_id", &found).Error
+		if err != nil {
+			return err
+		}
+		for _, id := range found {
+			existing[id] = true
+		}
+	}
+
+	for i := range rows {
+		if rows[i].ExternalID == "" {
+			continue
+		}
+		key := source + ":" + rows[i].ExternalID
+		rows[i].Duplicate = existing[key]
+		existing[key] = true
+	}
+	return nil
+}
+
+// ImportExpenses 导入支付宝/微信支付账单（multipart 字段 file），dry_run=true 时只返回预览，
+// account_id 指定导入支出的付款账户
+func ImportExpenses(c *gin.Context) {
+	userID := c.GetUint("userID")
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, uploadMaxBytes+1<<20)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": "multipart field file is required"})
+		return
+	}
+	if header.Size > uploadMaxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file too large", "max_bytes": uploadMaxBytes})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file"})
+		return
+	}
+
+	var accountID *uint
+	if s := c.PostForm("account_id"); s != "" {
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account_id"})
+			return
+		}
+		if !validateAccountLink(c, userID, uint(id)) {
+			return
+		}
+		if id != 0 {
+			v := uint(id)
+			accountID = &v
+		}
+	}
+
+	source, rows, skipped, err := parseBill(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := markImportDuplicates(userID, source, rows); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	// 用户的分类规则优先于按关键字猜测的分类
+	rules, err := loadCategoryRules(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	for i := range rows {
+		if rule, ok := matchRule(rules, transactionExpense, rows[i].Note, rows[i].Merchant, rows[i].Amount); ok {
+			rows[i].Category = rule.Category
+			rows[i].Tags = rule.Tags
+		}
+	}
+
+	expenses := make([]models.Expense, 0, len(rows))
+	for _, r := range rows {
+		if r.Duplicate {
+			continue
+		}
+		expense := models.Expense{
+			UserID:    userID,
+			Amount:    r.Amount,
+			Currency:  domesticCurrency,
+			Category:  r.Category,
+			Note:      r.Note,
+			Merchant:  r.Merchant,
+			Tags:      r.Tags,
+			SpentAt:   r.SpentAt,
+			Source:    source,
+			AccountID: accountID,
+		}
+		if r.ExternalID != "" {
+			id := source + ":" + r.ExternalID
+			expense.ExternalID = &id
+		}
+		expenses = append(expenses, expense)
+	}
+
+	dryRun := c.Query("dry_run") == "true" || c.PostForm("dry_run") == "true"
+	if !dryRun && len(expenses) > 0 {
+		err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+			return tx.CreateInBatches(&expenses, 200).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+
+		// 每个分类和月份只需检查一次预算
+		checked := make(map[string]bool)
+		for _, e := range expenses {
+			key := e.Category + "|" + e.SpentAt.Format(monthLayout)
+			if !checked[key] {
+				checked[key] = true
+				checkBudgetThresholds(userID, e.Category, e.SpentAt)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"source":     source,
+		"dry_run":    dryRun,
+		"imported":   len(expenses),
+		"duplicates": len(rows) - len(expenses),
+		"skipped":    skipped,
+		"expenses":   rows,
+	})
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+const sampleAlipayBill = `------------------------------------------------------------------------------------
+导出信息：
+姓名：张三
+支付宝账户：zhangsan@example.com
+起始时间：[2024-03-01 00:00:00]    终止时间：[2024-03-31 23:59:59]
+导出交易类型：[全部]
+共4笔记录
+------------------------支付宝（中国）网络技术有限公司  电子客户回单------------------------
+交易时间,交易分类,交易对方,对方账号,商品说明,收/支,金额,收/付款方式,交易状态,交易订单号,商家订单号,备注,
+2024-03-05 12:30:45,餐饮美食,某某餐厅,/,午餐,支出,32.50,余额宝,交易成功,2024030522001412345678901234	,T2024030512304501	,,
+2024-03-06 09:10:00,日用百货,天猫超市,tmall@example.com,洗衣液,支出,59.90,花呗,交易成功,2024030622001412345678905678	,T2024030609100002	,,
+2024-03-07 18:00:00,转账红包,李四,lisi@example.com,转账,收入,100.00,,交易成功,2024030722001412345678909999	,,,
+2024-03-08 20:00:00,日用百货,某网店,/,拖鞋,支出,19.90,花呗,交易关闭,2024030822001412345678900000	,,,
+`
+
+const sampleWechatBill = "\xef\xbb\xbf" + `微信支付账单明细,,,,,,,,,,
+微信昵称：[张三],,,,,,,,,,
+起始时间：[2024-03-01 00:00:00] 终止时间：[2024-03-31 23:59:59],,,,,,,,,,
+,,,,,,,,,,
+----------------------微信支付账单明细列表--------------------,,,,,,,,,,
+交易时间,交易类型,交易对方,商品,收/支,金额(元),支付方式,当前状态,交易单号,商户单号,备注
+2024-03-09 08:15:20,商户消费,滴滴出行,"滴滴快车-张师傅",支出,¥25.80,零钱,支付成功,4200002024030912345678	,10000202403091234	,/
+2024-03-09 12:00:00,商户消费,瑞幸咖啡,生椰拿铁,支出,¥15.00,招商银行(1234),已全额退款,4200002024030912340000	,10000202403090000	,/
+2024-03-10 19:30:00,扫二维码付款,路边摊,/,支出,"¥1,008.00",零钱,已支付,10001071012024031000001	,/	,/
+2024-03-10 21:00:00,微信红包,王五,/,收入,¥8.88,/,已存入零钱,1000039501202403100001	,/	,/
+`
+
+func TestParseAlipayBillGBK(t *testing.T) {
+	encoded, err := simplifiedchinese.GBK.NewEncoder().String(sampleAlipayBill)
+	if err != nil {
+		t.Fatalf("Failed to encode sample: %v", err)
+	}
+
+	source, rows, skipped, err := parseBill([]byte(encoded))
+	if err != nil {
+		t.Fatalf("Failed to parse Alipay bill: %v", err)
+	}
+	if source != billSourceAlipay {
+		t.Errorf("Expected source alipay, got %s", source)
+	}
+	// 收入和已关闭的交易不计入
+	if len(rows) != 2 || skipped != 2 {
+		t.Fatalf("Expected 2 rows and 2 skipped, got %d rows and %d skipped", len(rows), skipped)
+	}
+
+	first := rows[0]
+	if first.ExternalID != "2024030522001412345678901234" || first.Amount != 32.5 || first.Merchant != "某某餐厅" ||
+		first.Note != "午餐" || first.Category != "餐饮" || first.SpentAt.Format("2006-01-02 15:04:05") != "2024-03-05 12:30:45" {
+		t.Errorf("Unexpected first row: %+v", first)
+	}
+	if rows[1].Category != "购物" {
+		t.Errorf("Expected 日用百货 to map to 购物, got %s", rows[1].Category)
+	}
+}
+
+func TestParseWechatBillUTF8(t *testing.T) {
+	source, rows, skipped, err := parseBill([]byte(sampleWechatBill))
+	if err != nil {
+		t.Fatalf("Failed to parse WeChat bill: %v", err)
+	}
+	if source != billSourceWechat {
+		t.Errorf("Expected source wechat, got %s", source)
+	}
+	if len(rows) != 2 || skipped != 2 {
+		t.Fatalf("Expected 2 rows and 2 skipped, got %d rows and %d skipped", len(rows), skipped)
+	}
+
+	if rows[0].Category != "交通" || rows[0].Amount != 25.8 || rows[0].ExternalID != "4200002024030912345678" {
+		t.Errorf("Unexpected first row: %+v", rows[0])
+	}
+	if rows[1].Amount != 1008 || rows[1].Note != "" || rows[1].Category != fallbackCategory {
+		t.Errorf("Unexpected second row: %+v", rows[1])
+	}
+}
+
+func TestParseBillRejectsUnknownFormat(t *testing.T) {
+	if _, _, _, err := parseBill([]byte("date,amount\n2024-03-01,10\n")); err != errUnknownBillFormat {
+		t.Errorf("Expected errUnknownBillFormat, got %v", err)
+	}
+}
+
+func TestGuessCategory(t *testing.T) {
+	cases := map[[3]string]string{
+		{"餐饮美食", "", ""}:            "餐饮",
+		{"", "美团外卖", ""}:            "餐饮",
+		{"", "某某公司", "Bilibili大会员"}: "娱乐",
+		{"", "某某公司", "服务费"}:         fallbackCategory,
+	}
+	for in, want := range cases {
+		if got := guessCategory(in[0], in[1], in[2]); got != want {
+			t.Errorf("guessCategory(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,499 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"daily-planner-backend/internal/database"
+	"daily-planner-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 分期计划状态
+const (
+	installmentActive    = "active"
+	installmentCompleted = "completed"
+	installmentSettled   = "settled"
+)
+
+type CreateInstallmentRequest struct {
+	Name      string  `json:"name" binding:"required,max=100"`
+	Principal float64 `json:"principal" binding:"required,gt=0"`
+	Currency  string  `json:"currency" binding:"omitempty,iso4217"` // 缺省为用户的本位币
+	Periods   int     `json:"periods" binding:"required,min=1,max=120"`
+	Fee       float64 `json:"fee" binding:"gte=0"`           // 手续费合计
+	FeeRate   float64 `json:"fee_rate" binding:"gte=0,lt=1"` // 每期手续费率（如 0.006），与 fee 二选一
+	Category  string  `json:"category" binding:"required,max=50"`
+	Merchant  string  `json:"merchant" binding:"max=100"`
+	Note      string  `json:"note" binding:"max=255"`
+	AccountID uint    `json:"account_id"`
+	StartDate string  `json:"start_date"` // 第一期还款日，缺省为今天
+}
+
+type UpdateInstallmentRequest struct {
+	Name      string  `json:"name" binding:"max=100"`
+	Category  string  `json:"category" binding:"max=50"`
+	Merchant  *string `json:"merchant" binding:"omitempty,max=100"`
+	Note      *string `json:"note" binding:"omitempty,max=255"`
+	AccountID *uint   `json:"account_id"` // 0 表示取消关联账户
+}
+
+type SettleInstallmentRequest struct {
+	Fee       *float64 `json:"fee" binding:"omitempty,gte=0"` // 提前结清收取的手续费，缺省为剩余各期手续费
+	SettledAt string   `json:"settled_at"`                    // 缺省为当前时间
+}
+
+// InstallmentPeriod 分期计划中的一期
+type InstallmentPeriod struct {
+	Period    int       `json:"period"` // 从 1 开始
+	DueDate   time.Time `json:"due_date"`
+	Principal float64   `json:"principal"`
+	Fee       float64   `json:"fee"`
+	Amount    float64   `json:"amount"`
+	Paid      bool      `json:"paid"`
+}
+
+// InstallmentPlanView 分期计划及剩余待还金额，已结清或还完的计划剩余为 0
+type InstallmentPlanView struct {
+	models.InstallmentPlan
+	PeriodAmount       float64             `json:"period_amount"` // 每期还款额（尾差计入最后一期）
+	Total              float64             `json:"total"`         // 本金与手续费合计
+	RemainingPrincipal float64             `json:"remaining_principal"`
+	RemainingFee       float64             `json:"remaining_fee"`
+	Remaining          float64             `json:"remaining"`
+	RemainingPeriods   int                 `json:"remaining_periods"`
+	Schedule           []InstallmentPeriod `json:"schedule,omitempty"`
+}
+
+// installmentDueDate 第 n 期（从 0 开始）的还款日，每月与第一期同一天，短月取月末
+func installmentDueDate(plan *models.InstallmentPlan, n int) time.Time {
+	return recurringOccurrence(&models.RecurringExpense{StartDate: plan.StartDate, Interval: recurringMonthly, IntervalCount: 1}, n)
+}
+
+// installmentSchedule 还款计划：本金和手续费按期数均摊到分，尾差计入最后一期
+func installmentSchedule(plan *models.InstallmentPlan) []InstallmentPeriod {
+	if plan.Periods < 1 {
+		return nil
+	}
+	principal, fee := toCents(plan.Principal), toCents(plan.Fee)
+	n := int64(plan.Periods)
+
+	schedule := make([]InstallmentPeriod, plan.Periods)
+	for i := range schedule {
+		p, f := principal/n, fee/n
+		if i == plan.Periods-1 {
+			p, f = principal-p*(n-1), fee-f*(n-1)
+		}
+		schedule[i] = InstallmentPeriod{
+			Period:    i + 1,
+			DueDate:   installmentDueDate(plan, i),
+			Principal: fromCents(p),
+			Fee:       fromCents(f),
+			Amount:    fromCents(p + f),
+			Paid:      i < plan.PaidPeriods || plan.Status == installmentSettled,
+		}
+	}
+	return schedule
+}
+
+// buildInstallmentView 计算每期金额和剩余待还，withSchedule 时附带完整还款计划
+func buildInstallmentView(plan models.InstallmentPlan, withSchedule bool) InstallmentPlanView {
+	schedule := installmentSchedule(&plan)
+	view := InstallmentPlanView{
+		InstallmentPlan: plan,
+		Total:           round2(plan.Principal + plan.Fee),
+	}
+	if len(schedule) > 0 {
+		view.PeriodAmount = schedule[0].Amount
+	}
+	if plan.Status == installmentActive {
+		var principal, fee int64
+		for _, p := range schedule[plan.PaidPeriods:] {
+			principal += toCents(p.Principal)
+			fee += toCents(p.Fee)
+		}
+		view.RemainingPrincipal = fromCents(principal)
+		view.RemainingFee = fromCents(fee)
+		view.Remaining = fromCents(principal + fee)
+		view.RemainingPeriods = plan.Periods - plan.PaidPeriods
+	}
+	if withSchedule {
+		view.Schedule = schedule
+	}
+	return view
+}
+
+// installmentExpense 分期计划生成的支出，externalKey 区分各期和提前结清
+func installmentExpense(plan *models.InstallmentPlan, externalKey string, amount float64, note string, spentAt time.Time) models.Expense {
+	externalID := fmt.Sprintf("installment:%d:%s", plan.ID, externalKey)
+	if plan.Note != "" {
+		note += " " + plan.Note
+	}
+	return models.Expense{
+		UserID:        plan.UserID,
+		Amount:        amount,
+		Currency:      plan.Currency,
+		Category:      plan.Category,
+		Merchant:      plan.Merchant,
+		Note:          truncateRunes(note, 255),
+		AccountID:     plan.AccountID,
+		SpentAt:       spentAt,
+		Source:        "installment",
+		ExternalID:    &externalID,
+		InstallmentID: &plan.ID,
+	}
+}
+
+// materializeInstallment 生成已到期各期的支出并推进还款进度，
+// 支出以 installment:<计划ID>:<期数> 作为外部交易号，重复执行不会重复生成
+func materializeInstallment(planID uint, now time.Time) error {
+	todayDate := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+	var created []models.Expense
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var plan models.InstallmentPlan
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&plan, planID).Error; err != nil {
+			return err
+		}
+		if plan.Status != installmentActive {
+			return nil
+		}
+
+		schedule := installmentSchedule(&plan)
+		paid := plan.PaidPeriods
+		for paid < plan.Periods && !schedule[paid].DueDate.After(todayDate) {
+			p := schedule[paid]
+			expense := installmentExpense(&plan, fmt.Sprint(p.Period), p.Amount,
+				fmt.Sprintf("%s 第%d/%d期", plan.Name, p.Period, plan.Periods), p.DueDate)
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&expense)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				created = append(created, expense)
+			}
+			paid++
+		}
+
+		updates := map[string]interface{}{"paid_periods": paid}
+		if paid >= plan.Periods {
+			updates["status"] = installmentCompleted
+		} else {
+			updates["next_due_date"] = schedule[paid].DueDate
+		}
+		return tx.Model(&plan).Updates(updates).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	checked := make(map[string]bool)
+	for _, e := range created {
+		key := e.SpentAt.Format(monthLayout)
+		if !checked[key] {
+			checked[key] = true
+			checkBudgetThresholds(e.UserID, e.Category, e.SpentAt)
+		}
+	}
+	return nil
+}
+
+// MaterializeDueInstallments 后台任务：生成所有已到期的分期还款
+func MaterializeDueInstallments(now time.Time) error {
+	var ids []uint
+	if err := database.GetDB().Model(&models.InstallmentPlan{}).
+		Where("status = ? AND next_due_date <= ?", installmentActive, now).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := materializeInstallment(id, now); err != nil {
+			log.Printf("Failed to materialize installment plan %d: %v", id, err)
+		}
+	}
+	return nil
+}
+
+func loadOwnedInstallment(c *gin.Context) (*models.InstallmentPlan, bool) {
+	var plan models.InstallmentPlan
+	if err := database.GetDB().First(&plan, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return nil, false
+	}
+
+	if plan.UserID != c.GetUint("userID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return nil, false
+	}
+
+	return &plan, true
+}
+
+// GetInstallments 分期计划列表，可按 status 过滤；remaining 为全部进行中计划的剩余待还，换算为本位币
+func GetInstallments(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	query := database.GetDB().Where("user_id = ?", userID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var plans []models.InstallmentPlan
+	if err := query.Order("status ASC, next_due_date ASC, id ASC").Find(&plans).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	currencies := make([]string, len(plans))
+	for i, p := range plans {
+		currencies[i] = p.Currency
+	}
+	conv, err := loadCurrencyConverter(userID, currencies...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	// 剩余待还按今天的汇率换算为本位币后合计
+	views := make([]InstallmentPlanView, 0, len(plans))
+	var remaining float64
+	for _, p := range plans {
+		v := buildInstallmentView(p, false)
+		if r, ok := conv.convert(v.Remaining, p.Currency, today()); ok {
+			remaining += r
+		}
+		views = append(views, v)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"installments":  views,
+		"remaining":     round2(remaining),
+		"currency":      conv.base,
+		"missing_rates": conv.missingCurrencies(),
+	})
+}
+
+// GetInstallment 分期计划详情，包含每期还款计划
+func GetInstallment(c *gin.Context) {
+	plan, ok := loadOwnedInstallment(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, buildInstallmentView(*plan, true))
+}
+
+func CreateInstallment(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req CreateInstallmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	if req.Fee > 0 && req.FeeRate > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "provide either fee or fee_rate, not both"})
+		return
+	}
+	if req.FeeRate > 0 {
+		req.Fee = round2(req.Principal * req.FeeRate * float64(req.Periods))
+	}
+
+	startDate := today()
+	if req.StartDate != "" {
+		d, err := time.ParseInLocation(dayLayout, req.StartDate, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+			return
+		}
+		startDate = d
+	}
+
+	if !validateAccountLink(c, userID, req.AccountID) {
+		return
+	}
+
+	if req.Currency == "" {
+		base, err := userBaseCurrency(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		req.Currency = base
+	}
+
+	plan := models.InstallmentPlan{
+		UserID:      userID,
+		Name:        req.Name,
+		Principal:   round2(req.Principal),
+		Currency:    req.Currency,
+		Periods:     req.Periods,
+		Fee:         round2(req.Fee),
+		Category:    req.Category,
+		Merchant:    req.Merchant,
+		Note:        req.Note,
+		StartDate:   startDate,
+		NextDueDate: startDate,
+		Status:      installmentActive,
+	}
+	if req.AccountID != 0 {
+		plan.AccountID = &req.AccountID
+	}
+
+	if err := database.GetDB().Create(&plan).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	// 第一期已到期时立即生成，不必等待后台任务
+	if !plan.StartDate.After(today()) {
+		if err := materializeInstallment(plan.ID, time.Now()); err != nil {
+			log.Printf("Failed to materialize installment plan %d: %v", plan.ID, err)
+		}
+		database.GetDB().First(&plan, plan.ID)
+	}
+
+	c.JSON(http.StatusCreated, buildInstallmentView(plan, true))
+}
+
+// UpdateInstallment 修改名称、分类等信息，本金、期数和手续费创建后不能修改
+func UpdateInstallment(c *gin.Context) {
+	plan, ok := loadOwnedInstallment(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateInstallmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Name != "" {
+		updates["name"] = req.Name
+	}
+	if req.Category != "" {
+		updates["category"] = req.Category
+	}
+	if req.Merchant != nil {
+		updates["merchant"] = *req.Merchant
+	}
+	if req.Note != nil {
+		updates["note"] = *req.Note
+	}
+	if req.AccountID != nil {
+		if !validateAccountLink(c, plan.UserID, *req.AccountID) {
+			return
+		}
+		if *req.AccountID == 0 {
+			updates["account_id"] = nil
+		} else {
+			updates["account_id"] = *req.AccountID
+		}
+	}
+
+	if err := database.GetDB().Model(plan).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, buildInstallmentView(*plan, false))
+}
+
+// DeleteInstallment 删除分期计划，已生成的支出保留但解除关联
+func DeleteInstallment(c *gin.Context) {
+	plan, ok := loadOwnedInstallment(c)
+	if !ok {
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Expense{}).Where("installment_id = ?", plan.ID).Update("installment_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(plan).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "installment plan deleted"})
+}
+
+// SettleInstallment 提前结清：先生成已到期的各期，再把剩余本金和手续费记为一笔支出
+func SettleInstallment(c *gin.Context) {
+	plan, ok := loadOwnedInstallment(c)
+	if !ok {
+		return
+	}
+
+	var req SettleInstallmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	settledAt := time.Now()
+	if req.SettledAt != "" {
+		t, err := parseSpentAt(req.SettledAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid settled_at, use RFC3339 or YYYY-MM-DD"})
+			return
+		}
+		settledAt = t
+	}
+
+	if err := materializeInstallment(plan.ID, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	var expense models.Expense
+	var view InstallmentPlanView
+	conflict := false
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(plan, plan.ID).Error; err != nil {
+			return err
+		}
+		if plan.Status != installmentActive {
+			conflict = true
+			return nil
+		}
+
+		view = buildInstallmentView(*plan, false)
+		fee := view.RemainingFee
+		if req.Fee != nil {
+			fee = round2(*req.Fee)
+		}
+		expense = installmentExpense(plan, "settle", round2(view.RemainingPrincipal+fee),
+			fmt.Sprintf("%s 提前结清（剩余%d期）", plan.Name, view.RemainingPeriods), settledAt)
+		if err := tx.Create(&expense).Error; err != nil {
+			return err
+		}
+		return tx.Model(plan).Updates(map[string]interface{}{"status": installmentSettled, "settled_at": settledAt}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if conflict {
+		c.JSON(http.StatusConflict, gin.H{"error": "installment plan is already paid off"})
+		return
+	}
+
+	checkBudgetThresholds(expense.UserID, expense.Category, expense.SpentAt)
+
+	c.JSON(http.StatusOK, gin.H{
+		"installment":       buildInstallmentView(*plan, false),
+		"expense":           expense,
+		"settled_principal": view.RemainingPrincipal,
+	})
+}
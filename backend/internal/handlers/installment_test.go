@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"daily-planner-backend/internal/models"
+)
+
+func TestInstallmentSchedule(t *testing.T) {
+	plan := models.InstallmentPlan{
+		Principal: 1000,
+		Fee:       10,
+		Periods:   3,
+		StartDate: time.Date(2024, 1, 31, 0, 0, 0, 0, time.Local),
+		Status:    installmentActive,
+	}
+
+	schedule := installmentSchedule(&plan)
+	if len(schedule) != 3 {
+		t.Fatalf("Expected 3 periods, got %d", len(schedule))
+	}
+
+	wantPrincipal := []float64{333.33, 333.33, 333.34}
+	wantFee := []float64{3.33, 3.33, 3.34}
+	wantDue := []string{"2024-01-31", "2024-02-29", "2024-03-31"}
+	for i, p := range schedule {
+		if p.Period != i+1 || p.Principal != wantPrincipal[i] || p.Fee != wantFee[i] || p.Amount != round2(wantPrincipal[i]+wantFee[i]) {
+			t.Errorf("Unexpected period %d: %+v", i+1, p)
+		}
+		if got := p.DueDate.Format(dayLayout); got != wantDue[i] {
+			t.Errorf("Period %d due %s, want %s", i+1, got, wantDue[i])
+		}
+	}
+}
+
+func TestBuildInstallmentView(t *testing.T) {
+	plan := models.InstallmentPlan{
+		Principal:   5999,
+		Fee:         431.93,
+		Periods:     12,
+		PaidPeriods: 4,
+		StartDate:   time.Date(2024, 3, 15, 0, 0, 0, 0, time.Local),
+		Status:      installmentActive,
+	}
+
+	view := buildInstallmentView(plan, true)
+	if view.PeriodAmount != 535.9 || view.Total != 6430.93 {
+		t.Errorf("Unexpected period amount or total: %+v", view)
+	}
+	// 剩余 8 期：本金 499.91*7+499.99，手续费 35.99*7+36.04
+	if view.RemainingPeriods != 8 || view.RemainingPrincipal != 3999.36 || view.RemainingFee != 287.97 || view.Remaining != 4287.33 {
+		t.Errorf("Unexpected remaining: %+v", view)
+	}
+	if len(view.Schedule) != 12 || !view.Schedule[3].Paid || view.Schedule[4].Paid {
+		t.Errorf("Unexpected paid flags in schedule")
+	}
+
+	plan.Status = installmentSettled
+	view = buildInstallmentView(plan, true)
+	if view.Remaining != 0 || view.RemainingPeriods != 0 || !view.Schedule[11].Paid {
+		t.Errorf("Expected settled plan to have nothing remaining: %+v", view)
+	}
+	if view := buildInstallmentView(plan, false); view.Schedule != nil {
+		t.Error("Expected schedule to be omitted")
+	}
+}
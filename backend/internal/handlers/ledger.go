@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"daily-planner-backend/internal/database"
+	"daily-planner-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CreateLedgerRequest struct {
+	Name     string `json:"name" binding:"required,max=100"`
+	Currency string `json:"currency" binding:"omitempty,iso4217"` // 缺省为所有者的本位币，创建后不能修改
+}
+
+type UpdateLedgerRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+type InviteLedgerMemberRequest struct {
+	Username string `json:"username" binding:"required"`
+}
+
+// LedgerView 账本及当前用户是否为所有者
+type LedgerView struct {
+	models.Ledger
+	IsOwner bool `json:"is_owner"`
+}
+
+// LedgerMemberView 账本成员，包括所有者
+type LedgerMemberView struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+	Status   string `json:"status"`
+	IsOwner  bool   `json:"is_owner"`
+}
+
+// LedgerInvitationView 待处理的账本邀请
+type LedgerInvitationView struct {
+	models.LedgerMember
+	LedgerName      string `json:"ledger_name"`
+	InviterUsername string `json:"inviter_username"`
+}
+
+// isLedgerMember 用户是否为账本所有者或已接受邀请的成员
+func isLedgerMember(ledger *models.Ledger, userID uint) (bool, error) {
+	if ledger.OwnerID == userID {
+		return true, nil
+	}
+	var count int64
+	err := database.GetDB().Model(&models.LedgerMember{}).
+		Where("ledger_id = ? AND user_id = ? AND status = ?", ledger.ID, userID, inviteStatusAccepted).Count(&count).Error
+	return count > 0, err
+}
+
+// ledgerMemberIDs 账本所有者和已接受邀请的成员 ID，所有者在前
+func ledgerMemberIDs(ledger *models.Ledger) ([]uint, error) {
+	var ids []uint
+	err := database.GetDB().Model(&models.LedgerMember{}).
+		Where("ledger_id = ? AND status = ?", ledger.ID, inviteStatusAccepted).
+		Order("id ASC").Pluck("user_id", &ids).Error
+	return append([]uint{ledger.OwnerID}, ids...), err
+}
+
+// canAccessLedgerExpense 支出的付款人以及支出所在账本的成员可以修改和删除支出
+func canAccessLedgerExpense(expense *models.Expense, userID uint) (bool, error) {
+	if expense.UserID == userID {
+		return true, nil
+	}
+	if expense.LedgerID == nil {
+		return false, nil
+	}
+	var ledger models.Ledger
+	if err := database.GetDB().First(&ledger, *expense.LedgerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return isLedgerMember(&ledger, userID)
+}
+
+// loadLedgerForMember 读取账本并校验当前用户是成员，失败时已写入响应
+func loadLedgerForMember(c *gin.Context, ledgerID interface{}) (*models.Ledger, bool) {
+	var ledger models.Ledger
+	if err := database.GetDB().First(&ledger, ledgerID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return nil, false
+	}
+
+	member, err := isLedgerMember(&ledger, c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return nil, false
+	}
+	if !member {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return nil, false
+	}
+
+	return &ledger, true
+}
+
+// loadLedgerForOwner 读取账本并校验当前用户是所有者，失败时已写入响应
+func loadLedgerForOwner(c *gin.Context) (*models.Ledger, bool) {
+	ledger, ok := loadLedgerForMember(c, c.Param("id"))
+	if !ok {
+		return nil, false
+	}
+	if ledger.OwnerID != c.GetUint("userID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return nil, false
+	}
+	return ledger, true
+}
+
+// GetLedgers 获取自己创建的和已加入的账本
+func GetLedgers(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var ledgers []models.Ledger
+	if err := database.GetDB().
+		Where("owner_id = ? OR id IN (?)", userID,
+			database.GetDB().Model(&models.LedgerMember{}).Select("ledger_id").Where("user_id = ? AND status = ?", userID, inviteStatusAccepted)).
+		Order("created_at ASC").Find(&ledgers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	views := make([]LedgerView, 0, len(ledgers))
+	for _, l := range ledgers {
+		views = append(views, LedgerView{Ledger: l, IsOwner: l.OwnerID == userID})
+	}
+
+	c.JSON(http.StatusOK, views)
+}
+
+func CreateLedger(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req CreateLedgerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	if req.Currency == "" {
+		base, err := userBaseCurrency(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		req.Currency = base
+	}
+
+	ledger := models.Ledger{
+		OwnerID:  userID,
+		Name:     req.Name,
+		Currency: req.Currency,
+	}
+
+	if err := database.GetDB().Create(&ledger).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, LedgerView{Ledger: ledger, IsOwner: true})
+}
+
+func UpdateLedger(c *gin.Context) {
+	ledger, ok := loadLedgerForOwner(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateLedgerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	if err := database.GetDB().Model(ledger).Update("name", req.Name).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, LedgerView{Ledger: *ledger, IsOwner: true})
+}
+
+// DeleteLedger 删除账本，支出保留给各自的付款人，分摊和结算记录一并删除
+func DeleteLedger(c *gin.Context) {
+	ledger, ok := loadLedgerForOwner(c)
+	if !ok {
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		expenseIDs := tx.Model(&models.Expense{}).Select("id").Where("ledger_id = ?", ledger.ID)
+		if err := tx.Where("expense_id IN (?)", expenseIDs).Delete(&models.ExpenseSplit{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Expense{}).Where("ledger_id = ?", ledger.ID).Update("ledger_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("ledger_id = ?", ledger.ID).Delete(&models.LedgerSettlement{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("ledger_id = ?", ledger.ID).Delete(&models.LedgerMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(ledger).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ledger deleted"})
+}
+
+// GetLedgerMembers 账本成员列表（含所有者和待接受的邀请）
+func GetLedgerMembers(c *gin.Context) {
+	ledger, ok := loadLedgerForMember(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var owner models.User
+	if err := database.GetDB().First(&owner, ledger.OwnerID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	var members []LedgerMemberView
+	if err := database.GetDB().Model(&models.LedgerMember{}).
+		Select("ledger_members.user_id, ledger_members.status, users.username").
+		Joins("JOIN users ON users.id = ledger_members.user_id").
+		Where("ledger_members.ledger_id = ? AND ledger_members.status <> ?", ledger.ID, inviteStatusDeclined).
+		Order("ledger_members.created_at ASC").
+		Scan(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	members = append([]LedgerMemberView{{UserID: owner.ID, Username: owner.Username, Status: inviteStatusAccepted, IsOwner: true}}, members...)
+	c.JSON(http.StatusOK, members)
+}
+
+// InviteLedgerMember 按用户名邀请成员，被拒绝过的邀请会重新发出
+func InviteLedgerMember(c *gin.Context) {
+	userID := c.GetUint("userID")
+	ledger, ok := loadLedgerForOwner(c)
+	if !ok {
+		return
+	}
+
+	var req InviteLedgerMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	var invitee models.User
+	if err := database.GetDB().Where("username = ?", req.Username).First(&invitee).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if invitee.ID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot invite yourself"})
+		return
+	}
+
+	var member models.LedgerMember
+	err := database.GetDB().Where("ledger_id = ? AND user_id = ?", ledger.ID, invitee.ID).First(&member).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		member = models.LedgerMember{
+			LedgerID:  ledger.ID,
+			UserID:    invitee.ID,
+			Status:    inviteStatusPending,
+			InvitedBy: userID,
+		}
+		err = database.GetDB().Create(&member).Error
+	case err == nil && member.Status == inviteStatusDeclined:
+		err = database.GetDB().Model(&member).Update("status", inviteStatusPending).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, LedgerMemberView{UserID: invitee.ID, Username: invitee.Username, Status: member.Status})
+}
+
+// RemoveLedgerMember 所有者移除成员，或成员自己退出账本；已有的分摊记录保留，结算时仍会计入
+func RemoveLedgerMember(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var ledger models.Ledger
+	if err := database.GetDB().First(&ledger, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return
+	}
+
+	var member models.LedgerMember
+	if err := database.GetDB().Where("ledger_id = ? AND user_id = ?", ledger.ID, c.Param("user_id")).First(&member).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return
+	}
+
+	if ledger.OwnerID != userID && member.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return
+	}
+
+	if err := database.GetDB().Delete(&member).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "member removed"})
+}
+
+// GetLedgerInvitations 当前用户待处理的账本邀请
+func GetLedgerInvitations(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var invitations []LedgerInvitationView
+	if err := database.GetDB().Model(&models.LedgerMember{}).
+		Select("ledger_members.*, ledgers.name AS ledger_name, users.username AS inviter_username").
+		Joins("JOIN ledgers ON ledgers.id = ledger_members.ledger_id").
+		Joins("JOIN users ON users.id = ledger_members.invited_by").
+		Where("ledger_members.user_id = ? AND ledger_members.status = ?", userID, inviteStatusPending).
+		Order("ledger_members.created_at DESC").
+		Scan(&invitations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+func AcceptLedgerInvitation(c *gin.Context) {
+	respondLedgerInvitation(c, inviteStatusAccepted)
+}
+
+func DeclineLedgerInvitation(c *gin.Context) {
+	respondLedgerInvitation(c, inviteStatusDeclined)
+}
+
+func respondLedgerInvitation(c *gin.Context, status string) {
+	userID := c.GetUint("userID")
+
+	var member models.LedgerMember
+	if err := database.GetDB().First(&member, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return
+	}
+
+	if member.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return
+	}
+
+	if member.Status != inviteStatusPending {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invitation already " + member.Status})
+		return
+	}
+
+	if err := database.GetDB().Model(&member).Update("status", status).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
@@ -0,0 +1,677 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"daily-planner-backend/internal/database"
+	"daily-planner-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 借款方向和状态
+const (
+	loanLend    = "lend"
+	loanBorrow  = "borrow"
+	loanOpen    = "open"
+	loanSettled = "settled"
+)
+
+// loanReminderType 借款逾期时自动创建的提醒类型
+const loanReminderType = "loan"
+
+type CreateLoanRequest struct {
+	Direction    string  `json:"direction" binding:"required,oneof=lend borrow"`
+	Counterparty string  `json:"counterparty" binding:"required,max=50"`
+	Amount       float64 `json:"amount" binding:"required,gt=0"`
+	Currency     string  `json:"currency" binding:"omitempty,iso4217"` // 缺省为用户的本位币，创建后不能修改
+	Note         string  `json:"note" binding:"max=255"`
+	LoanedAt     string  `json:"loaned_at"` // 缺省为当前时间
+	DueDate      string  `json:"due_date"`  // 可选，YYYY-MM-DD
+}
+
+type UpdateLoanRequest struct {
+	Counterparty string  `json:"counterparty" binding:"max=50"`
+	Amount       float64 `json:"amount" binding:"omitempty,gt=0"`
+	Note         *string `json:"note" binding:"omitempty,max=255"`
+	DueDate      *string `json:"due_date"` // 空字符串表示取消到期日
+}
+
+type CreateLoanRepaymentRequest struct {
+	Amount   float64 `json:"amount" binding:"required,gt=0"`
+	Note     string  `json:"note" binding:"max=255"`
+	RepaidAt string  `json:"repaid_at"` // 缺省为当前时间
+}
+
+// LoanView 借款及已还、未还金额
+type LoanView struct {
+	models.Loan
+	Repaid      float64 `json:"repaid"`
+	Outstanding float64 `json:"outstanding"`
+	Overdue     bool    `json:"overdue"`
+}
+
+// CounterpartyBalance 与某人之间未结清的借款（换算为本位币），net 为正表示对方欠自己
+type CounterpartyBalance struct {
+	Counterparty string  `json:"counterparty"`
+	Lent         float64 `json:"lent"`     // 借出未收回
+	Borrowed     float64 `json:"borrowed"` // 借入未归还
+	Net          float64 `json:"net"`
+	OpenLoans    int     `json:"open_loans"`
+	Overdue      int     `json:"overdue"`
+}
+
+// buildLoanView 计算未还金额，到期日已过且未结清视为逾期（到期日当天不算）
+func buildLoanView(loan models.Loan, repaid float64, now time.Time) LoanView {
+	outstanding := toCents(loan.Amount) - toCents(repaid)
+	if outstanding < 0 {
+		outstanding = 0
+	}
+	todayDate := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	return LoanView{
+		Loan:        loan,
+		Repaid:      round2(repaid),
+		Outstanding: fromCents(outstanding),
+		Overdue:     outstanding > 0 && loan.DueDate != nil && loan.DueDate.Before(todayDate),
+	}
+}
+
+// summarizeLoans 按对方姓名汇总未结清的借款，未还金额按 day 的汇率换算为本位币，缺少汇率的借款只计笔数；
+// 姓名忽略首尾空格，按净额绝对值降序
+func summarizeLoans(views []LoanView, conv *currencyConverter, day time.Time) []CounterpartyBalance {
+	type totals struct {
+		name           string
+		lent, borrowed int64
+		open, overdue  int
+	}
+	byName := make(map[string]*totals)
+	var order []string
+	for _, v := range views {
+		if v.Outstanding <= 0 {
+			continue
+		}
+		name := strings.TrimSpace(v.Counterparty)
+		t, ok := byName[name]
+		if !ok {
+			t = &totals{name: name}
+			byName[name] = t
+			order = append(order, name)
+		}
+		if amount, ok := conv.convert(v.Outstanding, v.Currency, day); ok {
+			if v.Direction == loanLend {
+				t.lent += toCents(amount)
+			} else {
+				t.borrowed += toCents(amount)
+			}
+		}
+		t.open++
+		if v.Overdue {
+			t.overdue++
+		}
+	}
+
+	balances := make([]CounterpartyBalance, 0, len(order))
+	for _, name := range order {
+		t := byName[name]
+		balances = append(balances, CounterpartyBalance{
+			Counterparty: name,
+			Lent:         fromCents(t.lent),
+			Borrowed:     fromCents(t.borrowed),
+			Net:          fromCents(t.lent - t.borrowed),
+			OpenLoans:    t.open,
+			Overdue:      t.overdue,
+		})
+	}
+	sort.SliceStable(balances, func(i, j int) bool {
+		a, b := toCents(balances[i].Net), toCents(balances[j].Net)
+		if a < 0 {
+			a = -a
+		}
+		if b < 0 {
+			b = -b
+		}
+		return a > b
+	})
+	return balances
+}
+
+// loanReminderContent 逾期提醒内容
+func loanReminderContent(view LoanView) string {
+	due := view.DueDate.Format(dayLayout)
+	if view.Direction == loanLend {
+		return fmt.Sprintf("借给%s的 %.2f 已于 %s 到期，尚未收回 %.2f", view.Counterparty, view.Amount, due, view.Outstanding)
+	}
+	return fmt.Sprintf("向%s借的 %.2f 已于 %s 到期，尚未归还 %.2f", view.Counterparty, view.Amount, due, view.Outstanding)
+}
+
+// loanRepaidTotals 统计各借款的已还金额
+func loanRepaidTotals(db *gorm.DB, loanIDs []uint) (map[uint]float64, error) {
+	totals := make(map[uint]float64)
+	if len(loanIDs) == 0 {
+		return totals, nil
+	}
+
+	var rows []struct {
+		LoanID uint
+		Total  float64
+	}
+	err := db.Model(&models.LoanRepayment{}).Select("loan_id, COALESCE(SUM(amount), 0) AS total").
+		Where("loan_id IN ?", loanIDs).Group("loan_id").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		totals[r.LoanID] = round2(r.Total)
+	}
+	return totals, nil
+}
+
+func loanView(db *gorm.DB, loan *models.Loan) (LoanView, error) {
+	totals, err := loanRepaidTotals(db, []uint{loan.ID})
+	if err != nil {
+		return LoanView{}, err
+	}
+	return buildLoanView(*loan, totals[loan.ID], time.Now()), nil
+}
+
+// syncLoanStatus 根据未还金额更新借款状态，结清时停用逾期提醒，重新产生欠款时恢复为未结清
+func syncLoanStatus(tx *gorm.DB, loan *models.Loan, now time.Time) error {
+	view, err := loanView(tx, loan)
+	if err != nil {
+		return err
+	}
+
+	updates := make(map[string]interface{})
+	if view.Outstanding == 0 && loan.Status != loanSettled {
+		updates["status"] = loanSettled
+		updates["settled_at"] = now
+		if loan.ReminderID != nil {
+			if err := tx.Model(&models.Reminder{}).Where("id = ?", *loan.ReminderID).Update("is_enabled", false).Error; err != nil {
+				return err
+			}
+		}
+	} else if view.Outstanding > 0 && loan.Status != loanOpen {
+		updates["status"] = loanOpen
+		updates["settled_at"] = nil
+		if loan.ReminderID != nil {
+			if err := tx.Model(&models.Reminder{}).Where("id = ?", *loan.ReminderID).Update("is_enabled", true).Error; err != nil {
+				return err
+			}
+		}
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return tx.Model(loan).Updates(updates).Error
+}
+
+// clearLoanReminder 删除借款的逾期提醒，修改到期日后重新判断是否逾期
+func clearLoanReminder(tx *gorm.DB, loan *models.Loan) error {
+	if loan.ReminderID == nil {
+		return nil
+	}
+	if err := tx.Where("id = ? AND user_id = ?", *loan.ReminderID, loan.UserID).Delete(&models.Reminder{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(loan).Update("reminder_id", nil).Error; err != nil {
+		return err
+	}
+	loan.ReminderID = nil
+	return nil
+}
+
+// remindOverdueLoan 为逾期未结清的借款创建提醒，每笔借款只创建一次
+func remindOverdueLoan(loanID uint, now time.Time) error {
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var loan models.Loan
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&loan, loanID).Error; err != nil {
+			return err
+		}
+		if loan.Status != loanOpen || loan.ReminderID != nil {
+			return nil
+		}
+		totals, err := loanRepaidTotals(tx, []uint{loan.ID})
+		if err != nil {
+			return err
+		}
+		view := buildLoanView(loan, totals[loan.ID], now)
+		if !view.Overdue {
+			return nil
+		}
+
+		reminder := models.Reminder{
+			UserID:        loan.UserID,
+			ReminderType:  loanReminderType,
+			ScheduledTime: now.Format("2006-01-02 15:04"),
+			Content:       loanReminderContent(view),
+			IsEnabled:     true,
+		}
+		if err := tx.Create(&reminder).Error; err != nil {
+			return err
+		}
+		return tx.Model(&loan).Update("reminder_id", reminder.ID).Error
+	})
+}
+
+// RemindOverdueLoans 由后台任务定期调用，为已过到期日仍未结清的借款创建提醒
+func RemindOverdueLoans(now time.Time) error {
+	todayDate := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+	var ids []uint
+	if err := database.GetDB().Model(&models.Loan{}).
+		Where("status = ? AND reminder_id IS NULL AND due_date < ?", loanOpen, todayDate).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := remindOverdueLoan(id, now); err != nil {
+			log.Printf("Failed to create reminder for overdue loan %d: %v", id, err)
+		}
+	}
+	return nil
+}
+
+func loadOwnedLoan(c *gin.Context) (*models.Loan, bool) {
+	var loan models.Loan
+	if err := database.GetDB().First(&loan, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return nil, false
+	}
+
+	if loan.UserID != c.GetUint("userID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return nil, false
+	}
+
+	return &loan, true
+}
+
+// loadLoanViews 查询借款并计算已还、未还金额
+func loadLoanViews(query *gorm.DB) ([]LoanView, error) {
+	var loans []models.Loan
+	if err := query.Order("status ASC, due_date IS NULL, due_date ASC, loaned_at DESC").Find(&loans).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, len(loans))
+	for i, l := range loans {
+		ids[i] = l.ID
+	}
+	totals, err := loanRepaidTotals(database.GetDB(), ids)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	views := make([]LoanView, len(loans))
+	for i, l := range loans {
+		views[i] = buildLoanView(l, totals[l.ID], now)
+	}
+	return views, nil
+}
+
+// GetLoans 借款列表，可按 direction、status、counterparty 过滤
+func GetLoans(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	query := database.GetDB().Where("user_id = ?", userID)
+	if direction := c.Query("direction"); direction != "" {
+		query = query.Where("direction = ?", direction)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if counterparty := strings.TrimSpace(c.Query("counterparty")); counterparty != "" {
+		query = query.Where("counterparty = ?", counterparty)
+	}
+
+	views, err := loadLoanViews(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, views)
+}
+
+// GetLoanSummary 按对方汇总未结清的借出和借入金额，按今天的汇率换算为本位币
+func GetLoanSummary(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	views, err := loadLoanViews(database.GetDB().Where("user_id = ? AND status = ?", userID, loanOpen))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	currencies := make([]string, len(views))
+	for i, v := range views {
+		currencies[i] = v.Currency
+	}
+	conv, err := loadCurrencyConverter(userID, currencies...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	balances := summarizeLoans(views, conv, today())
+	var lent, borrowed int64
+	for _, b := range balances {
+		lent += toCents(b.Lent)
+		borrowed += toCents(b.Borrowed)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"counterparties": balances,
+		"lent":           fromCents(lent),
+		"borrowed":       fromCents(borrowed),
+		"net":            fromCents(lent - borrowed),
+		"currency":       conv.base,
+		"missing_rates":  conv.missingCurrencies(),
+	})
+}
+
+// GetLoan 借款详情，附带还款记录
+func GetLoan(c *gin.Context) {
+	loan, ok := loadOwnedLoan(c)
+	if !ok {
+		return
+	}
+
+	view, err := loanView(database.GetDB(), loan)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	var repayments []models.LoanRepayment
+	if err := database.GetDB().Where("loan_id = ?", loan.ID).Order("repaid_at DESC, id DESC").Find(&repayments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"loan":       view,
+		"repayments": repayments,
+	})
+}
+
+func CreateLoan(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req CreateLoanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	counterparty := strings.TrimSpace(req.Counterparty)
+	if counterparty == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "counterparty is required"})
+		return
+	}
+
+	loanedAt := time.Now()
+	if req.LoanedAt != "" {
+		t, err := parseSpentAt(req.LoanedAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid loaned_at format"})
+			return
+		}
+		loanedAt = t
+	}
+
+	if req.Currency == "" {
+		base, err := userBaseCurrency(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		req.Currency = base
+	}
+
+	loan := models.Loan{
+		UserID:       userID,
+		Direction:    req.Direction,
+		Counterparty: counterparty,
+		Amount:       round2(req.Amount),
+		Currency:     req.Currency,
+		Note:         req.Note,
+		LoanedAt:     loanedAt,
+		Status:       loanOpen,
+	}
+	if req.DueDate != "" {
+		d, err := time.ParseInLocation(dayLayout, req.DueDate, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+			return
+		}
+		loan.DueDate = &d
+	}
+
+	if err := database.GetDB().Create(&loan).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, buildLoanView(loan, 0, time.Now()))
+}
+
+// UpdateLoan 修改借款，本金不能低于已还金额；修改到期日会删除已有的逾期提醒并重新判断
+func UpdateLoan(c *gin.Context) {
+	loan, ok := loadOwnedLoan(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateLoanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if counterparty := strings.TrimSpace(req.Counterparty); counterparty != "" {
+		updates["counterparty"] = counterparty
+	}
+	if req.Amount != 0 {
+		updates["amount"] = round2(req.Amount)
+	}
+	if req.Note != nil {
+		updates["note"] = *req.Note
+	}
+	if req.DueDate != nil {
+		if *req.DueDate == "" {
+			updates["due_date"] = nil
+		} else {
+			d, err := time.ParseInLocation(dayLayout, *req.DueDate, time.Local)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+				return
+			}
+			updates["due_date"] = d
+		}
+	}
+
+	belowRepaid := false
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(loan, loan.ID).Error; err != nil {
+			return err
+		}
+		if req.Amount != 0 {
+			totals, err := loanRepaidTotals(tx, []uint{loan.ID})
+			if err != nil {
+				return err
+			}
+			if toCents(req.Amount) < toCents(totals[loan.ID]) {
+				belowRepaid = true
+				return nil
+			}
+		}
+		if req.DueDate != nil {
+			if err := clearLoanReminder(tx, loan); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(loan).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.First(loan, loan.ID).Error; err != nil {
+			return err
+		}
+		return syncLoanStatus(tx, loan, time.Now())
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if belowRepaid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount must not be less than repaid amount"})
+		return
+	}
+
+	view, err := loanView(database.GetDB(), loan)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, view)
+}
+
+// DeleteLoan 删除借款及其还款记录和逾期提醒
+func DeleteLoan(c *gin.Context) {
+	loan, ok := loadOwnedLoan(c)
+	if !ok {
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("loan_id = ?", loan.ID).Delete(&models.LoanRepayment{}).Error; err != nil {
+			return err
+		}
+		if err := clearLoanReminder(tx, loan); err != nil {
+			return err
+		}
+		return tx.Delete(loan).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "loan deleted"})
+}
+
+// CreateLoanRepayment 记录一笔部分或全部还款，还清后借款自动结清
+func CreateLoanRepayment(c *gin.Context) {
+	loan, ok := loadOwnedLoan(c)
+	if !ok {
+		return
+	}
+
+	var req CreateLoanRepaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	repaidAt := time.Now()
+	if req.RepaidAt != "" {
+		t, err := parseSpentAt(req.RepaidAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid repaid_at format"})
+			return
+		}
+		repaidAt = t
+	}
+
+	repayment := models.LoanRepayment{
+		LoanID:   loan.ID,
+		UserID:   loan.UserID,
+		Amount:   round2(req.Amount),
+		Note:     req.Note,
+		RepaidAt: repaidAt,
+	}
+
+	exceeds := false
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(loan, loan.ID).Error; err != nil {
+			return err
+		}
+		view, err := loanView(tx, loan)
+		if err != nil {
+			return err
+		}
+		if toCents(repayment.Amount) > toCents(view.Outstanding) {
+			exceeds = true
+			return nil
+		}
+		if err := tx.Create(&repayment).Error; err != nil {
+			return err
+		}
+		return syncLoanStatus(tx, loan, time.Now())
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if exceeds {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "repayment exceeds outstanding amount"})
+		return
+	}
+
+	view, err := loanView(database.GetDB(), loan)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"repayment": repayment,
+		"loan":      view,
+	})
+}
+
+// DeleteLoanRepayment 删除一笔还款，已结清的借款恢复为未结清
+func DeleteLoanRepayment(c *gin.Context) {
+	loan, ok := loadOwnedLoan(c)
+	if !ok {
+		return
+	}
+
+	var repayment models.LoanRepayment
+	if err := database.GetDB().Where("loan_id = ?", loan.ID).First(&repayment, c.Param("repayment_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(loan, loan.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&repayment).Error; err != nil {
+			return err
+		}
+		return syncLoanStatus(tx, loan, time.Now())
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	view, err := loanView(database.GetDB(), loan)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, view)
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"daily-planner-backend/internal/models"
+
+	"pgregory.net/rapid"
+)
+
+func TestBuildLoanView(t *testing.T) {
+	due := time.Date(2024, 3, 10, 0, 0, 0, 0, time.Local)
+	loan := models.Loan{Direction: loanLend, Counterparty: "老王", Amount: 500, DueDate: &due}
+
+	onDueDate := buildLoanView(loan, 200, time.Date(2024, 3, 10, 22, 0, 0, 0, time.Local))
+	if onDueDate.Outstanding != 300 || onDueDate.Repaid != 200 || onDueDate.Overdue {
+		t.Errorf("Expected loan not to be overdue on its due date: %+v", onDueDate)
+	}
+
+	late := buildLoanView(loan, 200, time.Date(2024, 3, 11, 8, 0, 0, 0, time.Local))
+	if !late.Overdue {
+		t.Errorf("Expected loan to be overdue after its due date: %+v", late)
+	}
+
+	repaid := buildLoanView(loan, 500, time.Date(2024, 4, 1, 0, 0, 0, 0, time.Local))
+	if repaid.Outstanding != 0 || repaid.Overdue {
+		t.Errorf("Expected repaid loan not to be overdue: %+v", repaid)
+	}
+
+	noDue := buildLoanView(models.Loan{Direction: loanBorrow, Amount: 100}, 0, time.Now())
+	if noDue.Overdue || noDue.Outstanding != 100 {
+		t.Errorf("Expected loan without due date never to be overdue: %+v", noDue)
+	}
+}
+
+func TestSummarizeLoans(t *testing.T) {
+	views := []LoanView{
+		{Loan: models.Loan{Direction: loanLend, Counterparty: "老王"}, Outstanding: 300, Overdue: true},
+		{Loan: models.Loan{Direction: loanBorrow, Counterparty: " 老王 "}, Outstanding: 100},
+		{Loan: models.Loan{Direction: loanBorrow, Counterparty: "小李"}, Outstanding: 800.5},
+		{Loan: models.Loan{Direction: loanLend, Counterparty: "阿强"}},
+	}
+
+	balances := summarizeLoans(views, newCurrencyConverter("CNY", nil), time.Now())
+	if len(balances) != 2 {
+		t.Fatalf("Expected 2 counterparties with open loans, got %+v", balances)
+	}
+	if b := balances[0]; b.Counterparty != "小李" || b.Borrowed != 800.5 || b.Net != -800.5 {
+		t.Errorf("Unexpected first balance: %+v", b)
+	}
+	if b := balances[1]; b.Counterparty != "老王" || b.Lent != 300 || b.Borrowed != 100 || b.Net != 200 || b.OpenLoans != 2 || b.Overdue != 1 {
+		t.Errorf("Unexpected second balance: %+v", b)
+	}
+}
+
+func TestSummarizeLoansConvertsCurrencies(t *testing.T) {
+	day := time.Date(2024, 3, 10, 0, 0, 0, 0, time.Local)
+	conv := newCurrencyConverter("CNY", []models.ExchangeRate{{Currency: "USD", Date: day, Rate: 7.2}})
+	views := []LoanView{
+		{Loan: models.Loan{Direction: loanLend, Counterparty: "老王", Currency: "USD"}, Outstanding: 100},
+		{Loan: models.Loan{Direction: loanBorrow, Counterparty: "老王", Currency: "CNY"}, Outstanding: 200},
+		{Loan: models.Loan{Direction: loanLend, Counterparty: "小李", Currency: "EUR"}, Outstanding: 50},
+	}
+
+	balances := summarizeLoans(views, conv, day)
+	if len(balances) != 2 {
+		t.Fatalf("Expected 2 counterparties, got %+v", balances)
+	}
+	if b := balances[0]; b.Counterparty != "老王" || b.Lent != 720 || b.Borrowed != 200 || b.Net != 520 {
+		t.Errorf("Expected USD loan converted to CNY: %+v", b)
+	}
+	// 缺少汇率的借款只计笔数，不计金额
+	if b := balances[1]; b.Counterparty != "小李" || b.Lent != 0 || b.OpenLoans != 1 {
+		t.Errorf("Expected loan without rate to be counted but not summed: %+v", b)
+	}
+	if missing := conv.missingCurrencies(); len(missing) != 1 || missing[0] != "EUR" {
+		t.Errorf("Expected EUR to be reported missing, got %v", missing)
+	}
+}
+
+func TestLoanReminderContent(t *testing.T) {
+	due := time.Date(2024, 3, 10, 0, 0, 0, 0, time.Local)
+	view := LoanView{Loan: models.Loan{Direction: loanLend, Counterparty: "老王", Amount: 500, DueDate: &due}, Outstanding: 300}
+	if got, want := loanReminderContent(view), "借给老王的 500.00 已于 2024-03-10 到期，尚未收回 300.00"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	view.Direction = loanBorrow
+	if got, want := loanReminderContent(view), "向老王借的 500.00 已于 2024-03-10 到期，尚未归还 300.00"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+// **Feature: loans, Property 1: Counterparty net balances add up to lent minus borrowed**
+func TestLoanSummaryConservesOutstanding(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		names := []string{"老王", "小李", "阿强"}
+		n := rapid.IntRange(0, 20).Draw(t, "n")
+
+		var views []LoanView
+		var lent, borrowed int64
+		for i := 0; i < n; i++ {
+			direction := rapid.SampledFrom([]string{loanLend, loanBorrow}).Draw(t, "direction")
+			cents := rapid.Int64Range(0, 1000000).Draw(t, "cents")
+			views = append(views, LoanView{
+				Loan:        models.Loan{Direction: direction, Counterparty: rapid.SampledFrom(names).Draw(t, "name")},
+				Outstanding: fromCents(cents),
+			})
+			if direction == loanLend {
+				lent += cents
+			} else {
+				borrowed += cents
+			}
+		}
+
+		var net, open int64
+		for _, b := range summarizeLoans(views, newCurrencyConverter("CNY", nil), time.Now()) {
+			if toCents(b.Net) != toCents(b.Lent)-toCents(b.Borrowed) {
+				t.Fatalf("Net does not match lent minus borrowed: %+v", b)
+			}
+			net += toCents(b.Net)
+			open += int64(b.OpenLoans)
+		}
+		if net != lent-borrowed {
+			t.Fatalf("Expected total net %d, got %d", lent-borrowed, net)
+		}
+		for _, v := range views {
+			if v.Outstanding == 0 {
+				open++
+			}
+		}
+		if open != int64(len(views)) {
+			t.Fatalf("Expected every loan with an outstanding amount to be counted once")
+		}
+	})
+}
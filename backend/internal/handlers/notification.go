@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"daily-planner-backend/internal/database"
+	"daily-planner-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 通知类型
+const (
+	notificationBudgetThreshold = "budget_threshold"
+)
+
+// notify 写入一条站内通知；通知失败不影响触发它的业务操作
+func notify(userID uint, kind, title, content string, refID uint) {
+	n := models.Notification{
+		UserID:  userID,
+		Type:    kind,
+		Title:   title,
+		Content: content,
+		RefID:   refID,
+	}
+	if err := database.GetDB().Create(&n).Error; err != nil {
+		log.Printf("Failed to create %s notification for user %d: %v", kind, userID, err)
+	}
+}
+
+// GetNotifications 通知列表，unread=true 时只返回未读
+func GetNotifications(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	query := database.GetDB().Where("user_id = ?", userID)
+	if c.Query("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var notifications []models.Notification
+	if err := query.Order("created_at DESC, id DESC").Limit(200).Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, notifications)
+}
+
+func MarkNotificationRead(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var notification models.Notification
+	if err := database.GetDB().First(&notification, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return
+	}
+
+	if notification.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return
+	}
+
+	if notification.ReadAt == nil {
+		if err := database.GetDB().Model(&notification).Update("read_at", time.Now()).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, notification)
+}
+
+func MarkAllNotificationsRead(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	result := database.GetDB().Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).Update("read_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "notifications marked as read", "updated": result.RowsAffected})
+}
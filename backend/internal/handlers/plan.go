@@ -1,23 +1,54 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"daily-planner-backend/internal/database"
 	"daily-planner-backend/internal/models"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type CreatePlanRequest struct {
 	Content       string `json:"content" binding:"required"`
 	ExecutionDate string `json:"execution_date" binding:"required"`
+	ListID        *uint  `json:"list_id"`
+	Tags          string `json:"tags"` // 逗号分隔
+	GoalID        uint   `json:"goal_id"`
 }
 
 type UpdatePlanRequest struct {
-	Content string `json:"content"`
-	Status  string `json:"status"`
+	Content string  `json:"content"`
+	Status  string  `json:"status"`
+	Tags    *string `json:"tags"`
+	GoalID  *uint   `json:"goal_id"` // 0 表示取消关联目标
+}
+
+// normalizeTags 规范化逗号分隔的标签：去除空白和重复项，兼容中文逗号
+func normalizeTags(s string) string {
+	seen := make(map[string]bool)
+	var tags []string
+	for _, t := range strings.Split(strings.ReplaceAll(s, "，", ","), ",") {
+		t = strings.TrimSpace(t)
+		if t != "" && !seen[t] {
+			seen[t] = true
+			tags = append(tags, t)
+		}
+	}
+	return strings.Join(tags, ",")
+}
+
+// splitTags 拆分规范化后的标签
+func splitTags(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
 }
 
 func GetPlans(c *gin.Context) {
@@ -27,6 +58,15 @@ func GetPlans(c *gin.Context) {
 	var plans []models.Plan
 	query := database.GetDB().Where("user_id = ?", userID)
 
+	// 指定清单时返回清单内所有计划（包括共享给当前用户的清单）
+	if listID := c.Query("list_id"); listID != "" {
+		list, _, ok := loadPlanListWithRole(c, listID)
+		if !ok {
+			return
+		}
+		query = database.GetDB().Where("list_id = ?", list.ID)
+	}
+
 	if dateStr != "" {
 		date, err := time.Parse("2006-01-02", dateStr)
 		if err == nil {
@@ -35,7 +75,7 @@ func GetPlans(c *gin.Context) {
 		}
 	}
 
-	if err := query.Order("execution_date ASC").Find(&plans).Error; err != nil {
+	if err := query.Preload("Attachments").Order("execution_date ASC").Find(&plans).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
@@ -63,6 +103,27 @@ func CreatePlan(c *gin.Context) {
 		Content:       req.Content,
 		ExecutionDate: executionDate,
 		Status:        "pending",
+		Tags:          normalizeTags(req.Tags),
+	}
+
+	// 清单中的计划归清单所有者，协作者需要编辑权限
+	if req.ListID != nil {
+		list, role, ok := loadPlanListWithRole(c, strconv.FormatUint(uint64(*req.ListID), 10))
+		if !ok {
+			return
+		}
+		if !assignPlanToList(&plan, list, role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+			return
+		}
+	}
+
+	// 目标须属于计划的所有者
+	if !validateGoalLink(c, plan.UserID, req.GoalID) {
+		return
+	}
+	if req.GoalID != 0 {
+		plan.GoalID = &req.GoalID
 	}
 
 	if err := database.GetDB().Create(&plan).Error; err != nil {
@@ -83,7 +144,12 @@ func UpdatePlan(c *gin.Context) {
 		return
 	}
 
-	if plan.UserID != userID {
+	role, err := planRole(&plan, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if !canEditPlan(role) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
 		return
 	}
@@ -101,6 +167,15 @@ func UpdatePlan(c *gin.Context) {
 	if req.Status != "" {
 		updates["status"] = req.Status
 	}
+	if req.Tags != nil {
+		updates["tags"] = normalizeTags(*req.Tags)
+	}
+	if req.GoalID != nil {
+		if !validateGoalLink(c, plan.UserID, *req.GoalID) {
+			return
+		}
+		updates["goal_id"] = goalIDValue(*req.GoalID)
+	}
 
 	if err := database.GetDB().Model(&plan).Updates(updates).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
@@ -110,6 +185,23 @@ func UpdatePlan(c *gin.Context) {
 	c.JSON(http.StatusOK, plan)
 }
 
+// deletePlan 在一个事务中删除计划及其附件记录，提交后删除附件文件
+func deletePlan(ctx context.Context, plan *models.Plan) error {
+	var keys []string
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var err error
+		if keys, err = deleteAttachmentRows(tx, attachmentOwnerPlan, plan.ID); err != nil {
+			return err
+		}
+		return tx.Delete(plan).Error
+	})
+	if err != nil {
+		return err
+	}
+	removeAttachmentFiles(ctx, keys)
+	return nil
+}
+
 func DeletePlan(c *gin.Context) {
 	userID := c.GetUint("userID")
 	planID := c.Param("id")
@@ -120,12 +212,17 @@ func DeletePlan(c *gin.Context) {
 		return
 	}
 
-	if plan.UserID != userID {
+	role, err := planRole(&plan, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if !canDeletePlan(role) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
 		return
 	}
 
-	if err := database.GetDB().Delete(&plan).Error; err != nil {
+	if err := deletePlan(c.Request.Context(), &plan); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
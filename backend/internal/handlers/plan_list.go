@@ -0,0 +1,466 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"daily-planner-backend/internal/database"
+	"daily-planner-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 计划清单中的角色
+const (
+	planRoleOwner  = "owner"
+	planRoleEditor = "editor"
+	planRoleViewer = "viewer"
+)
+
+// 邀请状态
+const (
+	inviteStatusPending  = "pending"
+	inviteStatusAccepted = "accepted"
+	inviteStatusDeclined = "declined"
+)
+
+type CreatePlanListRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+type SharePlanListRequest struct {
+	Username string `json:"username" binding:"required"`
+	Role     string `json:"role" binding:"required,oneof=viewer editor"`
+}
+
+type UpdateMemberRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=viewer editor"`
+}
+
+// PlanListView 清单及当前用户在其中的角色
+type PlanListView struct {
+	models.PlanList
+	Role string `json:"role"`
+}
+
+// PlanListMemberView 成员信息附带用户名
+type PlanListMemberView struct {
+	models.PlanListMember
+	Username string `json:"username"`
+}
+
+// InvitationView 待处理的邀请
+type InvitationView struct {
+	models.PlanListMember
+	ListName        string `json:"list_name"`
+	InviterUsername string `json:"inviter_username"`
+}
+
+// canEditPlan 所有者和编辑者可以修改计划
+func canEditPlan(role string) bool {
+	return role == planRoleOwner || role == planRoleEditor
+}
+
+// canDeletePlan 只有所有者可以删除计划
+func canDeletePlan(role string) bool {
+	return role == planRoleOwner
+}
+
+// memberRole 在成员记录中查找用户已接受的角色，待接受或已拒绝的邀请不算成员
+func memberRole(members []models.PlanListMember, listID, userID uint) string {
+	for _, m := range members {
+		if m.ListID == listID && m.UserID == userID && m.Status == inviteStatusAccepted {
+			return m.Role
+		}
+	}
+	return ""
+}
+
+// resolveListRole 清单所有者为 owner，其余用户取其在成员记录中的角色
+func resolveListRole(list *models.PlanList, userID uint, members []models.PlanListMember) string {
+	if list.OwnerID == userID {
+		return planRoleOwner
+	}
+	return memberRole(members, list.ID, userID)
+}
+
+// resolvePlanRole 计划所有者为 owner，共享清单中的计划取用户在该清单的成员角色
+func resolvePlanRole(plan *models.Plan, userID uint, members []models.PlanListMember) string {
+	if plan.UserID == userID {
+		return planRoleOwner
+	}
+	if plan.ListID == nil {
+		return ""
+	}
+	return memberRole(members, *plan.ListID, userID)
+}
+
+// listMembersOf 读取用户在清单中的成员记录
+func listMembersOf(listID, userID uint) ([]models.PlanListMember, error) {
+	var members []models.PlanListMember
+	err := database.GetDB().Where("list_id = ? AND user_id = ?", listID, userID).Find(&members).Error
+	return members, err
+}
+
+// listRole 用户对清单的角色
+func listRole(list *models.PlanList, userID uint) (string, error) {
+	if list.OwnerID == userID {
+		return planRoleOwner, nil
+	}
+	members, err := listMembersOf(list.ID, userID)
+	if err != nil {
+		return "", err
+	}
+	return resolveListRole(list, userID, members), nil
+}
+
+// planRole 用户对计划的角色：计划所有者为 owner，共享清单成员取其成员角色
+func planRole(plan *models.Plan, userID uint) (string, error) {
+	if plan.UserID == userID || plan.ListID == nil {
+		return resolvePlanRole(plan, userID, nil), nil
+	}
+	members, err := listMembersOf(*plan.ListID, userID)
+	if err != nil {
+		return "", err
+	}
+	return resolvePlanRole(plan, userID, members), nil
+}
+
+// assignPlanToList 将新计划放入清单，清单中的计划归清单所有者；没有编辑权限时返回 false
+func assignPlanToList(plan *models.Plan, list *models.PlanList, role string) bool {
+	if !canEditPlan(role) {
+		return false
+	}
+	plan.UserID = list.OwnerID
+	plan.ListID = &list.ID
+	return true
+}
+
+// shareUpdates 重复邀请已有成员时的字段更新：更新角色，已拒绝的邀请重新变为待接受
+func shareUpdates(member *models.PlanListMember, role string) map[string]interface{} {
+	updates := map[string]interface{}{"role": role}
+	if member.Status == inviteStatusDeclined {
+		updates["status"] = inviteStatusPending
+	}
+	return updates
+}
+
+// checkInvitationResponse 校验用户能否接受或拒绝邀请，可以时返回 0，否则返回状态码和错误信息
+func checkInvitationResponse(member *models.PlanListMember, userID uint) (int, string) {
+	if member.UserID != userID {
+		return http.StatusForbidden, "access denied"
+	}
+	if member.Status != inviteStatusPending {
+		return http.StatusBadRequest, "invitation already " + member.Status
+	}
+	return 0, ""
+}
+
+// loadPlanListWithRole 读取清单并返回当前用户角色，无权访问时已写入响应
+func loadPlanListWithRole(c *gin.Context, listID string) (*models.PlanList, string, bool) {
+	var list models.PlanList
+	if err := database.GetDB().First(&list, listID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return nil, "", false
+	}
+
+	role, err := listRole(&list, c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return nil, "", false
+	}
+	if role == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return nil, "", false
+	}
+
+	return &list, role, true
+}
+
+// GetPlanLists 获取自己创建的和已加入的清单
+func GetPlanLists(c *gin.Context) {
+	userID := c.GetUint("userID")
+	db := database.GetDB()
+
+	var owned []models.PlanList
+	if err := db.Where("owner_id = ?", userID).Order("created_at ASC").Find(&owned).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	var members []models.PlanListMember
+	if err := db.Where("user_id = ? AND status = ?", userID, inviteStatusAccepted).Find(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	lists := make([]PlanListView, 0, len(owned)+len(members))
+	for _, l := range owned {
+		lists = append(lists, PlanListView{PlanList: l, Role: planRoleOwner})
+	}
+	for _, m := range members {
+		var l models.PlanList
+		if err := db.First(&l, m.ListID).Error; err != nil {
+			continue
+		}
+		lists = append(lists, PlanListView{PlanList: l, Role: m.Role})
+	}
+
+	c.JSON(http.StatusOK, lists)
+}
+
+func CreatePlanList(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req CreatePlanListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	list := models.PlanList{
+		OwnerID: userID,
+		Name:    req.Name,
+	}
+
+	if err := database.GetDB().Create(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, PlanListView{PlanList: list, Role: planRoleOwner})
+}
+
+func UpdatePlanList(c *gin.Context) {
+	list, role, ok := loadPlanListWithRole(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if role != planRoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return
+	}
+
+	var req CreatePlanListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	if err := database.GetDB().Model(list).Update("name", req.Name).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, PlanListView{PlanList: *list, Role: role})
+}
+
+// DeletePlanList 删除清单，清单中的计划保留给所有者
+func DeletePlanList(c *gin.Context) {
+	list, role, ok := loadPlanListWithRole(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if role != planRoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Plan{}).Where("list_id = ?", list.ID).Update("list_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("list_id = ?", list.ID).Delete(&models.PlanListMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(list).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "list deleted"})
+}
+
+// GetPlanListMembers 清单成员列表（含待接受的邀请）
+func GetPlanListMembers(c *gin.Context) {
+	list, _, ok := loadPlanListWithRole(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var members []PlanListMemberView
+	if err := database.GetDB().Model(&models.PlanListMember{}).
+		Select("plan_list_members.*, users.username").
+		Joins("JOIN users ON users.id = plan_list_members.user_id").
+		Where("plan_list_members.list_id = ?", list.ID).
+		Order("plan_list_members.created_at ASC").
+		Scan(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// SharePlanList 按用户名邀请成员，已有邀请时更新角色并重新发出
+func SharePlanList(c *gin.Context) {
+	userID := c.GetUint("userID")
+	list, role, ok := loadPlanListWithRole(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if role != planRoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return
+	}
+
+	var req SharePlanListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	var invitee models.User
+	if err := database.GetDB().Where("username = ?", req.Username).First(&invitee).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if invitee.ID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot share a list with yourself"})
+		return
+	}
+
+	var member models.PlanListMember
+	err := database.GetDB().Where("list_id = ? AND user_id = ?", list.ID, invitee.ID).First(&member).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		member = models.PlanListMember{
+			ListID:    list.ID,
+			UserID:    invitee.ID,
+			Role:      req.Role,
+			Status:    inviteStatusPending,
+			InvitedBy: userID,
+		}
+		err = database.GetDB().Create(&member).Error
+	case err == nil:
+		err = database.GetDB().Model(&member).Updates(shareUpdates(&member, req.Role)).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, PlanListMemberView{PlanListMember: member, Username: invitee.Username})
+}
+
+// UpdatePlanListMember 修改成员角色
+func UpdatePlanListMember(c *gin.Context) {
+	list, role, ok := loadPlanListWithRole(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if role != planRoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return
+	}
+
+	var req UpdateMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	var member models.PlanListMember
+	if err := database.GetDB().Where("list_id = ? AND user_id = ?", list.ID, c.Param("user_id")).First(&member).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return
+	}
+
+	if err := database.GetDB().Model(&member).Update("role", req.Role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+// RemovePlanListMember 所有者移除成员，或成员自己退出清单
+func RemovePlanListMember(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var list models.PlanList
+	if err := database.GetDB().First(&list, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return
+	}
+
+	var member models.PlanListMember
+	if err := database.GetDB().Where("list_id = ? AND user_id = ?", list.ID, c.Param("user_id")).First(&member).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return
+	}
+
+	if list.OwnerID != userID && member.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return
+	}
+
+	if err := database.GetDB().Delete(&member).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "member removed"})
+}
+
+// GetInvitations 当前用户待处理的邀请
+func GetInvitations(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var invitations []InvitationView
+	if err := database.GetDB().Model(&models.PlanListMember{}).
+		Select("plan_list_members.*, plan_lists.name AS list_name, users.username AS inviter_username").
+		Joins("JOIN plan_lists ON plan_lists.id = plan_list_members.list_id").
+		Joins("JOIN users ON users.id = plan_list_members.invited_by").
+		Where("plan_list_members.user_id = ? AND plan_list_members.status = ?", userID, inviteStatusPending).
+		Order("plan_list_members.created_at DESC").
+		Scan(&invitations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+func AcceptInvitation(c *gin.Context) {
+	respondInvitation(c, inviteStatusAccepted)
+}
+
+func DeclineInvitation(c *gin.Context) {
+	respondInvitation(c, inviteStatusDeclined)
+}
+
+func respondInvitation(c *gin.Context, status string) {
+	userID := c.GetUint("userID")
+
+	var member models.PlanListMember
+	if err := database.GetDB().First(&member, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return
+	}
+
+	if code, msg := checkInvitationResponse(&member, userID); code != 0 {
+		c.JSON(code, gin.H{"error": msg})
+		return
+	}
+
+	if err := database.GetDB().Model(&member).Update("status", status).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"daily-planner-backend/internal/database"
+	"daily-planner-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type PlanTemplateItemRequest struct {
+	Content       string `json:"content" binding:"required"`
+	OffsetMinutes int    `json:"offset_minutes" binding:"min=0,max=1439"`
+}
+
+type CreatePlanTemplateRequest struct {
+	Name        string                    `json:"name" binding:"required,max=100"`
+	Description string                    `json:"description" binding:"max=500"`
+	StartTime   string                    `json:"start_time"`
+	Items       []PlanTemplateItemRequest `json:"items" binding:"required,min=1,dive"`
+}
+
+type UpdatePlanTemplateRequest struct {
+	Name        string                     `json:"name" binding:"max=100"`
+	Description *string                    `json:"description"`
+	StartTime   string                     `json:"start_time"`
+	Items       *[]PlanTemplateItemRequest `json:"items" binding:"omitempty,min=1,dive"` // 传入时整体替换条目，不能为空
+}
+
+// parseClockMinutes 将 HH:MM 转换为当天的分钟数
+func parseClockMinutes(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// templateItemTime 计算模板条目在指定日期的执行时间，超出当天时返回错误
+func templateItemTime(date time.Time, startMinutes, offsetMinutes int) (time.Time, error) {
+	total := startMinutes + offsetMinutes
+	if total >= 24*60 {
+		return time.Time{}, fmt.Errorf("item at offset %d minutes falls outside the day", offsetMinutes)
+	}
+	return date.Add(time.Duration(total) * time.Minute), nil
+}
+
+func buildTemplateItems(reqs []PlanTemplateItemRequest) []models.PlanTemplateItem {
+	items := make([]models.PlanTemplateItem, len(reqs))
+	for i, r := range reqs {
+		items[i] = models.PlanTemplateItem{
+			Content:       r.Content,
+			OffsetMinutes: r.OffsetMinutes,
+			SortOrder:     i,
+		}
+	}
+	return items
+}
+
+// buildTemplatePlans 按模板条目为指定日期生成待办计划，任一条目超出当天时返回错误
+func buildTemplatePlans(template *models.PlanTemplate, date time.Time, startMinutes int) ([]models.Plan, error) {
+	plans := make([]models.Plan, 0, len(template.Items))
+	for _, item := range template.Items {
+		executionDate, err := templateItemTime(date, startMinutes, item.OffsetMinutes)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, models.Plan{
+			UserID:        template.UserID,
+			Content:       item.Content,
+			ExecutionDate: executionDate,
+			Status:        "pending",
+		})
+	}
+	return plans, nil
+}
+
+func orderedTemplateItems(db *gorm.DB) *gorm.DB {
+	return db.Order("offset_minutes ASC, sort_order ASC")
+}
+
+// loadOwnedPlanTemplate 读取模板并校验归属，失败时已写入响应
+func loadOwnedPlanTemplate(c *gin.Context) (*models.PlanTemplate, bool) {
+	var template models.PlanTemplate
+	if err := database.GetDB().Preload("Items", orderedTemplateItems).First(&template, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return nil, false
+	}
+
+	if template.UserID != c.GetUint("userID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return nil, false
+	}
+
+	return &template, true
+}
+
+func GetPlanTemplates(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var templates []models.PlanTemplate
+	if err := database.GetDB().Preload("Items", orderedTemplateItems).Where("user_id = ?", userID).Order("name ASC").Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+func GetPlanTemplate(c *gin.Context) {
+	template, ok := loadOwnedPlanTemplate(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+func CreatePlanTemplate(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req CreatePlanTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	if req.StartTime == "" {
+		req.StartTime = "00:00"
+	}
+	if _, err := parseClockMinutes(req.StartTime); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start_time format, use HH:MM"})
+		return
+	}
+
+	template := models.PlanTemplate{
+		UserID:      userID,
+		Name:        req.Name,
+		Description: req.Description,
+		StartTime:   req.StartTime,
+		Items:       buildTemplateItems(req.Items),
+	}
+
+	if err := database.GetDB().Create(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+func UpdatePlanTemplate(c *gin.Context) {
+	template, ok := loadOwnedPlanTemplate(c)
+	if !ok {
+		return
+	}
+
+	var req UpdatePlanTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Name != "" {
+		updates["name"] = req.Name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.StartTime != "" {
+		if _, err := parseClockMinutes(req.StartTime); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start_time format, use HH:MM"})
+			return
+		}
+		updates["start_time"] = req.StartTime
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(template).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if req.Items != nil {
+			if err := tx.Where("template_id = ?", template.ID).Delete(&models.PlanTemplateItem{}).Error; err != nil {
+				return err
+			}
+			items := buildTemplateItems(*req.Items)
+			for i := range items {
+				items[i].TemplateID = template.ID
+			}
+			if err := tx.Create(&items).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	database.GetDB().Preload("Items", orderedTemplateItems).First(template, template.ID)
+	c.JSON(http.StatusOK, template)
+}
+
+func DeletePlanTemplate(c *gin.Context) {
+	template, ok := loadOwnedPlanTemplate(c)
+	if !ok {
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("template_id = ?", template.ID).Delete(&models.PlanTemplateItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(template).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "template deleted"})
+}
+
+// ApplyPlanTemplate 在一个事务中按模板为指定日期创建全部计划
+func ApplyPlanTemplate(c *gin.Context) {
+	template, ok := loadOwnedPlanTemplate(c)
+	if !ok {
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", c.Query("date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+		return
+	}
+
+	startTime := template.StartTime
+	if s := c.Query("start"); s != "" {
+		startTime = s
+	}
+	startMinutes, err := parseClockMinutes(startTime)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start format, use HH:MM"})
+		return
+	}
+
+	if len(template.Items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "template has no items"})
+		return
+	}
+
+	plans, err := buildTemplatePlans(template, date, startMinutes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		return tx.Create(&plans).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, plans)
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"daily-planner-backend/internal/models"
+
+	"github.com/gin-gonic/gin/binding"
+	"pgregory.net/rapid"
+)
+
+func TestParseClockMinutes(t *testing.T) {
+	cases := map[string]int{"00:00": 0, "07:30": 450, "23:59": 1439}
+	for s, want := range cases {
+		got, err := parseClockMinutes(s)
+		if err != nil || got != want {
+			t.Errorf("parseClockMinutes(%q) = %d, %v; want %d", s, got, err, want)
+		}
+	}
+
+	for _, s := range []string{"", "24:00", "7:3", "12:60", "noon"} {
+		if _, err := parseClockMinutes(s); err == nil {
+			t.Errorf("Expected error for %q", s)
+		}
+	}
+}
+
+// **Feature: plan-templates, Property 1: Clock strings round-trip through minutes of the day**
+func TestParseClockMinutesRoundTrip(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		minutes := rapid.IntRange(0, 24*60-1).Draw(t, "minutes")
+		s := time.Date(2024, 1, 1, minutes/60, minutes%60, 0, 0, time.UTC).Format("15:04")
+
+		got, err := parseClockMinutes(s)
+		if err != nil || got != minutes {
+			t.Fatalf("parseClockMinutes(%q) = %d, %v; want %d", s, got, err, minutes)
+		}
+	})
+}
+
+// **Feature: plan-templates, Property 2: Applied plans keep the template's time sequence within the day**
+func TestTemplatePlansFollowOffsets(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		start := rapid.IntRange(0, 24*60-1).Draw(t, "start")
+		offsets := rapid.SliceOfN(rapid.IntRange(0, 24*60-1), 1, 10).Draw(t, "offsets")
+
+		template := models.PlanTemplate{UserID: 7}
+		for i, o := range offsets {
+			template.Items = append(template.Items, models.PlanTemplateItem{Content: "item", OffsetMinutes: o, SortOrder: i})
+		}
+		date := time.Date(2024, 3, 5, 0, 0, 0, 0, time.Local)
+
+		plans, err := buildTemplatePlans(&template, date, start)
+		fits := true
+		for _, o := range offsets {
+			if start+o >= 24*60 {
+				fits = false
+			}
+		}
+		if !fits {
+			if err == nil {
+				t.Fatalf("Expected error when an item falls outside the day")
+			}
+			return
+		}
+		if err != nil || len(plans) != len(offsets) {
+			t.Fatalf("Unexpected result: %v, %v", plans, err)
+		}
+
+		for i, p := range plans {
+			if p.UserID != 7 || p.Status != "pending" {
+				t.Fatalf("Unexpected plan fields: %+v", p)
+			}
+			if got := int(p.ExecutionDate.Sub(date).Minutes()); got != start+offsets[i] {
+				t.Fatalf("Plan %d at minute %d, want %d", i, got, start+offsets[i])
+			}
+			if p.ExecutionDate.Day() != date.Day() {
+				t.Fatalf("Plan %d spills into the next day: %v", i, p.ExecutionDate)
+			}
+		}
+	})
+}
+
+func TestUpdatePlanTemplateRejectsEmptyItems(t *testing.T) {
+	empty := []PlanTemplateItemRequest{}
+	if err := binding.Validator.ValidateStruct(&UpdatePlanTemplateRequest{Items: &empty}); err == nil {
+		t.Error("Expected empty items list to be rejected")
+	}
+
+	if err := binding.Validator.ValidateStruct(&UpdatePlanTemplateRequest{Name: "早晨"}); err != nil {
+		t.Errorf("Expected omitted items to be accepted: %v", err)
+	}
+
+	items := []PlanTemplateItemRequest{{Content: "跑步", OffsetMinutes: 30}}
+	if err := binding.Validator.ValidateStruct(&UpdatePlanTemplateRequest{Items: &items}); err != nil {
+		t.Errorf("Expected non-empty items list to be accepted: %v", err)
+	}
+
+	invalid := []PlanTemplateItemRequest{{Content: "", OffsetMinutes: 30}}
+	if err := binding.Validator.ValidateStruct(&UpdatePlanTemplateRequest{Items: &invalid}); err == nil {
+		t.Error("Expected items to be validated individually")
+	}
+}
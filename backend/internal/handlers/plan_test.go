@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"net/http"
 	"testing"
 
+	"daily-planner-backend/internal/models"
+
 	"pgregory.net/rapid"
 )
 
@@ -50,3 +53,134 @@ func TestPlanOwnershipVerification(t *testing.T) {
 		}
 	})
 }
+
+// **Feature: shared-plans, Property 1: Collaborators can edit but only owners can delete**
+func TestPlanRolePermissions(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		role := rapid.SampledFrom([]string{planRoleOwner, planRoleEditor, planRoleViewer, ""}).Draw(t, "role")
+
+		if canDeletePlan(role) && role != planRoleOwner {
+			t.Fatalf("Role %q should not be able to delete plans", role)
+		}
+		if canDeletePlan(role) && !canEditPlan(role) {
+			t.Fatalf("Role %q can delete but not edit", role)
+		}
+		if canEditPlan(role) != (role == planRoleOwner || role == planRoleEditor) {
+			t.Fatalf("Unexpected edit permission for role %q", role)
+		}
+	})
+}
+
+func TestResolveListRole(t *testing.T) {
+	list := models.PlanList{ID: 1, OwnerID: 10}
+	members := []models.PlanListMember{
+		{ListID: 1, UserID: 20, Role: planRoleEditor, Status: inviteStatusAccepted},
+		{ListID: 1, UserID: 30, Role: planRoleViewer, Status: inviteStatusAccepted},
+		{ListID: 1, UserID: 40, Role: planRoleEditor, Status: inviteStatusPending},
+		{ListID: 1, UserID: 50, Role: planRoleEditor, Status: inviteStatusDeclined},
+		{ListID: 2, UserID: 60, Role: planRoleEditor, Status: inviteStatusAccepted},
+	}
+
+	cases := map[uint]string{10: planRoleOwner, 20: planRoleEditor, 30: planRoleViewer, 40: "", 50: "", 60: "", 70: ""}
+	for userID, want := range cases {
+		if got := resolveListRole(&list, userID, members); got != want {
+			t.Errorf("User %d: expected role %q, got %q", userID, want, got)
+		}
+	}
+}
+
+func TestResolvePlanRole(t *testing.T) {
+	listID := uint(1)
+	members := []models.PlanListMember{
+		{ListID: 1, UserID: 20, Role: planRoleEditor, Status: inviteStatusAccepted},
+		{ListID: 1, UserID: 30, Role: planRoleViewer, Status: inviteStatusAccepted},
+	}
+
+	shared := models.Plan{UserID: 10, ListID: &listID}
+	if role := resolvePlanRole(&shared, 20, members); !canEditPlan(role) || canDeletePlan(role) {
+		t.Errorf("Editor should edit but not delete a shared plan, got role %q", role)
+	}
+	if role := resolvePlanRole(&shared, 30, members); canEditPlan(role) || role != planRoleViewer {
+		t.Errorf("Viewer should not edit a shared plan, got role %q", role)
+	}
+	if role := resolvePlanRole(&shared, 10, nil); !canDeletePlan(role) {
+		t.Errorf("List owner should delete plans in the list, got role %q", role)
+	}
+
+	// 不在清单中的计划只有所有者可以访问，成员身份不起作用
+	private := models.Plan{UserID: 10}
+	if role := resolvePlanRole(&private, 20, members); role != "" {
+		t.Errorf("Member should not access a private plan, got role %q", role)
+	}
+}
+
+func TestAssignPlanToList(t *testing.T) {
+	list := models.PlanList{ID: 3, OwnerID: 10}
+
+	plan := models.Plan{UserID: 20}
+	if !assignPlanToList(&plan, &list, planRoleEditor) {
+		t.Fatal("Editor should be able to create plans in the list")
+	}
+	if plan.UserID != 10 || plan.ListID == nil || *plan.ListID != 3 {
+		t.Errorf("Plan created in a list should belong to the list owner: %+v", plan)
+	}
+
+	viewerPlan := models.Plan{UserID: 30}
+	if assignPlanToList(&viewerPlan, &list, planRoleViewer) || viewerPlan.UserID != 30 || viewerPlan.ListID != nil {
+		t.Errorf("Viewer should not be able to create plans in the list: %+v", viewerPlan)
+	}
+}
+
+func TestInvitationResponse(t *testing.T) {
+	pending := models.PlanListMember{UserID: 20, Status: inviteStatusPending}
+	if code, _ := checkInvitationResponse(&pending, 20); code != 0 {
+		t.Errorf("Invitee should be able to respond to a pending invitation, got %d", code)
+	}
+	if code, _ := checkInvitationResponse(&pending, 30); code != http.StatusForbidden {
+		t.Errorf("Other users should not respond to the invitation, got %d", code)
+	}
+
+	for _, status := range []string{inviteStatusAccepted, inviteStatusDeclined} {
+		answered := models.PlanListMember{UserID: 20, Status: status}
+		code, msg := checkInvitationResponse(&answered, 20)
+		if code != http.StatusBadRequest || msg != "invitation already "+status {
+			t.Errorf("Expected %s invitation to be rejected, got %d %q", status, code, msg)
+		}
+	}
+
+	// 重新邀请已拒绝的用户时恢复为待接受，已接受的成员只修改角色
+	declined := models.PlanListMember{Status: inviteStatusDeclined}
+	if u := shareUpdates(&declined, planRoleEditor); u["status"] != inviteStatusPending || u["role"] != planRoleEditor {
+		t.Errorf("Unexpected re-invite updates: %v", u)
+	}
+	accepted := models.PlanListMember{Status: inviteStatusAccepted}
+	if u := shareUpdates(&accepted, planRoleViewer); len(u) != 1 || u["role"] != planRoleViewer {
+		t.Errorf("Unexpected role change updates: %v", u)
+	}
+}
+
+// **Feature: shared-plans, Property 2: Only accepted members of the plan's list gain a role**
+func TestOnlyAcceptedMembersGainPlanRole(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		listID := uint(1)
+		plan := models.Plan{UserID: 1, ListID: &listID}
+		member := models.PlanListMember{
+			ListID: rapid.UintRange(1, 3).Draw(t, "listID"),
+			UserID: 2,
+			Role:   rapid.SampledFrom([]string{planRoleEditor, planRoleViewer}).Draw(t, "role"),
+			Status: rapid.SampledFrom([]string{inviteStatusPending, inviteStatusAccepted, inviteStatusDeclined}).Draw(t, "status"),
+		}
+
+		role := resolvePlanRole(&plan, 2, []models.PlanListMember{member})
+		granted := member.ListID == listID && member.Status == inviteStatusAccepted
+		if granted && role != member.Role {
+			t.Fatalf("Expected role %q, got %q", member.Role, role)
+		}
+		if !granted && role != "" {
+			t.Fatalf("Expected no role for %+v, got %q", member, role)
+		}
+		if canDeletePlan(role) {
+			t.Fatalf("Members should never be able to delete the owner's plans")
+		}
+	})
+}
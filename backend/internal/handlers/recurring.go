@@ -0,0 +1,527 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"daily-planner-backend/internal/database"
+	"daily-planner-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 周期支出的间隔单位
+const (
+	recurringDaily   = "daily"
+	recurringWeekly  = "weekly"
+	recurringMonthly = "monthly"
+	recurringYearly  = "yearly"
+)
+
+// recurringCatchUpLimit 单次最多补生成的期数，防止起始日期过早时生成过多记录
+const recurringCatchUpLimit = 400
+
+type CreateRecurringExpenseRequest struct {
+	Name           string  `json:"name" binding:"required,max=100"`
+	Amount         float64 `json:"amount" binding:"required,gt=0"`
+	Currency       string  `json:"currency" binding:"omitempty,iso4217"` // 缺省为用户的本位币
+	Category       string  `json:"category" binding:"required,max=50"`
+	Merchant       string  `json:"merchant" binding:"max=100"`
+	Note           string  `json:"note" binding:"max=255"`
+	Interval       string  `json:"interval" binding:"required,oneof=daily weekly monthly yearly"`
+	IntervalCount  int     `json:"interval_count" binding:"omitempty,min=1,max=365"`
+	StartDate      string  `json:"start_date"` // 首次扣款日，缺省为今天
+	EndDate        string  `json:"end_date"`
+	IsSubscription bool    `json:"is_subscription"`
+}
+
+type UpdateRecurringExpenseRequest struct {
+	Name           string  `json:"name" binding:"max=100"`
+	Amount         float64 `json:"amount" binding:"omitempty,gt=0"`
+	Currency       string  `json:"currency" binding:"omitempty,iso4217"`
+	Category       string  `json:"category" binding:"max=50"`
+	Merchant       *string `json:"merchant" binding:"omitempty,max=100"`
+	Note           *string `json:"note" binding:"omitempty,max=255"`
+	NextDueDate    string  `json:"next_due_date"`
+	EndDate        *string `json:"end_date"` // 空字符串表示不再限制结束日期
+	IsSubscription *bool   `json:"is_subscription"`
+	Active         *bool   `json:"active"`
+}
+
+// UpcomingCharge 即将发生的一笔周期支出
+type UpcomingCharge struct {
+	RecurringID uint      `json:"recurring_id"`
+	Name        string    `json:"name"`
+	Amount      float64   `json:"amount"`
+	Currency    string    `json:"currency"`
+	Category    string    `json:"category"`
+	DueDate     time.Time `json:"due_date"`
+}
+
+// recurringOccurrence 返回第 n 期（从 0 开始）的扣款日。按月和按年的周期以起始日为锚点，
+// 遇到较短的月份取月末，之后的月份恢复原来的日期
+func recurringOccurrence(def *models.RecurringExpense, n int) time.Time {
+	start := def.StartDate
+	step := def.IntervalCount
+	if step < 1 {
+		step = 1
+	}
+
+	switch def.Interval {
+	case recurringDaily:
+		return start.AddDate(0, 0, n*step)
+	case recurringWeekly:
+		return start.AddDate(0, 0, 7*n*step)
+	}
+
+	months := n * step
+	if def.Interval == recurringYearly {
+		months *= 12
+	}
+	first := time.Date(start.Year(), start.Month()+time.Month(months), 1, 0, 0, 0, 0, start.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	day := start.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// recurringIndex 返回扣款日为 due 的期数；due 不是扣款日时返回不早于它的第一期
+func recurringIndex(def *models.RecurringExpense, due time.Time) int {
+	n := 0
+	// 先按平均周期长度估算，再逐期校正
+	if days := daysBetween(def.StartDate, due); days > 0 {
+		n = int(float64(days)/recurringPeriodDays(def)) - 1
+		if n < 0 {
+			n = 0
+		}
+	}
+	for n > 0 && recurringOccurrence(def, n).After(due) {
+		n--
+	}
+	for recurringOccurrence(def, n).Before(due) {
+		n++
+	}
+	return n
+}
+
+// recurringPeriodDays 一个周期的平均天数
+func recurringPeriodDays(def *models.RecurringExpense) float64 {
+	step := float64(def.IntervalCount)
+	if step < 1 {
+		step = 1
+	}
+	switch def.Interval {
+	case recurringDaily:
+		return step
+	case recurringWeekly:
+		return 7 * step
+	case recurringMonthly:
+		return 365.0 / 12 * step
+	default:
+		return 365 * step
+	}
+}
+
+// annualizedCost 按周期折算的年度费用
+func annualizedCost(def *models.RecurringExpense) float64 {
+	step := float64(def.IntervalCount)
+	if step < 1 {
+		step = 1
+	}
+	switch def.Interval {
+	case recurringDaily:
+		return round2(def.Amount * 365 / step)
+	case recurringWeekly:
+		return round2(def.Amount * 52 / step)
+	case recurringMonthly:
+		return round2(def.Amount * 12 / step)
+	default:
+		return round2(def.Amount / step)
+	}
+}
+
+// upcomingCharges 列出 [from, until] 内的扣款
+func upcomingCharges(def *models.RecurringExpense, from, until time.Time) []UpcomingCharge {
+	var charges []UpcomingCharge
+	if def.NextDueDate.After(from) {
+		from = def.NextDueDate
+	}
+	for n := recurringIndex(def, from); ; n++ {
+		due := recurringOccurrence(def, n)
+		if due.After(until) || (def.EndDate != nil && due.After(*def.EndDate)) {
+			break
+		}
+		charges = append(charges, UpcomingCharge{
+			RecurringID: def.ID,
+			Name:        def.Name,
+			Amount:      def.Amount,
+			Currency:    def.Currency,
+			Category:    def.Category,
+			DueDate:     due,
+		})
+	}
+	return charges
+}
+
+// materializeRecurring 为到期的周期支出生成支出记录并推进下次扣款日。
+// 支出以 recurring:<定义ID>:<日期> 作为外部交易号，重复执行不会重复生成
+func materializeRecurring(defID uint, now time.Time) error {
+	todayDate := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+	var created []models.Expense
+	var def models.RecurringExpense
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&def, defID).Error; err != nil {
+			return err
+		}
+		if !def.Active {
+			return nil
+		}
+
+		n := recurringIndex(&def, def.NextDueDate)
+		next := def.NextDueDate
+		active := true
+		for i := 0; i < recurringCatchUpLimit && !next.After(todayDate); i++ {
+			if def.EndDate != nil && next.After(*def.EndDate) {
+				break
+			}
+			externalID := fmt.Sprintf("recurring:%d:%s", def.ID, next.Format(dayLayout))
+			expense := models.Expense{
+				UserID:      def.UserID,
+				Amount:      def.Amount,
+				Currency:    def.Currency,
+				Category:    def.Category,
+				Merchant:    def.Merchant,
+				Note:        def.Note,
+				SpentAt:     next,
+				Source:      "recurring",
+				ExternalID:  &externalID,
+				RecurringID: &def.ID,
+			}
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&expense)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				created = append(created, expense)
+			}
+			n++
+			next = recurringOccurrence(&def, n)
+		}
+		if def.EndDate != nil && next.After(*def.EndDate) {
+			active = false
+		}
+
+		return tx.Model(&def).Updates(map[string]interface{}{"next_due_date": next, "active": active}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	checked := make(map[string]bool)
+	for _, e := range created {
+		key := e.SpentAt.Format(monthLayout)
+		if !checked[key] {
+			checked[key] = true
+			checkBudgetThresholds(e.UserID, e.Category, e.SpentAt)
+		}
+	}
+	return nil
+}
+
+// MaterializeDueRecurringExpenses 后台任务：生成所有已到期的周期支出
+func MaterializeDueRecurringExpenses(now time.Time) error {
+	var ids []uint
+	if err := database.GetDB().Model(&models.RecurringExpense{}).
+		Where("active = ? AND next_due_date <= ?", true, now).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := materializeRecurring(id, now); err != nil {
+			log.Printf("Failed to materialize recurring expense %d: %v", id, err)
+		}
+	}
+	return nil
+}
+
+func loadOwnedRecurring(c *gin.Context) (*models.RecurringExpense, bool) {
+	var def models.RecurringExpense
+	if err := database.GetDB().First(&def, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return nil, false
+	}
+
+	if def.UserID != c.GetUint("userID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return nil, false
+	}
+
+	return &def, true
+}
+
+func GetRecurringExpenses(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var defs []models.RecurringExpense
+	if err := database.GetDB().Where("user_id = ?", userID).Order("active DESC, next_due_date ASC").Find(&defs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, defs)
+}
+
+// GetUpcomingCharges 未来 days 天（缺省 30）内的扣款，以及订阅的年度费用；合计换算为本位币
+func GetUpcomingCharges(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	days := 30
+	if s := c.Query("days"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 366 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 366"})
+			return
+		}
+		days = n
+	}
+
+	var defs []models.RecurringExpense
+	if err := database.GetDB().Where("user_id = ? AND active = ?", userID, true).Find(&defs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	currencies := make([]string, len(defs))
+	for i, d := range defs {
+		currencies[i] = d.Currency
+	}
+	conv, err := loadCurrencyConverter(userID, currencies...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	// 合计按扣款日汇率换算为本位币，订阅年费按今天的汇率换算
+	from := today()
+	until := from.AddDate(0, 0, days)
+	charges := []UpcomingCharge{}
+	var total, annual float64
+	subscriptions := []gin.H{}
+	for i := range defs {
+		for _, ch := range upcomingCharges(&defs[i], from, until) {
+			charges = append(charges, ch)
+			if v, ok := conv.convert(ch.Amount, ch.Currency, ch.DueDate); ok {
+				total += v
+			}
+		}
+		if defs[i].IsSubscription {
+			cost := annualizedCost(&defs[i])
+			if v, ok := conv.convert(cost, defs[i].Currency, from); ok {
+				annual += v
+			}
+			subscriptions = append(subscriptions, gin.H{
+				"id":          defs[i].ID,
+				"name":        defs[i].Name,
+				"amount":      defs[i].Amount,
+				"currency":    defs[i].Currency,
+				"interval":    defs[i].Interval,
+				"annual_cost": cost,
+			})
+		}
+	}
+	sort.SliceStable(charges, func(i, j int) bool { return charges[i].DueDate.Before(charges[j].DueDate) })
+
+	c.JSON(http.StatusOK, gin.H{
+		"days":          days,
+		"total":         round2(total),
+		"currency":      conv.base,
+		"missing_rates": conv.missingCurrencies(),
+		"charges":       charges,
+		"subscriptions": gin.H{
+			"count":        len(subscriptions),
+			"annual_cost":  round2(annual),
+			"monthly_cost": round2(annual / 12),
+			"items":        subscriptions,
+		},
+	})
+}
+
+func CreateRecurringExpense(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req CreateRecurringExpenseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	startDate := today()
+	if req.StartDate != "" {
+		d, err := time.ParseInLocation(dayLayout, req.StartDate, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+			return
+		}
+		startDate = d
+	}
+
+	if req.Currency == "" {
+		base, err := userBaseCurrency(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		req.Currency = base
+	}
+
+	def := models.RecurringExpense{
+		UserID:         userID,
+		Name:           req.Name,
+		Amount:         req.Amount,
+		Currency:       req.Currency,
+		Category:       req.Category,
+		Merchant:       req.Merchant,
+		Note:           req.Note,
+		Interval:       req.Interval,
+		IntervalCount:  req.IntervalCount,
+		StartDate:      startDate,
+		NextDueDate:    startDate,
+		IsSubscription: req.IsSubscription,
+		Active:         true,
+	}
+	if def.IntervalCount == 0 {
+		def.IntervalCount = 1
+	}
+	if req.EndDate != "" {
+		d, err := time.ParseInLocation(dayLayout, req.EndDate, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+			return
+		}
+		if d.Before(startDate) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must not be before start_date"})
+			return
+		}
+		def.EndDate = &d
+	}
+
+	if err := database.GetDB().Create(&def).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	// 起始日已到时立即生成，不必等待后台任务
+	if !def.StartDate.After(today()) {
+		if err := materializeRecurring(def.ID, time.Now()); err != nil {
+			log.Printf("Failed to materialize recurring expense %d: %v", def.ID, err)
+		}
+		database.GetDB().First(&def, def.ID)
+	}
+
+	c.JSON(http.StatusCreated, def)
+}
+
+func UpdateRecurringExpense(c *gin.Context) {
+	def, ok := loadOwnedRecurring(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateRecurringExpenseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Name != "" {
+		updates["name"] = req.Name
+	}
+	if req.Amount != 0 {
+		updates["amount"] = req.Amount
+	}
+	if req.Currency != "" {
+		updates["currency"] = req.Currency
+	}
+	if req.Category != "" {
+		updates["category"] = req.Category
+	}
+	if req.Merchant != nil {
+		updates["merchant"] = *req.Merchant
+	}
+	if req.Note != nil {
+		updates["note"] = *req.Note
+	}
+	if req.NextDueDate != "" {
+		// 跳过或推迟某一期：下次扣款日必须是周期上的某一天
+		d, err := time.ParseInLocation(dayLayout, req.NextDueDate, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+			return
+		}
+		if d.Before(def.StartDate) || !recurringOccurrence(def, recurringIndex(def, d)).Equal(d) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "next_due_date must be a scheduled occurrence"})
+			return
+		}
+		updates["next_due_date"] = d
+	}
+	if req.EndDate != nil {
+		if *req.EndDate == "" {
+			updates["end_date"] = nil
+		} else {
+			d, err := time.ParseInLocation(dayLayout, *req.EndDate, time.Local)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+				return
+			}
+			if d.Before(def.StartDate) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must not be before start_date"})
+				return
+			}
+			updates["end_date"] = d
+		}
+	}
+	if req.IsSubscription != nil {
+		updates["is_subscription"] = *req.IsSubscription
+	}
+	if req.Active != nil {
+		updates["active"] = *req.Active
+	}
+
+	if err := database.GetDB().Model(def).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, def)
+}
+
+// DeleteRecurringExpense 删除周期支出定义，已生成的支出保留但解除关联
+func DeleteRecurringExpense(c *gin.Context) {
+	def, ok := loadOwnedRecurring(c)
+	if !ok {
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Expense{}).Where("recurring_id = ?", def.ID).Update("recurring_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(def).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "recurring expense deleted"})
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"daily-planner-backend/internal/models"
+
+	"pgregory.net/rapid"
+)
+
+func TestRecurringOccurrenceMonthEnd(t *testing.T) {
+	def := models.RecurringExpense{
+		Interval:      recurringMonthly,
+		IntervalCount: 1,
+		StartDate:     time.Date(2024, 1, 31, 0, 0, 0, 0, time.Local),
+	}
+
+	// 短月取月末，之后恢复 31 日
+	want := []string{"2024-01-31", "2024-02-29", "2024-03-31", "2024-04-30", "2024-05-31"}
+	for n, w := range want {
+		if got := recurringOccurrence(&def, n).Format(dayLayout); got != w {
+			t.Errorf("Occurrence %d: expected %s, got %s", n, w, got)
+		}
+	}
+
+	def.Interval = recurringYearly
+	def.StartDate = time.Date(2024, 2, 29, 0, 0, 0, 0, time.Local)
+	if got := recurringOccurrence(&def, 1).Format(dayLayout); got != "2025-02-28" {
+		t.Errorf("Expected leap-day subscription to renew on 2025-02-28, got %s", got)
+	}
+}
+
+func TestAnnualizedCost(t *testing.T) {
+	cases := []struct {
+		interval string
+		count    int
+		amount   float64
+		want     float64
+	}{
+		{recurringMonthly, 1, 25, 300},
+		{recurringMonthly, 3, 30, 120},
+		{recurringWeekly, 2, 10, 260},
+		{recurringYearly, 1, 88, 88},
+		{recurringDaily, 1, 1, 365},
+	}
+	for _, tc := range cases {
+		def := models.RecurringExpense{Interval: tc.interval, IntervalCount: tc.count, Amount: tc.amount}
+		if got := annualizedCost(&def); got != tc.want {
+			t.Errorf("annualizedCost(%s x%d, %v) = %v, want %v", tc.interval, tc.count, tc.amount, got, tc.want)
+		}
+	}
+}
+
+func TestUpcomingCharges(t *testing.T) {
+	start := time.Date(2024, 3, 10, 0, 0, 0, 0, time.Local)
+	end := time.Date(2024, 4, 10, 0, 0, 0, 0, time.Local)
+	def := models.RecurringExpense{
+		ID:            1,
+		Interval:      recurringWeekly,
+		IntervalCount: 1,
+		Amount:        9.9,
+		StartDate:     start,
+		NextDueDate:   start.AddDate(0, 0, 14),
+		EndDate:       &end,
+	}
+
+	charges := upcomingCharges(&def, time.Date(2024, 3, 20, 0, 0, 0, 0, time.Local), time.Date(2024, 4, 30, 0, 0, 0, 0, time.Local))
+	var got []string
+	for _, c := range charges {
+		got = append(got, c.DueDate.Format(dayLayout))
+	}
+	want := []string{"2024-03-24", "2024-03-31", "2024-04-07"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected charges %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Expected charges %v, got %v", want, got)
+			break
+		}
+	}
+}
+
+// **Feature: recurring-expenses, Property 1: recurringIndex finds the occurrence it was given**
+func TestRecurringIndexRoundTrip(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		def := models.RecurringExpense{
+			Interval:      rapid.SampledFrom([]string{recurringDaily, recurringWeekly, recurringMonthly, recurringYearly}).Draw(t, "interval"),
+			IntervalCount: rapid.IntRange(1, 6).Draw(t, "count"),
+			StartDate:     time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local).AddDate(0, 0, rapid.IntRange(0, 1500).Draw(t, "start")),
+		}
+		n := rapid.IntRange(0, 120).Draw(t, "n")
+
+		due := recurringOccurrence(&def, n)
+		if got := recurringIndex(&def, due); got != n {
+			t.Fatalf("recurringIndex(%s) = %d, want %d", due.Format(dayLayout), got, n)
+		}
+		if !recurringOccurrence(&def, n+1).After(due) {
+			t.Fatalf("Occurrences are not increasing after %s", due.Format(dayLayout))
+		}
+	})
+}
@@ -0,0 +1,512 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"daily-planner-backend/internal/database"
+	"daily-planner-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 报销状态，报销单和其中的支出使用同一组状态
+const (
+	reimbursePending    = "pending"
+	reimburseSubmitted  = "submitted"
+	reimburseReimbursed = "reimbursed"
+	reimburseRejected   = "rejected"
+)
+
+// reimbursementSource 报销到账收入的来源，统计时可据此排除
+const reimbursementSource = "reimbursement"
+
+// claimTransitions 报销单操作允许的当前状态及操作后的状态
+var claimTransitions = map[string]struct {
+	from []string
+	to   string
+}{
+	"submit": {from: []string{reimbursePending, reimburseRejected}, to: reimburseSubmitted},
+	"reject": {from: []string{reimburseSubmitted}, to: reimburseRejected},
+	"pay":    {from: []string{reimburseSubmitted}, to: reimburseReimbursed},
+}
+
+type CreateClaimRequest struct {
+	Title      string `json:"title" binding:"required,max=100"`
+	Note       string `json:"note" binding:"max=255"`
+	ExpenseIDs []uint `json:"expense_ids" binding:"required,min=1"`
+}
+
+type UpdateClaimRequest struct {
+	Title      string  `json:"title" binding:"max=100"`
+	Note       *string `json:"note" binding:"omitempty,max=255"`
+	ExpenseIDs []uint  `json:"expense_ids"` // 替换报销单内的支出，仅待提交或被驳回的报销单可修改
+}
+
+type PayClaimRequest struct {
+	Amount    float64 `json:"amount" binding:"omitempty,gt=0"` // 实际到账金额，缺省为报销单合计
+	AccountID uint    `json:"account_id"`                      // 到账账户
+	Category  string  `json:"category" binding:"max=50"`       // 收入分类，缺省为“其他收入”
+	PaidAt    string  `json:"paid_at"`                         // 缺省为当前时间
+}
+
+// ReimbursementStatusTotal 某个报销状态下的支出笔数和金额
+type ReimbursementStatusTotal struct {
+	Status string  `json:"status"`
+	Count  int64   `json:"count"`
+	Total  float64 `json:"total"`
+}
+
+// nextClaimStatus 返回报销单执行操作后的状态，当前状态不允许该操作时返回 false
+func nextClaimStatus(current, action string) (string, bool) {
+	t, ok := claimTransitions[action]
+	if !ok {
+		return "", false
+	}
+	for _, s := range t.from {
+		if s == current {
+			return t.to, true
+		}
+	}
+	return "", false
+}
+
+// claimTotal 报销单内支出按各自消费日期的汇率换算为本位币后的合计，缺少汇率时返回 errMissingRates
+func claimTotal(expenses []models.Expense, conv *currencyConverter) (float64, error) {
+	var total float64
+	for _, e := range expenses {
+		v, ok := conv.convert(e.Amount, e.Currency, e.SpentAt)
+		if !ok {
+			return 0, errMissingRates
+		}
+		total += v
+	}
+	return round2(total), nil
+}
+
+// writeClaimError 写入保存报销单失败的响应，缺少汇率时列出缺少的币种
+func writeClaimError(c *gin.Context, err error, conv *currencyConverter) {
+	if errors.Is(err, errMissingRates) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "missing_rates": conv.missingCurrencies()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+}
+
+func loadOwnedClaim(c *gin.Context) (*models.ReimbursementClaim, bool) {
+	var claim models.ReimbursementClaim
+	if err := database.GetDB().First(&claim, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return nil, false
+	}
+
+	if claim.UserID != c.GetUint("userID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return nil, false
+	}
+
+	return &claim, true
+}
+
+// loadClaimableExpenses 校验支出可以加入报销单：属于当前用户、标记为可报销，
+// 且未在其他报销单中（被驳回的报销单可以修改后重新提交，或删除后另行申请）；失败时已写入响应
+func loadClaimableExpenses(c *gin.Context, ids []uint, claimID uint) ([]models.Expense, bool) {
+	var expenses []models.Expense
+	if err := database.GetDB().Where("id IN ?", ids).Order("spent_at ASC, id ASC").Find(&expenses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return nil, false
+	}
+
+	unique := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		unique[id] = true
+	}
+	if len(expenses) != len(unique) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expense not found"})
+		return nil, false
+	}
+
+	for _, e := range expenses {
+		if e.UserID != c.GetUint("userID") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+			return nil, false
+		}
+		if !e.Reimbursable {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("expense %d is not reimbursable", e.ID)})
+			return nil, false
+		}
+		if e.ClaimID != nil && *e.ClaimID != claimID {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("expense %d is already in another claim", e.ID)})
+			return nil, false
+		}
+	}
+	return expenses, true
+}
+
+// setClaimExpenses 将报销单内支出的状态同步为报销单状态
+func setClaimExpenses(tx *gorm.DB, claimID uint, status string) error {
+	return tx.Model(&models.Expense{}).Where("claim_id = ?", claimID).Update("reimburse_status", status).Error
+}
+
+// refreshClaimTotal 按报销单内现有支出重新计算换算为本位币后的合计，缺少汇率时返回 errMissingRates
+func refreshClaimTotal(tx *gorm.DB, claim *models.ReimbursementClaim, conv *currencyConverter) error {
+	total, _, err := convertedSum(tx.Model(&models.Expense{}).Where("claim_id = ?", claim.ID), conv)
+	if err != nil {
+		return err
+	}
+	if len(conv.missingCurrencies()) > 0 {
+		return errMissingRates
+	}
+	claim.Total, claim.Currency = total, conv.base
+	return tx.Model(claim).Updates(map[string]interface{}{"total": claim.Total, "currency": claim.Currency}).Error
+}
+
+// GetClaims 报销单列表，可按 status 过滤
+func GetClaims(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	query := database.GetDB().Where("user_id = ?", userID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var claims []models.ReimbursementClaim
+	if err := query.Order("created_at DESC, id DESC").Find(&claims).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, claims)
+}
+
+// GetClaim 报销单详情，包含其中的支出
+func GetClaim(c *gin.Context) {
+	claim, ok := loadOwnedClaim(c)
+	if !ok {
+		return
+	}
+
+	if err := database.GetDB().Where("claim_id = ?", claim.ID).Order("spent_at ASC, id ASC").Find(&claim.Expenses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, claim)
+}
+
+func CreateClaim(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req CreateClaimRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	expenses, ok := loadClaimableExpenses(c, req.ExpenseIDs, 0)
+	if !ok {
+		return
+	}
+
+	conv, err := loadCurrencyConverter(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	total, err := claimTotal(expenses, conv)
+	if err != nil {
+		writeClaimError(c, err, conv)
+		return
+	}
+
+	claim := models.ReimbursementClaim{
+		UserID:   userID,
+		Title:    req.Title,
+		Note:     req.Note,
+		Status:   reimbursePending,
+		Total:    total,
+		Currency: conv.base,
+	}
+
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&claim).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Expense{}).Where("id IN ?", req.ExpenseIDs).
+			Updates(map[string]interface{}{"claim_id": claim.ID, "reimburse_status": reimbursePending}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	for i := range expenses {
+		expenses[i].ClaimID = &claim.ID
+		expenses[i].ReimburseStatus = reimbursePending
+	}
+	claim.Expenses = expenses
+
+	c.JSON(http.StatusCreated, claim)
+}
+
+func UpdateClaim(c *gin.Context) {
+	claim, ok := loadOwnedClaim(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateClaimRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Title != "" {
+		updates["title"] = req.Title
+	}
+	if req.Note != nil {
+		updates["note"] = *req.Note
+	}
+
+	if req.ExpenseIDs != nil {
+		if claim.Status != reimbursePending && claim.Status != reimburseRejected {
+			c.JSON(http.StatusConflict, gin.H{"error": "only pending or rejected claims can change their expenses"})
+			return
+		}
+		if len(req.ExpenseIDs) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "claim needs at least one expense"})
+			return
+		}
+		if _, ok := loadClaimableExpenses(c, req.ExpenseIDs, claim.ID); !ok {
+			return
+		}
+	}
+
+	conv, err := loadCurrencyConverter(claim.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(claim).Updates(updates).Error; err != nil {
+			return err
+		}
+		if req.ExpenseIDs == nil {
+			return nil
+		}
+		// 移出的支出恢复为待报销
+		if err := tx.Model(&models.Expense{}).Where("claim_id = ? AND id NOT IN ?", claim.ID, req.ExpenseIDs).
+			Updates(map[string]interface{}{"claim_id": nil, "reimburse_status": reimbursePending}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Expense{}).Where("id IN ?", req.ExpenseIDs).
+			Updates(map[string]interface{}{"claim_id": claim.ID, "reimburse_status": claim.Status}).Error; err != nil {
+			return err
+		}
+		return refreshClaimTotal(tx, claim, conv)
+	})
+	if err != nil {
+		writeClaimError(c, err, conv)
+		return
+	}
+
+	c.JSON(http.StatusOK, claim)
+}
+
+// DeleteClaim 删除未到账的报销单，其中的支出恢复为待报销
+func DeleteClaim(c *gin.Context) {
+	claim, ok := loadOwnedClaim(c)
+	if !ok {
+		return
+	}
+
+	if claim.Status == reimburseReimbursed {
+		c.JSON(http.StatusConflict, gin.H{"error": "reimbursed claims cannot be deleted"})
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Expense{}).Where("claim_id = ?", claim.ID).
+			Updates(map[string]interface{}{"claim_id": nil, "reimburse_status": reimbursePending}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(claim).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "claim deleted"})
+}
+
+// transitionClaim 执行不涉及到账的状态变更（提交、驳回）
+func transitionClaim(c *gin.Context, action string) {
+	claim, ok := loadOwnedClaim(c)
+	if !ok {
+		return
+	}
+
+	next, ok := nextClaimStatus(claim.Status, action)
+	if !ok {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("cannot %s a %s claim", action, claim.Status)})
+		return
+	}
+
+	updates := map[string]interface{}{"status": next}
+	if action == "submit" {
+		updates["submitted_at"] = time.Now()
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(claim).Updates(updates).Error; err != nil {
+			return err
+		}
+		return setClaimExpenses(tx, claim.ID, next)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, claim)
+}
+
+func SubmitClaim(c *gin.Context) {
+	transitionClaim(c, "submit")
+}
+
+func RejectClaim(c *gin.Context) {
+	transitionClaim(c, "reject")
+}
+
+// PayClaim 标记报销单已到账，同时记录一笔来源为 reimbursement 的收入
+func PayClaim(c *gin.Context) {
+	claim, ok := loadOwnedClaim(c)
+	if !ok {
+		return
+	}
+
+	var req PayClaimRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	next, ok := nextClaimStatus(claim.Status, "pay")
+	if !ok {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("cannot pay a %s claim", claim.Status)})
+		return
+	}
+	if !validateAccountLink(c, claim.UserID, req.AccountID) {
+		return
+	}
+
+	paidAt := time.Now()
+	if req.PaidAt != "" {
+		t, err := parseSpentAt(req.PaidAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid paid_at, use RFC3339 or YYYY-MM-DD"})
+			return
+		}
+		paidAt = t
+	}
+	if req.Amount == 0 {
+		req.Amount = claim.Total
+	}
+	if req.Category == "" {
+		req.Category = fallbackIncomeCategory
+	}
+
+	externalID := fmt.Sprintf("%s:%d", reimbursementSource, claim.ID)
+	income := models.Expense{
+		UserID:     claim.UserID,
+		Type:       transactionIncome,
+		Amount:     req.Amount,
+		Currency:   claim.Currency, // 到账金额与报销单合计使用同一币种
+		Category:   req.Category,
+		Note:       "报销：" + claim.Title,
+		SpentAt:    paidAt,
+		Source:     reimbursementSource,
+		ExternalID: &externalID,
+	}
+	if req.AccountID != 0 {
+		income.AccountID = &req.AccountID
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&income).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(claim).Updates(map[string]interface{}{
+			"status":      next,
+			"paid_amount": req.Amount,
+			"paid_at":     paidAt,
+			"income_id":   income.ID,
+		}).Error; err != nil {
+			return err
+		}
+		return setClaimExpenses(tx, claim.ID, next)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	autoSetAside(&income)
+
+	c.JSON(http.StatusOK, gin.H{"claim": claim, "income": income})
+}
+
+// GetReimbursementSummary 各报销状态的支出笔数和换算为本位币的金额，outstanding 为尚未到账（待提交和已提交）的金额，
+// unclaimed 为还未加入报销单的金额
+func GetReimbursementSummary(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	conv, err := loadCurrencyConverter(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	reimbursable := database.GetDB().Model(&models.Expense{}).Where("user_id = ? AND reimbursable = ?", userID, true)
+	rows, err := convertedTotals(reimbursable, "reimburse_status", conv)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	unclaimed, _, err := convertedSum(reimbursable.Where("claim_id IS NULL"), conv)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	statuses := make([]ReimbursementStatusTotal, 0, 4)
+	var outstanding float64
+	for _, status := range []string{reimbursePending, reimburseSubmitted, reimburseReimbursed, reimburseRejected} {
+		item := ReimbursementStatusTotal{Status: status}
+		for _, r := range rows {
+			if r.Key == status {
+				item.Count, item.Total = r.Count, r.Total
+			}
+		}
+		if status == reimbursePending || status == reimburseSubmitted {
+			outstanding += item.Total
+		}
+		statuses = append(statuses, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"statuses":      statuses,
+		"outstanding":   round2(outstanding),
+		"unclaimed":     unclaimed,
+		"currency":      conv.base,
+		"missing_rates": conv.missingCurrencies(),
+	})
+}
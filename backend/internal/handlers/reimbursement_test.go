@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"daily-planner-backend/internal/models"
+)
+
+func TestNextClaimStatus(t *testing.T) {
+	cases := []struct {
+		current, action, want string
+		ok                    bool
+	}{
+		{reimbursePending, "submit", reimburseSubmitted, true},
+		{reimburseRejected, "submit", reimburseSubmitted, true},
+		{reimburseSubmitted, "reject", reimburseRejected, true},
+		{reimburseSubmitted, "pay", reimburseReimbursed, true},
+		{reimbursePending, "pay", "", false},
+		{reimbursePending, "reject", "", false},
+		{reimburseReimbursed, "submit", "", false},
+		{reimburseReimbursed, "reject", "", false},
+		{reimburseSubmitted, "approve", "", false},
+	}
+	for _, tc := range cases {
+		got, ok := nextClaimStatus(tc.current, tc.action)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("nextClaimStatus(%s, %s) = %q, %v; want %q, %v", tc.current, tc.action, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestClaimTotal(t *testing.T) {
+	conv := newCurrencyConverter("CNY", nil)
+	expenses := []models.Expense{{Amount: 0.1}, {Amount: 0.2}, {Amount: 128.5, Currency: "CNY"}}
+	if got, err := claimTotal(expenses, conv); err != nil || got != 128.8 {
+		t.Errorf("Expected 128.8, got %v, %v", got, err)
+	}
+	if got, err := claimTotal(nil, conv); err != nil || got != 0 {
+		t.Errorf("Expected 0 for empty claim, got %v, %v", got, err)
+	}
+}
+
+func TestClaimTotalConvertsCurrencies(t *testing.T) {
+	conv := newCurrencyConverter("CNY", []models.ExchangeRate{
+		{Currency: "USD", Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local), Rate: 7.2},
+		{Currency: "USD", Date: time.Date(2024, 3, 10, 0, 0, 0, 0, time.Local), Rate: 7.1},
+	})
+	expenses := []models.Expense{
+		{Amount: 100, Currency: "CNY", SpentAt: time.Date(2024, 3, 5, 12, 0, 0, 0, time.Local)},
+		{Amount: 10, Currency: "USD", SpentAt: time.Date(2024, 3, 5, 12, 0, 0, 0, time.Local)},
+		{Amount: 10, Currency: "USD", SpentAt: time.Date(2024, 3, 12, 12, 0, 0, 0, time.Local)},
+	}
+	if got, err := claimTotal(expenses, conv); err != nil || got != 243 {
+		t.Errorf("Expected 243 converted at each expense's rate, got %v, %v", got, err)
+	}
+
+	expenses = append(expenses, models.Expense{Amount: 5, Currency: "EUR", SpentAt: time.Date(2024, 3, 12, 0, 0, 0, 0, time.Local)})
+	if _, err := claimTotal(expenses, conv); !errors.Is(err, errMissingRates) {
+		t.Errorf("Expected errMissingRates, got %v", err)
+	}
+	if missing := conv.missingCurrencies(); len(missing) != 1 || missing[0] != "EUR" {
+		t.Errorf("Expected EUR to be reported missing, got %v", missing)
+	}
+}
@@ -0,0 +1,614 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"daily-planner-backend/internal/database"
+	"daily-planner-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 储蓄记录类型
+const (
+	savingsDeposit  = "deposit"
+	savingsWithdraw = "withdraw"
+)
+
+type CreateSavingsGoalRequest struct {
+	Name            string  `json:"name" binding:"required,max=100"`
+	Note            string  `json:"note" binding:"max=255"`
+	TargetAmount    float64 `json:"target_amount" binding:"required,gt=0"`
+	TargetDate      string  `json:"target_date" binding:"required"`
+	AutoSavePercent float64 `json:"auto_save_percent" binding:"gte=0,lte=100"`
+}
+
+type UpdateSavingsGoalRequest struct {
+	Name            string   `json:"name" binding:"max=100"`
+	Note            *string  `json:"note" binding:"omitempty,max=255"`
+	TargetAmount    float64  `json:"target_amount" binding:"omitempty,gt=0"`
+	TargetDate      string   `json:"target_date"`
+	AutoSavePercent *float64 `json:"auto_save_percent" binding:"omitempty,gte=0,lte=100"`
+	Archived        *bool    `json:"archived"`
+}
+
+type CreateSavingsEntryRequest struct {
+	Type       string  `json:"type" binding:"required,oneof=deposit withdraw"`
+	Amount     float64 `json:"amount" binding:"required,gt=0"`
+	Note       string  `json:"note" binding:"max=255"`
+	OccurredAt string  `json:"occurred_at"` // 缺省为当前时间
+}
+
+// SavingsProgress 储蓄进度
+type SavingsProgress struct {
+	Saved           float64 `json:"saved"`
+	Target          float64 `json:"target"`
+	Remaining       float64 `json:"remaining"`
+	Percent         float64 `json:"percent"`
+	MonthsLeft      int     `json:"months_left"`      // 含本月在内到目标月份的剩余月数
+	RequiredMonthly float64 `json:"required_monthly"` // 剩余各月每月需存入的金额
+	Achieved        bool    `json:"achieved"`
+	Overdue         bool    `json:"overdue"` // 已过目标日期仍未存够
+}
+
+// SavingsGoalView 储蓄目标及进度
+type SavingsGoalView struct {
+	models.SavingsGoal
+	Progress SavingsProgress `json:"progress"`
+}
+
+// computeSavingsProgress 根据已存金额计算完成度和每月需存金额，
+// 剩余月数含当前月，已过目标日期时剩余金额需在本月存完
+func computeSavingsProgress(goal *models.SavingsGoal, saved float64, now time.Time) SavingsProgress {
+	target := toCents(goal.TargetAmount)
+	current := toCents(saved)
+	remaining := target - current
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	p := SavingsProgress{
+		Saved:     fromCents(current),
+		Target:    goal.TargetAmount,
+		Remaining: fromCents(remaining),
+		Achieved:  remaining == 0,
+	}
+	if target > 0 && current > 0 {
+		percent := float64(current) / float64(target)
+		if percent > 1 {
+			percent = 1
+		}
+		p.Percent = round2(percent * 100)
+	}
+
+	months := (goal.TargetDate.Year()-now.Year())*12 + int(goal.TargetDate.Month()) - int(now.Month()) + 1
+	todayDate := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if months < 0 || goal.TargetDate.Before(todayDate) {
+		months = 0
+	}
+	p.MonthsLeft = months
+	p.Overdue = months == 0 && !p.Achieved
+
+	if remaining > 0 {
+		if months <= 1 {
+			p.RequiredMonthly = fromCents(remaining)
+		} else {
+			// 向上取整到分，按此金额每月存入即可按时存够
+			n := int64(months)
+			p.RequiredMonthly = fromCents((remaining + n - 1) / n)
+		}
+	}
+	return p
+}
+
+// setAsideAmounts 按各目标的自动存入比例计算一笔收入应存入的金额，
+// 已存够的目标不再存入，单个目标的存入不超过其剩余金额
+func setAsideAmounts(goals []models.SavingsGoal, saved map[uint]float64, income float64) map[uint]float64 {
+	amounts := make(map[uint]float64)
+	incomeCents := toCents(income)
+	for _, goal := range goals {
+		if goal.Archived || goal.AutoSavePercent <= 0 {
+			continue
+		}
+		remaining := toCents(goal.TargetAmount) - toCents(saved[goal.ID])
+		if remaining <= 0 {
+			continue
+		}
+		amount := toCents(fromCents(incomeCents) * goal.AutoSavePercent / 100)
+		if amount > remaining {
+			amount = remaining
+		}
+		if amount > 0 {
+			amounts[goal.ID] = fromCents(amount)
+		}
+	}
+	return amounts
+}
+
+// savingsBalances 统计各储蓄目标的已存金额（存入减取出）
+func savingsBalances(db *gorm.DB, goalIDs []uint) (map[uint]float64, error) {
+	balances := make(map[uint]float64)
+	if len(goalIDs) == 0 {
+		return balances, nil
+	}
+
+	var rows []struct {
+		GoalID uint
+		Total  float64
+	}
+	err := db.Model(&models.SavingsEntry{}).
+		Select("goal_id, COALESCE(SUM(CASE WHEN type = ? THEN amount ELSE -amount END), 0) AS total", savingsDeposit).
+		Where("goal_id IN ?", goalIDs).Group("goal_id").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		balances[r.GoalID] = round2(r.Total)
+	}
+	return balances, nil
+}
+
+func savingsGoalView(goal *models.SavingsGoal) (SavingsGoalView, error) {
+	balances, err := savingsBalances(database.GetDB(), []uint{goal.ID})
+	if err != nil {
+		return SavingsGoalView{}, err
+	}
+	return SavingsGoalView{SavingsGoal: *goal, Progress: computeSavingsProgress(goal, balances[goal.ID], time.Now())}, nil
+}
+
+func loadOwnedSavingsGoal(c *gin.Context) (*models.SavingsGoal, bool) {
+	var goal models.SavingsGoal
+	if err := database.GetDB().First(&goal, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return nil, false
+	}
+
+	if goal.UserID != c.GetUint("userID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return nil, false
+	}
+
+	return &goal, true
+}
+
+// validateAutoSavePercent 校验各目标的自动存入比例合计不超过 100%，excludeID 为正在修改的目标；失败时已写入响应
+func validateAutoSavePercent(c *gin.Context, userID, excludeID uint, percent float64) bool {
+	if percent <= 0 {
+		return true
+	}
+	var total float64
+	err := database.GetDB().Model(&models.SavingsGoal{}).
+		Where("user_id = ? AND id <> ? AND archived = ?", userID, excludeID, false).
+		Select("COALESCE(SUM(auto_save_percent), 0)").Scan(&total).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return false
+	}
+	if total+percent > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "total auto_save_percent of savings goals must not exceed 100"})
+		return false
+	}
+	return true
+}
+
+// setAsideRemoval 撤回一笔自动存入时可以删除的金额：已被取出的部分无法撤回，删除后已存金额不能为负
+func setAsideRemoval(amount, balance float64) float64 {
+	if balance <= 0 {
+		return 0
+	}
+	if toCents(amount) > toCents(balance) {
+		return balance
+	}
+	return amount
+}
+
+// removeSetAside 撤回收入的自动存入；超出已存金额的部分保留为不再关联收入的存入
+func removeSetAside(tx *gorm.DB, expenseID uint) error {
+	var entries []models.SavingsEntry
+	if err := tx.Where("source_expense_id = ?", expenseID).Order("id ASC").Find(&entries).Error; err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(entries))
+	for i, e := range entries {
+		ids[i] = e.GoalID
+	}
+	var goals []models.SavingsGoal
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", ids).Find(&goals).Error; err != nil {
+		return err
+	}
+	balances, err := savingsBalances(tx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		remove := setAsideRemoval(e.Amount, balances[e.GoalID])
+		balances[e.GoalID] = round2(balances[e.GoalID] - remove)
+		if toCents(remove) == toCents(e.Amount) {
+			if err := tx.Delete(&e).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if err := tx.Model(&e).Updates(map[string]interface{}{
+			"amount":            round2(e.Amount - remove),
+			"source_expense_id": nil,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resetsSetAside 修改收支时是否需要删除原有的自动存入并重新存入：
+// 收入的金额、币种或日期变化，或在收入和支出之间切换类型
+func resetsSetAside(expense *models.Expense, req *UpdateExpenseRequest) bool {
+	if expense.Type != transactionIncome && req.Type != transactionIncome {
+		return false
+	}
+	return (req.Type != "" && req.Type != expense.Type) ||
+		(req.Amount != 0 && req.Amount != expense.Amount) ||
+		(req.Currency != "" && req.Currency != expense.Currency) ||
+		req.SpentAt != ""
+}
+
+// autoSetAside 记录收入后按自动存入比例存入各储蓄目标，收入币种按当日汇率折合本位币，
+// 缺少汇率时跳过；存入记录关联来源收入，删除收入时一并撤回
+func autoSetAside(income *models.Expense) {
+	if income.Type != transactionIncome {
+		return
+	}
+
+	db := database.GetDB()
+	var goals []models.SavingsGoal
+	if err := db.Where("user_id = ? AND archived = ? AND auto_save_percent > 0", income.UserID, false).Find(&goals).Error; err != nil {
+		log.Printf("Failed to load savings goals of user %d: %v", income.UserID, err)
+		return
+	}
+	if len(goals) == 0 {
+		return
+	}
+
+	conv, err := loadCurrencyConverter(income.UserID)
+	if err != nil {
+		log.Printf("Failed to load exchange rates of user %d: %v", income.UserID, err)
+		return
+	}
+	amount, ok := conv.convert(income.Amount, income.Currency, income.SpentAt)
+	if !ok {
+		log.Printf("Failed to set aside income %d: no exchange rate for %s", income.ID, income.Currency)
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		ids := make([]uint, len(goals))
+		for i, g := range goals {
+			ids[i] = g.ID
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", ids).Find(&goals).Error; err != nil {
+			return err
+		}
+		balances, err := savingsBalances(tx, ids)
+		if err != nil {
+			return err
+		}
+		amounts := setAsideAmounts(goals, balances, amount)
+		for _, goal := range goals {
+			deposit, ok := amounts[goal.ID]
+			if !ok {
+				continue
+			}
+			entry := models.SavingsEntry{
+				GoalID:          goal.ID,
+				UserID:          income.UserID,
+				Type:            savingsDeposit,
+				Amount:          deposit,
+				Note:            truncateRunes("自动存入："+income.Category+" "+income.Note, 255),
+				SourceExpenseID: &income.ID,
+				OccurredAt:      income.SpentAt,
+			}
+			if err := tx.Create(&entry).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to set aside income %d: %v", income.ID, err)
+	}
+}
+
+func GetSavingsGoals(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	query := database.GetDB().Where("user_id = ?", userID)
+	if c.Query("archived") != "true" {
+		query = query.Where("archived = ?", false)
+	}
+
+	var goals []models.SavingsGoal
+	if err := query.Order("target_date ASC").Find(&goals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	ids := make([]uint, len(goals))
+	for i, g := range goals {
+		ids[i] = g.ID
+	}
+	balances, err := savingsBalances(database.GetDB(), ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	now := time.Now()
+	views := make([]SavingsGoalView, len(goals))
+	for i := range goals {
+		views[i] = SavingsGoalView{SavingsGoal: goals[i], Progress: computeSavingsProgress(&goals[i], balances[goals[i].ID], now)}
+	}
+
+	c.JSON(http.StatusOK, views)
+}
+
+// GetSavingsGoal 储蓄目标详情，附带存取记录
+func GetSavingsGoal(c *gin.Context) {
+	goal, ok := loadOwnedSavingsGoal(c)
+	if !ok {
+		return
+	}
+
+	view, err := savingsGoalView(goal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	var entries []models.SavingsEntry
+	if err := database.GetDB().Where("goal_id = ?", goal.ID).Order("occurred_at DESC, id DESC").Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"goal":    view,
+		"entries": entries,
+	})
+}
+
+func CreateSavingsGoal(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req CreateSavingsGoalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	targetDate, err := time.ParseInLocation(dayLayout, req.TargetDate, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+		return
+	}
+	if !validateAutoSavePercent(c, userID, 0, req.AutoSavePercent) {
+		return
+	}
+
+	goal := models.SavingsGoal{
+		UserID:          userID,
+		Name:            req.Name,
+		Note:            req.Note,
+		TargetAmount:    round2(req.TargetAmount),
+		TargetDate:      targetDate,
+		AutoSavePercent: req.AutoSavePercent,
+	}
+
+	if err := database.GetDB().Create(&goal).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, SavingsGoalView{SavingsGoal: goal, Progress: computeSavingsProgress(&goal, 0, time.Now())})
+}
+
+func UpdateSavingsGoal(c *gin.Context) {
+	goal, ok := loadOwnedSavingsGoal(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateSavingsGoalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Name != "" {
+		updates["name"] = req.Name
+	}
+	if req.Note != nil {
+		updates["note"] = *req.Note
+	}
+	if req.TargetAmount != 0 {
+		updates["target_amount"] = round2(req.TargetAmount)
+	}
+	if req.TargetDate != "" {
+		targetDate, err := time.ParseInLocation(dayLayout, req.TargetDate, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+			return
+		}
+		updates["target_date"] = targetDate
+	}
+	if req.AutoSavePercent != nil {
+		if !validateAutoSavePercent(c, goal.UserID, goal.ID, *req.AutoSavePercent) {
+			return
+		}
+		updates["auto_save_percent"] = *req.AutoSavePercent
+	}
+	if req.Archived != nil {
+		updates["archived"] = *req.Archived
+	}
+
+	if err := database.GetDB().Model(goal).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	view, err := savingsGoalView(goal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, view)
+}
+
+// DeleteSavingsGoal 删除储蓄目标及其存取记录
+func DeleteSavingsGoal(c *gin.Context) {
+	goal, ok := loadOwnedSavingsGoal(c)
+	if !ok {
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("goal_id = ?", goal.ID).Delete(&models.SavingsEntry{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(goal).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "savings goal deleted"})
+}
+
+// CreateSavingsEntry 向储蓄目标存入或取出，取出金额不能超过已存金额
+func CreateSavingsEntry(c *gin.Context) {
+	goal, ok := loadOwnedSavingsGoal(c)
+	if !ok {
+		return
+	}
+
+	var req CreateSavingsEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	occurredAt := time.Now()
+	if req.OccurredAt != "" {
+		t, err := parseSpentAt(req.OccurredAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid occurred_at format"})
+			return
+		}
+		occurredAt = t
+	}
+
+	entry := models.SavingsEntry{
+		GoalID:     goal.ID,
+		UserID:     goal.UserID,
+		Type:       req.Type,
+		Amount:     round2(req.Amount),
+		Note:       req.Note,
+		OccurredAt: occurredAt,
+	}
+
+	insufficient := false
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		// 锁定目标，避免并发取出超过已存金额
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.SavingsGoal{}, goal.ID).Error; err != nil {
+			return err
+		}
+		if entry.Type == savingsWithdraw {
+			balances, err := savingsBalances(tx, []uint{goal.ID})
+			if err != nil {
+				return err
+			}
+			if toCents(entry.Amount) > toCents(balances[goal.ID]) {
+				insufficient = true
+				return nil
+			}
+		}
+		return tx.Create(&entry).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if insufficient {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "withdrawal exceeds saved amount"})
+		return
+	}
+
+	view, err := savingsGoalView(goal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"entry": entry,
+		"goal":  view,
+	})
+}
+
+// DeleteSavingsEntry 删除一笔存取记录，删除存入后余额不能为负
+func DeleteSavingsEntry(c *gin.Context) {
+	goal, ok := loadOwnedSavingsGoal(c)
+	if !ok {
+		return
+	}
+
+	var entry models.SavingsEntry
+	if err := database.GetDB().Where("goal_id = ?", goal.ID).First(&entry, c.Param("entry_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "resource not found"})
+		return
+	}
+
+	negative := false
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.SavingsGoal{}, goal.ID).Error; err != nil {
+			return err
+		}
+		if entry.Type == savingsDeposit {
+			balances, err := savingsBalances(tx, []uint{goal.ID})
+			if err != nil {
+				return err
+			}
+			if toCents(entry.Amount) > toCents(balances[goal.ID]) {
+				negative = true
+				return nil
+			}
+		}
+		return tx.Delete(&entry).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if negative {
+		c.JSON(http.StatusConflict, gin.H{"error": "saved amount would become negative"})
+		return
+	}
+
+	view, err := savingsGoalView(goal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, view)
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"daily-planner-backend/internal/models"
+
+	"pgregory.net/rapid"
+)
+
+func TestComputeSavingsProgress(t *testing.T) {
+	goal := models.SavingsGoal{
+		TargetAmount: 10000,
+		TargetDate:   time.Date(2024, 6, 30, 0, 0, 0, 0, time.Local),
+	}
+	now := time.Date(2024, 3, 10, 9, 0, 0, 0, time.Local)
+
+	// 3 月到 6 月共 4 个月，剩余 7000
+	p := computeSavingsProgress(&goal, 3000, now)
+	if p.Percent != 30 || p.Remaining != 7000 || p.MonthsLeft != 4 || p.RequiredMonthly != 1750 || p.Achieved || p.Overdue {
+		t.Errorf("Unexpected progress: %+v", p)
+	}
+
+	// 不能整除时向上取整到分
+	odd := computeSavingsProgress(&models.SavingsGoal{TargetAmount: 100, TargetDate: goal.TargetDate}, 0, time.Date(2024, 4, 1, 0, 0, 0, 0, time.Local))
+	if odd.MonthsLeft != 3 || odd.RequiredMonthly != 33.34 {
+		t.Errorf("Expected required monthly to round up: %+v", odd)
+	}
+
+	overdue := computeSavingsProgress(&goal, 9000, time.Date(2024, 7, 1, 0, 0, 0, 0, time.Local))
+	if !overdue.Overdue || overdue.MonthsLeft != 0 || overdue.RequiredMonthly != 1000 {
+		t.Errorf("Expected overdue goal to require the whole remainder: %+v", overdue)
+	}
+
+	// 目标日期当天仍在期限内
+	lastDay := computeSavingsProgress(&goal, 9000, time.Date(2024, 6, 30, 20, 0, 0, 0, time.Local))
+	if lastDay.Overdue || lastDay.MonthsLeft != 1 {
+		t.Errorf("Expected target date itself not to be overdue: %+v", lastDay)
+	}
+
+	done := computeSavingsProgress(&goal, 12000, now)
+	if !done.Achieved || done.Percent != 100 || done.Remaining != 0 || done.RequiredMonthly != 0 {
+		t.Errorf("Expected achieved goal: %+v", done)
+	}
+}
+
+func TestSetAsideAmounts(t *testing.T) {
+	goals := []models.SavingsGoal{
+		{ID: 1, TargetAmount: 5000, AutoSavePercent: 10},
+		{ID: 2, TargetAmount: 1000, AutoSavePercent: 20},
+		{ID: 3, TargetAmount: 800, AutoSavePercent: 5},
+		{ID: 4, TargetAmount: 800},
+		{ID: 5, TargetAmount: 800, AutoSavePercent: 30, Archived: true},
+	}
+	saved := map[uint]float64{2: 950, 3: 800}
+
+	amounts := setAsideAmounts(goals, saved, 1234.5)
+	if len(amounts) != 2 || amounts[1] != 123.45 || amounts[2] != 50 {
+		t.Errorf("Unexpected set-aside amounts: %v", amounts)
+	}
+}
+
+func TestSetAsideRemovalAfterWithdrawal(t *testing.T) {
+	// 收入自动存入 100，之后取出 60，已存 40：删除收入只能撤回 40，余下 60 保留
+	if got := setAsideRemoval(100, 40); got != 40 {
+		t.Errorf("Expected to remove only the remaining 40, got %v", got)
+	}
+	if got := setAsideRemoval(100, 250); got != 100 {
+		t.Errorf("Expected the whole deposit to be removable, got %v", got)
+	}
+	if got := setAsideRemoval(100, 0); got != 0 {
+		t.Errorf("Expected nothing to be removable from an emptied goal, got %v", got)
+	}
+}
+
+func TestResetsSetAside(t *testing.T) {
+	income := models.Expense{Type: transactionIncome, Amount: 5000, Currency: "CNY"}
+	cases := []struct {
+		name    string
+		expense models.Expense
+		req     UpdateExpenseRequest
+		want    bool
+	}{
+		{"note only", income, UpdateExpenseRequest{Note: "工资"}, false},
+		{"same amount", income, UpdateExpenseRequest{Amount: 5000, Currency: "CNY"}, false},
+		{"amount", income, UpdateExpenseRequest{Amount: 6000}, true},
+		{"currency", income, UpdateExpenseRequest{Currency: "USD"}, true},
+		{"date", income, UpdateExpenseRequest{SpentAt: "2024-03-01"}, true},
+		{"income to expense", income, UpdateExpenseRequest{Type: transactionExpense}, true},
+		{"expense to income", models.Expense{Type: transactionExpense, Amount: 50}, UpdateExpenseRequest{Type: transactionIncome}, true},
+		{"expense amount", models.Expense{Type: transactionExpense, Amount: 50}, UpdateExpenseRequest{Amount: 60}, false},
+	}
+	for _, tc := range cases {
+		if got := resetsSetAside(&tc.expense, &tc.req); got != tc.want {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+// **Feature: savings, Property 1: Saving the required monthly amount reaches the target in time**
+func TestRequiredMonthlyReachesTarget(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		target := float64(rapid.IntRange(1, 10000000).Draw(t, "targetCents")) / 100
+		saved := float64(rapid.IntRange(0, 10000000).Draw(t, "savedCents")) / 100
+		months := rapid.IntRange(0, 60).Draw(t, "months")
+
+		now := time.Date(2024, 1, 15, 0, 0, 0, 0, time.Local)
+		goal := models.SavingsGoal{TargetAmount: target, TargetDate: now.AddDate(0, months, 0)}
+
+		p := computeSavingsProgress(&goal, saved, now)
+		if p.MonthsLeft != months+1 {
+			t.Fatalf("Expected %d months left, got %+v", months+1, p)
+		}
+		total := toCents(saved) + toCents(p.RequiredMonthly)*int64(p.MonthsLeft)
+		if total < toCents(target) {
+			t.Fatalf("Required monthly %.2f does not reach target: %+v", p.RequiredMonthly, p)
+		}
+		if p.RequiredMonthly > 0 && total-toCents(target) >= int64(p.MonthsLeft) {
+			t.Fatalf("Required monthly %.2f overshoots target: %+v", p.RequiredMonthly, p)
+		}
+	})
+}
+
+// **Feature: savings, Property 2: Withdrawing a set-aside never leaves a negative saved amount**
+func TestSetAsideRemovalKeepsBalanceNonNegative(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		deposit := rapid.Int64Range(1, 1000000).Draw(t, "deposit")
+		other := rapid.Int64Range(0, 1000000).Draw(t, "other")
+		withdrawn := rapid.Int64Range(0, deposit+other).Draw(t, "withdrawn")
+		balance := fromCents(deposit + other - withdrawn)
+
+		remove := toCents(setAsideRemoval(fromCents(deposit), balance))
+		if remove < 0 || remove > deposit {
+			t.Fatalf("Removal %d outside deposit %d", remove, deposit)
+		}
+		if toCents(balance)-remove < 0 {
+			t.Fatalf("Balance %v becomes negative after removing %d", balance, remove)
+		}
+		if toCents(balance) >= deposit && remove != deposit {
+			t.Fatalf("Expected the whole deposit to be removed when covered, got %d", remove)
+		}
+	})
+}
@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"html"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"daily-planner-backend/internal/database"
+	"daily-planner-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	searchTypePlan     = "plan"
+	searchTypeExpense  = "expense"
+	searchTypeReminder = "reminder"
+
+	// 每种类型从数据库取出的候选数量上限
+	searchCandidateLimit = 200
+	searchSnippetRunes   = 120
+)
+
+// SearchHit 搜索结果项
+type SearchHit struct {
+	Type      string      `json:"type"`
+	ID        uint        `json:"id"`
+	Field     string      `json:"field"`
+	Highlight string      `json:"highlight"`
+	Score     float64     `json:"score"`
+	Date      time.Time   `json:"date"`
+	Item      interface{} `json:"item"`
+}
+
+// tokenizeSearchText 分词：拉丁字母和数字按单词切分并转小写，连续汉字切为二元组
+// （与 MySQL ngram 解析器的默认 ngram_token_size=2 保持一致），单个汉字保留为一元
+func tokenizeSearchText(s string) []string {
+	var (
+		tokens []string
+		word   []rune
+		han    []rune
+	)
+
+	flushWord := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+			word = word[:0]
+		}
+	}
+	flushHan := func() {
+		switch {
+		case len(han) == 1:
+			tokens = append(tokens, string(han))
+		case len(han) > 1:
+			for i := 0; i+1 < len(han); i++ {
+				tokens = append(tokens, string(han[i:i+2]))
+			}
+		}
+		han = han[:0]
+	}
+
+	for _, r := range s {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushHan()
+			word = append(word, unicode.ToLower(r))
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+
+	return tokens
+}
+
+// uniqueSearchTokens 查询分词去重并保持顺序
+func uniqueSearchTokens(q string) []string {
+	seen := make(map[string]bool)
+	var tokens []string
+	for _, t := range tokenizeSearchText(q) {
+		if !seen[t] {
+			seen[t] = true
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+// scoreSearchText 计算文本与查询的相关度：词频取对数累加，再乘以查询词覆盖率，整句命中额外加分
+func scoreSearchText(text, query string, queryTokens []string) float64 {
+	if text == "" || len(queryTokens) == 0 {
+		return 0
+	}
+
+	docTokens := tokenizeSearchText(text)
+	var score float64
+	matched := 0
+	for _, qt := range queryTokens {
+		tf := 0
+		for _, dt := range docTokens {
+			// 拉丁单词允许前缀匹配，如 taxi 命中 taxis
+			if dt == qt || (!isHanToken(qt) && strings.HasPrefix(dt, qt)) {
+				tf++
+			}
+		}
+		if tf > 0 {
+			matched++
+			score += 1 + math.Log(float64(tf))
+		}
+	}
+	if matched == 0 {
+		return 0
+	}
+
+	score *= float64(matched) / float64(len(queryTokens))
+	if strings.Contains(strings.ToLower(text), strings.ToLower(strings.TrimSpace(query))) {
+		score += 1
+	}
+	return math.Round(score*1000) / 1000
+}
+
+func isHanToken(t string) bool {
+	for _, r := range t {
+		return unicode.Is(unicode.Han, r)
+	}
+	return false
+}
+
+// highlightSearchText 用 <em></em> 标出命中片段，过长文本截取首个命中附近的摘要；原文经 HTML 转义，可以直接作为 HTML 渲染
+func highlightSearchText(text, query string, queryTokens []string) string {
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	marked := make([]bool, len(runes))
+	needles := append([]string{strings.ToLower(strings.TrimSpace(query))}, queryTokens...)
+	for _, needle := range needles {
+		n := []rune(needle)
+		if len(n) == 0 {
+			continue
+		}
+		for i := 0; i+len(n) <= len(lower); i++ {
+			if string(lower[i:i+len(n)]) == needle {
+				for j := i; j < i+len(n); j++ {
+					marked[j] = true
+				}
+			}
+		}
+	}
+
+	start, end := 0, len(runes)
+	if len(runes) > searchSnippetRunes {
+		first := 0
+		for i, m := range marked {
+			if m {
+				first = i
+				break
+			}
+		}
+		start = first - searchSnippetRunes/3
+		if start < 0 {
+			start = 0
+		}
+		end = start + searchSnippetRunes
+		if end > len(runes) {
+			end = len(runes)
+			start = end - searchSnippetRunes
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; i++ {
+		if marked[i] && (i == start || !marked[i-1]) {
+			b.WriteString("<em>")
+		}
+		b.WriteString(html.EscapeString(string(runes[i])))
+		if marked[i] && (i == end-1 || !marked[i+1]) {
+			b.WriteString("</em>")
+		}
+	}
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// applySearchMatch 使用全文索引检索候选；不可用或查询词过短时退化为 LIKE
+func applySearchMatch(query *gorm.DB, columns []string, tokens []string) *gorm.DB {
+	var ftTerms []string
+	for _, t := range tokens {
+		if len([]rune(t)) >= 2 {
+			ftTerms = append(ftTerms, `"`+t+`"`)
+		}
+	}
+
+	if database.FullTextEnabled() && len(ftTerms) > 0 {
+		match := "MATCH(" + strings.Join(columns, ", ") + ") AGAINST (? IN BOOLEAN MODE)"
+		against := strings.Join(ftTerms, " ")
+		return query.Where(match, against).Clauses(clause.OrderBy{
+			Expression: clause.Expr{SQL: match + " DESC", Vars: []interface{}{against}, WithoutParentheses: true},
+		})
+	}
+
+	var conds []string
+	var args []interface{}
+	for _, t := range tokens {
+		for _, col := range columns {
+			conds = append(conds, col+" LIKE ?")
+			args = append(args, "%"+t+"%")
+		}
+	}
+	return query.Where("("+strings.Join(conds, " OR ")+")", args...).Order("updated_at DESC")
+}
+
+// Search 在计划、支出和提醒中全文搜索，按相关度排序并返回高亮片段
+func Search(c *gin.Context) {
+	userID := c.GetUint("userID")
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query parameter q is required"})
+		return
+	}
+
+	tokens := uniqueSearchTokens(q)
+	if len(tokens) == 0 {
+		c.JSON(http.StatusOK, gin.H{"query": q, "total": 0, "results": []SearchHit{}})
+		return
+	}
+
+	types := map[string]bool{searchTypePlan: true, searchTypeExpense: true, searchTypeReminder: true}
+	if typesStr := c.Query("types"); typesStr != "" {
+		types = make(map[string]bool)
+		for _, t := range strings.Split(typesStr, ",") {
+			t = strings.TrimSpace(t)
+			if t != searchTypePlan && t != searchTypeExpense && t != searchTypeReminder {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid type, use plan, expense or reminder"})
+				return
+			}
+			types[t] = true
+		}
+	}
+
+	limit := 20
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if n, err := strconv.Atoi(limitStr); err == nil && n > 0 && n <= 100 {
+			limit = n
+		}
+	}
+
+	db := database.GetDB()
+	hits := []SearchHit{}
+
+	if types[searchTypePlan] {
+		var plans []models.Plan
+		query := applySearchMatch(db.Where("user_id = ?", userID), []string{"content"}, tokens)
+		if err := query.Limit(searchCandidateLimit).Find(&plans).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		for _, p := range plans {
+			if score := scoreSearchText(p.Content, q, tokens); score > 0 {
+				hits = append(hits, SearchHit{
+					Type: searchTypePlan, ID: p.ID, Field: "content",
+					Highlight: highlightSearchText(p.Content, q, tokens),
+					Score:     score, Date: p.ExecutionDate, Item: p,
+				})
+			}
+		}
+	}
+
+	if types[searchTypeExpense] {
+		var expenses []models.Expense
+		query := applySearchMatch(db.Where("user_id = ?", userID), []string{"note", "category"}, tokens)
+		if err := query.Limit(searchCandidateLimit).Find(&expenses).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		for _, e := range expenses {
+			noteScore := scoreSearchText(e.Note, q, tokens)
+			categoryScore := scoreSearchText(e.Category, q, tokens)
+			if noteScore+categoryScore == 0 {
+				continue
+			}
+			hit := SearchHit{
+				Type: searchTypeExpense, ID: e.ID, Field: "note",
+				Highlight: highlightSearchText(e.Note, q, tokens),
+				Score:     noteScore + categoryScore, Date: e.SpentAt, Item: e,
+			}
+			if categoryScore > noteScore {
+				hit.Field = "category"
+				hit.Highlight = highlightSearchText(e.Category, q, tokens)
+			}
+			hits = append(hits, hit)
+		}
+	}
+
+	if types[searchTypeReminder] {
+		var reminders []models.Reminder
+		query := applySearchMatch(db.Where("user_id = ?", userID), []string{"content"}, tokens)
+		if err := query.Limit(searchCandidateLimit).Find(&reminders).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		for _, r := range reminders {
+			if score := scoreSearchText(r.Content, q, tokens); score > 0 {
+				hits = append(hits, SearchHit{
+					Type: searchTypeReminder, ID: r.ID, Field: "content",
+					Highlight: highlightSearchText(r.Content, q, tokens),
+					Score:     score, Date: r.UpdatedAt, Item: r,
+				})
+			}
+		}
+	}
+
+	// 相关度相同时较新的记录排在前面
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Date.After(hits[j].Date)
+	})
+
+	total := len(hits)
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"query":   q,
+		"total":   total,
+		"results": hits,
+	})
+}
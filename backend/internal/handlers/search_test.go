@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"pgregory.net/rapid"
+)
+
+func TestTokenizeSearchText(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{"Taxi to Airport", []string{"taxi", "to", "airport"}},
+		{"看牙医", []string{"看牙", "牙医"}},
+		{"美团外卖 25元", []string{"美团", "团外", "外卖", "25", "元"}},
+		{"去 dentist,复查", []string{"去", "dentist", "复查"}},
+		{"  ", nil},
+	}
+
+	for _, tc := range testCases {
+		got := tokenizeSearchText(tc.input)
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("tokenize(%q): expected %v, got %v", tc.input, tc.expected, got)
+		}
+	}
+}
+
+// **Feature: search, Property 1: Text containing the query always scores above zero**
+func TestSearchScoreContainsQuery(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		query := rapid.StringMatching(`[a-z\x{4e00}-\x{9fa5}]{2,6}`).Draw(t, "query")
+		prefix := rapid.StringMatching(`[a-z \x{4e00}-\x{9fa5}]{0,20}`).Draw(t, "prefix")
+		suffix := rapid.StringMatching(`[a-z \x{4e00}-\x{9fa5}]{0,20}`).Draw(t, "suffix")
+		text := prefix + " " + query + " " + suffix
+
+		tokens := uniqueSearchTokens(query)
+		if score := scoreSearchText(text, query, tokens); score <= 0 {
+			t.Fatalf("Expected positive score for %q in %q, got %v", query, text, score)
+		}
+
+		// Property: highlight keeps the original text once markup is removed
+		highlight := highlightSearchText(text, query, tokens)
+		plain := strings.NewReplacer("<em>", "", "</em>", "").Replace(highlight)
+		if plain != text {
+			t.Fatalf("Highlight altered text: %q -> %q", text, plain)
+		}
+		if !strings.Contains(highlight, "<em>") {
+			t.Fatalf("Expected highlight markup in %q", highlight)
+		}
+	})
+}
+
+func TestHighlightSearchTextEscapesHTML(t *testing.T) {
+	text := `看牙医<script>alert("x")</script> & 复查`
+	query := "牙医"
+	got := highlightSearchText(text, query, uniqueSearchTokens(query))
+
+	want := `看<em>牙医</em>&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; &amp; 复查`
+	if got != want {
+		t.Errorf("Expected escaped highlight %q, got %q", want, got)
+	}
+	if strings.Contains(got, "<script>") {
+		t.Errorf("Highlight must not contain raw markup from content: %q", got)
+	}
+
+	// 命中片段本身也要转义
+	got = highlightSearchText("a<b>c", "<b>", uniqueSearchTokens("<b>"))
+	if strings.Contains(got, "<b>") {
+		t.Errorf("Expected matched markup to be escaped, got %q", got)
+	}
+}
+
+func TestSearchScoreRanksFullMatchHigher(t *testing.T) {
+	query := "taxi airport"
+	tokens := uniqueSearchTokens(query)
+
+	full := scoreSearchText("Taxi to airport", query, tokens)
+	partial := scoreSearchText("Taxi home", query, tokens)
+	none := scoreSearchText("Lunch", query, tokens)
+
+	if !(full > partial && partial > 0 && none == 0) {
+		t.Fatalf("Unexpected ranking: full=%v partial=%v none=%v", full, partial, none)
+	}
+}
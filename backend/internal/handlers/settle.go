@@ -0,0 +1,500 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"daily-planner-backend/internal/database"
+	"daily-planner-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 分摊方式
+const (
+	splitEqual = "equal"
+	splitShare = "share"
+	splitExact = "exact"
+)
+
+// settleExactLimit 有余额的成员不超过这个数量时精确求解最少转账笔数，否则使用贪心匹配
+const settleExactLimit = 12
+
+// SplitParticipant 参与分摊的成员，share 用于按份数分摊，amount 用于按金额分摊
+type SplitParticipant struct {
+	UserID uint    `json:"user_id" binding:"required"`
+	Share  float64 `json:"share" binding:"min=0"`
+	Amount float64 `json:"amount" binding:"min=0"`
+}
+
+// SplitRequest 共享支出的分摊方式，participants 为空时由全体成员平均分摊
+type SplitRequest struct {
+	Method       string             `json:"method" binding:"required,oneof=equal share exact"`
+	Participants []SplitParticipant `json:"participants" binding:"dive"`
+}
+
+type CreateSettlementRequest struct {
+	ToUserID  uint    `json:"to_user_id" binding:"required"`
+	Amount    float64 `json:"amount" binding:"required,gt=0"`
+	Note      string  `json:"note" binding:"max=255"`
+	SettledAt string  `json:"settled_at"`
+}
+
+// SettleTransfer 结清余额所需的一笔转账
+type SettleTransfer struct {
+	FromUserID uint    `json:"from_user_id"`
+	ToUserID   uint    `json:"to_user_id"`
+	Amount     float64 `json:"amount"`
+}
+
+// MemberBalance 成员在账本中的余额，正数表示别人欠他
+type MemberBalance struct {
+	UserID   uint    `json:"user_id"`
+	Username string  `json:"username"`
+	Paid     float64 `json:"paid"`
+	Owed     float64 `json:"owed"`
+	Balance  float64 `json:"balance"`
+}
+
+var errInvalidSplit = errors.New("invalid split")
+
+func toCents(v float64) int64 {
+	return int64(math.Round(v * 100))
+}
+
+func fromCents(c int64) float64 {
+	return float64(c) / 100
+}
+
+// computeSplits 计算每个参与者应承担的金额（分），除不尽的零头依次分给排在前面的参与者
+func computeSplits(total int64, method string, parts []SplitParticipant) ([]int64, error) {
+	if len(parts) == 0 {
+		return nil, errInvalidSplit
+	}
+	amounts := make([]int64, len(parts))
+
+	switch method {
+	case splitEqual:
+		base, rem := total/int64(len(parts)), total%int64(len(parts))
+		for i := range parts {
+			amounts[i] = base
+			if int64(i) < rem {
+				amounts[i]++
+			}
+		}
+	case splitShare:
+		var sum float64
+		for _, p := range parts {
+			if p.Share <= 0 {
+				return nil, errInvalidSplit
+			}
+			sum += p.Share
+		}
+		var assigned int64
+		for i, p := range parts {
+			amounts[i] = int64(math.Floor(float64(total) * p.Share / sum))
+			assigned += amounts[i]
+		}
+		for i := 0; assigned < total; i = (i + 1) % len(parts) {
+			amounts[i]++
+			assigned++
+		}
+	case splitExact:
+		var sum int64
+		for i, p := range parts {
+			amounts[i] = toCents(p.Amount)
+			sum += amounts[i]
+		}
+		if sum != total {
+			return nil, errInvalidSplit
+		}
+	default:
+		return nil, errInvalidSplit
+	}
+	return amounts, nil
+}
+
+// buildExpenseSplits 校验参与者都是账本成员并生成分摊记录；失败时已写入响应
+func buildExpenseSplits(c *gin.Context, ledger *models.Ledger, amount float64, req *SplitRequest) ([]models.ExpenseSplit, bool) {
+	members, err := ledgerMemberIDs(ledger)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return nil, false
+	}
+
+	split := SplitRequest{Method: splitEqual}
+	if req != nil {
+		split = *req
+	}
+	if len(split.Participants) == 0 {
+		if split.Method != splitEqual {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "participants are required for share and exact splits"})
+			return nil, false
+		}
+		for _, id := range members {
+			split.Participants = append(split.Participants, SplitParticipant{UserID: id})
+		}
+	}
+
+	isMember := make(map[uint]bool, len(members))
+	for _, id := range members {
+		isMember[id] = true
+	}
+	seen := make(map[uint]bool, len(split.Participants))
+	for _, p := range split.Participants {
+		if !isMember[p.UserID] || seen[p.UserID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "participants must be distinct ledger members"})
+			return nil, false
+		}
+		seen[p.UserID] = true
+	}
+
+	amounts, err := computeSplits(toCents(amount), split.Method, split.Participants)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid split: shares must be positive and exact amounts must add up to the expense amount"})
+		return nil, false
+	}
+
+	splits := make([]models.ExpenseSplit, len(split.Participants))
+	for i, p := range split.Participants {
+		share := p.Share
+		switch split.Method {
+		case splitEqual:
+			share = 1
+		case splitExact:
+			share = 0
+		}
+		splits[i] = models.ExpenseSplit{UserID: p.UserID, Share: share, Amount: fromCents(amounts[i])}
+	}
+	return splits, true
+}
+
+// resplitByShares 金额变化后按原有份数重新分摊，按金额分摊的支出无法自动重算
+func resplitByShares(splits []models.ExpenseSplit, amount float64) ([]models.ExpenseSplit, error) {
+	parts := make([]SplitParticipant, len(splits))
+	for i, s := range splits {
+		if s.Share <= 0 {
+			return nil, errInvalidSplit
+		}
+		parts[i] = SplitParticipant{UserID: s.UserID, Share: s.Share}
+	}
+	amounts, err := computeSplits(toCents(amount), splitShare, parts)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]models.ExpenseSplit, len(splits))
+	for i, s := range splits {
+		result[i] = models.ExpenseSplit{ExpenseID: s.ExpenseID, UserID: s.UserID, Share: s.Share, Amount: fromCents(amounts[i])}
+	}
+	return result, nil
+}
+
+// minimalTransfers 计算使所有余额（分）归零的最少转账。
+// 最少笔数等于有余额人数减去能划分出的和为零的最多分组数；人数较少时用状态压缩精确求解，
+// 每组内再按贪心匹配，否则直接对全体贪心匹配
+func minimalTransfers(balances map[uint]int64) []SettleTransfer {
+	var ids []uint
+	for id, b := range balances {
+		if b != 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	if len(ids) > settleExactLimit {
+		return greedyTransfers(ids, balances)
+	}
+
+	n := len(ids)
+	full := 1<<n - 1
+	sums := make([]int64, full+1)
+	for mask := 1; mask <= full; mask++ {
+		low := mask & -mask
+		i := 0
+		for 1<<i != low {
+			i++
+		}
+		sums[mask] = sums[mask^low] + balances[ids[i]]
+	}
+
+	// dp[mask] 为从空集逐个加入 mask 中元素时，途经和为零的子集的最多个数
+	dp := make([]int, full+1)
+	prev := make([]int, full+1)
+	for mask := 1; mask <= full; mask++ {
+		dp[mask] = -1
+		for i := 0; i < n; i++ {
+			if mask&(1<<i) == 0 {
+				continue
+			}
+			if v := dp[mask^(1<<i)]; v > dp[mask] {
+				dp[mask] = v
+				prev[mask] = mask ^ (1 << i)
+			}
+		}
+		if sums[mask] == 0 {
+			dp[mask]++
+		}
+	}
+
+	var transfers []SettleTransfer
+	groupEnd := full
+	for mask := full; mask != 0; {
+		next := prev[mask]
+		if next == 0 || sums[next] == 0 {
+			var group []uint
+			for i := 0; i < n; i++ {
+				if (groupEnd^next)&(1<<i) != 0 {
+					group = append(group, ids[i])
+				}
+			}
+			transfers = append(transfers, greedyTransfers(group, balances)...)
+			groupEnd = next
+		}
+		mask = next
+	}
+	return transfers
+}
+
+// greedyTransfers 每次让欠款最多的人向应收最多的人转账
+func greedyTransfers(ids []uint, balances map[uint]int64) []SettleTransfer {
+	remaining := make(map[uint]int64, len(ids))
+	for _, id := range ids {
+		remaining[id] = balances[id]
+	}
+
+	var transfers []SettleTransfer
+	for {
+		var debtor, creditor uint
+		var minBal, maxBal int64
+		for _, id := range ids {
+			if b := remaining[id]; b < minBal {
+				debtor, minBal = id, b
+			} else if b > maxBal {
+				creditor, maxBal = id, b
+			}
+		}
+		if minBal == 0 || maxBal == 0 {
+			return transfers
+		}
+
+		amount := -minBal
+		if maxBal < amount {
+			amount = maxBal
+		}
+		remaining[debtor] += amount
+		remaining[creditor] -= amount
+		transfers = append(transfers, SettleTransfer{FromUserID: debtor, ToUserID: creditor, Amount: fromCents(amount)})
+	}
+}
+
+// ledgerBalances 汇总账本中每个人的已付、应付和结算，返回按分计的余额。
+// 账本内的支出只能使用账本币种，因此可以直接累加金额
+func ledgerBalances(ledger *models.Ledger) (map[uint]int64, map[uint]int64, map[uint]int64, error) {
+	db := database.GetDB()
+	paid := make(map[uint]int64)
+	owed := make(map[uint]int64)
+	balances := make(map[uint]int64)
+
+	var paidRows []struct {
+		UserID uint
+		Total  float64
+	}
+	if err := db.Model(&models.Expense{}).Select("user_id, COALESCE(SUM(amount), 0) AS total").
+		Where("ledger_id = ?", ledger.ID).Group("user_id").Scan(&paidRows).Error; err != nil {
+		return nil, nil, nil, err
+	}
+	for _, r := range paidRows {
+		paid[r.UserID] = toCents(r.Total)
+	}
+
+	var owedRows []struct {
+		UserID uint
+		Total  float64
+	}
+	if err := db.Model(&models.ExpenseSplit{}).Select("expense_splits.user_id, COALESCE(SUM(expense_splits.amount), 0) AS total").
+		Joins("JOIN expenses ON expenses.id = expense_splits.expense_id").
+		Where("expenses.ledger_id = ?", ledger.ID).Group("expense_splits.user_id").Scan(&owedRows).Error; err != nil {
+		return nil, nil, nil, err
+	}
+	for _, r := range owedRows {
+		owed[r.UserID] = toCents(r.Total)
+	}
+
+	var settlements []models.LedgerSettlement
+	if err := db.Where("ledger_id = ?", ledger.ID).Find(&settlements).Error; err != nil {
+		return nil, nil, nil, err
+	}
+
+	for id, v := range paid {
+		balances[id] += v
+	}
+	for id, v := range owed {
+		balances[id] -= v
+	}
+	for _, s := range settlements {
+		balances[s.FromUserID] += toCents(s.Amount)
+		balances[s.ToUserID] -= toCents(s.Amount)
+	}
+	return balances, paid, owed, nil
+}
+
+// GetLedgerExpenses 账本中的支出及分摊明细
+func GetLedgerExpenses(c *gin.Context) {
+	ledger, ok := loadLedgerForMember(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var expenses []models.Expense
+	if err := database.GetDB().Preload("Splits").Preload("Attachments").
+		Where("ledger_id = ?", ledger.ID).Order("spent_at DESC, id DESC").Find(&expenses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, expenses)
+}
+
+// GetLedgerSettleUp 各成员余额和结清所需的最少转账
+func GetLedgerSettleUp(c *gin.Context) {
+	ledger, ok := loadLedgerForMember(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	balances, paid, owed, err := ledgerBalances(ledger)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	members, err := ledgerMemberIDs(ledger)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	ids := append([]uint{}, members...)
+	listed := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		listed[id] = true
+	}
+	// 已退出但仍有余额的成员也要列出
+	for id := range balances {
+		if !listed[id] {
+			ids = append(ids, id)
+			listed[id] = true
+		}
+	}
+
+	var users []models.User
+	if err := database.GetDB().Select("id", "username").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	names := make(map[uint]string, len(users))
+	for _, u := range users {
+		names[u.ID] = u.Username
+	}
+
+	views := make([]MemberBalance, 0, len(ids))
+	for _, id := range ids {
+		views = append(views, MemberBalance{
+			UserID:   id,
+			Username: names[id],
+			Paid:     fromCents(paid[id]),
+			Owed:     fromCents(owed[id]),
+			Balance:  fromCents(balances[id]),
+		})
+	}
+
+	transfers := minimalTransfers(balances)
+	if transfers == nil {
+		transfers = []SettleTransfer{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"balances":  views,
+		"transfers": transfers,
+		"currency":  ledger.Currency,
+	})
+}
+
+// GetLedgerSettlements 账本的结算记录
+func GetLedgerSettlements(c *gin.Context) {
+	ledger, ok := loadLedgerForMember(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var settlements []models.LedgerSettlement
+	if err := database.GetDB().Where("ledger_id = ?", ledger.ID).Order("settled_at DESC, id DESC").Find(&settlements).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settlements)
+}
+
+// CreateLedgerSettlement 记录当前用户向另一成员的还款
+func CreateLedgerSettlement(c *gin.Context) {
+	userID := c.GetUint("userID")
+	ledger, ok := loadLedgerForMember(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req CreateSettlementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+	if req.ToUserID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot settle with yourself"})
+		return
+	}
+
+	members, err := ledgerMemberIDs(ledger)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	isMember := false
+	for _, id := range members {
+		if id == req.ToUserID {
+			isMember = true
+		}
+	}
+	if !isMember {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "recipient is not a ledger member"})
+		return
+	}
+
+	settledAt := time.Now()
+	if req.SettledAt != "" {
+		t, err := parseSpentAt(req.SettledAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid settled_at, use RFC3339 or YYYY-MM-DD"})
+			return
+		}
+		settledAt = t
+	}
+
+	settlement := models.LedgerSettlement{
+		LedgerID:   ledger.ID,
+		FromUserID: userID,
+		ToUserID:   req.ToUserID,
+		Amount:     req.Amount,
+		Note:       req.Note,
+		SettledAt:  settledAt,
+	}
+
+	if err := database.GetDB().Create(&settlement).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, settlement)
+}
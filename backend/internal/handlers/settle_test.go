@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"daily-planner-backend/internal/models"
+
+	"pgregory.net/rapid"
+)
+
+func TestComputeSplits(t *testing.T) {
+	parts := []SplitParticipant{{UserID: 1, Share: 2, Amount: 50}, {UserID: 2, Share: 1, Amount: 30}, {UserID: 3, Share: 1, Amount: 20}}
+
+	// 100.00 平均分给 3 人，多出的 1 分给第一个人
+	equal, err := computeSplits(10000, splitEqual, parts)
+	if err != nil || !reflect.DeepEqual(equal, []int64{3334, 3333, 3333}) {
+		t.Errorf("Unexpected equal split: %v, %v", equal, err)
+	}
+
+	share, err := computeSplits(10001, splitShare, parts)
+	if err != nil || !reflect.DeepEqual(share, []int64{5001, 2500, 2500}) {
+		t.Errorf("Unexpected share split: %v, %v", share, err)
+	}
+
+	exact, err := computeSplits(10000, splitExact, parts)
+	if err != nil || !reflect.DeepEqual(exact, []int64{5000, 3000, 2000}) {
+		t.Errorf("Unexpected exact split: %v, %v", exact, err)
+	}
+
+	if _, err := computeSplits(9999, splitExact, parts); err != errInvalidSplit {
+		t.Errorf("Expected exact split not adding up to be rejected, got %v", err)
+	}
+	if _, err := computeSplits(100, splitShare, []SplitParticipant{{UserID: 1}}); err != errInvalidSplit {
+		t.Errorf("Expected zero share to be rejected, got %v", err)
+	}
+}
+
+func TestResplitByShares(t *testing.T) {
+	splits := []models.ExpenseSplit{{ExpenseID: 9, UserID: 1, Share: 1, Amount: 30}, {ExpenseID: 9, UserID: 2, Share: 2, Amount: 60}}
+	updated, err := resplitByShares(splits, 120)
+	if err != nil || updated[0].Amount != 40 || updated[1].Amount != 80 || updated[1].ExpenseID != 9 {
+		t.Errorf("Unexpected resplit: %+v, %v", updated, err)
+	}
+
+	exact := []models.ExpenseSplit{{UserID: 1, Share: 0, Amount: 30}}
+	if _, err := resplitByShares(exact, 50); err == nil {
+		t.Error("Expected exact splits to require an explicit split")
+	}
+}
+
+func TestMinimalTransfersBeatsGreedy(t *testing.T) {
+	// {+3,-3} 和 {+4,-2,-2} 各自结清只需 3 笔，直接贪心需要 4 笔
+	balances := map[uint]int64{1: 400, 2: 300, 3: -300, 4: -200, 5: -200}
+
+	if greedy := greedyTransfers([]uint{1, 2, 3, 4, 5}, balances); len(greedy) != 4 {
+		t.Fatalf("Expected greedy matching to need 4 transfers, got %v", greedy)
+	}
+
+	transfers := minimalTransfers(balances)
+	if len(transfers) != 3 {
+		t.Errorf("Expected 3 transfers, got %v", transfers)
+	}
+	assertSettled(t, balances, transfers)
+}
+
+func assertSettled(t interface{ Fatalf(string, ...interface{}) }, balances map[uint]int64, transfers []SettleTransfer) {
+	remaining := make(map[uint]int64, len(balances))
+	for id, b := range balances {
+		remaining[id] = b
+	}
+	for _, tr := range transfers {
+		if tr.Amount <= 0 || tr.FromUserID == tr.ToUserID {
+			t.Fatalf("Invalid transfer %+v", tr)
+		}
+		remaining[tr.FromUserID] += toCents(tr.Amount)
+		remaining[tr.ToUserID] -= toCents(tr.Amount)
+	}
+	for id, b := range remaining {
+		if b != 0 {
+			t.Fatalf("User %d still has balance %d after transfers %v", id, b, transfers)
+		}
+	}
+}
+
+// **Feature: shared-ledgers, Property 1: Settle-up zeroes every balance with at most n-1 transfers, never more than greedy**
+func TestMinimalTransfersSettleEveryone(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		n := rapid.IntRange(2, 9).Draw(t, "members")
+		balances := make(map[uint]int64, n)
+		ids := make([]uint, 0, n)
+		var sum int64
+		for i := 1; i < n; i++ {
+			b := int64(rapid.IntRange(-5000, 5000).Draw(t, "balance"))
+			balances[uint(i)] = b
+			ids = append(ids, uint(i))
+			sum += b
+		}
+		balances[uint(n)] = -sum
+		ids = append(ids, uint(n))
+
+		transfers := minimalTransfers(balances)
+		assertSettled(t, balances, transfers)
+
+		nonZero := 0
+		for _, b := range balances {
+			if b != 0 {
+				nonZero++
+			}
+		}
+		if nonZero > 0 && len(transfers) > nonZero-1 {
+			t.Fatalf("Expected at most %d transfers, got %d", nonZero-1, len(transfers))
+		}
+		if greedy := greedyTransfers(ids, balances); len(transfers) > len(greedy) {
+			t.Fatalf("Minimal settle-up used %d transfers, greedy used %d", len(transfers), len(greedy))
+		}
+	})
+}
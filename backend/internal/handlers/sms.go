@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"daily-planner-backend/internal/database"
+	"daily-planner-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+var errSMSNotRecognized = errors.New("message not recognized")
+
+// 短信模板中常用的片段，模板通过命名分组 amount、merchant、card 和 month/day/hour/minute 取值
+const (
+	smsAmount = `(?:人民币|RMB|CNY)?\s*[-+]?(?P<amount>\d{1,3}(?:,\d{3})+(?:\.\d{1,2})?|\d+(?:\.\d{1,2})?)\s*元?`
+	smsTime   = `(?:(?P<year>\d{4})年)?(?P<month>\d{1,2})月(?P<day>\d{1,2})日\s*(?P<hour>\d{1,2})[:：时](?P<minute>\d{2})分?`
+)
+
+var (
+	smsCardTailRe = regexp.MustCompile(`尾号\*?(\d{4})`)
+	smsTimeRe     = regexp.MustCompile(smsTime)
+	smsBankRe     = regexp.MustCompile(`[【\[]([^】\]]*银行[^】\]]*)[】\]]`)
+)
+
+// smsMerchantPrefixes 商户名前的支付渠道，解析后去掉
+var smsMerchantPrefixes = []string{"财付通-", "支付宝-", "微信支付-", "美团支付-", "京东支付-", "银联-", "网联-"}
+
+// smsPattern 一种短信格式，typ 为该格式对应的收支类型
+type smsPattern struct {
+	re  *regexp.Regexp
+	typ string
+}
+
+// smsTemplate 某家银行的短信模板，短信包含任一关键字时依次尝试各格式
+type smsTemplate struct {
+	Bank     string
+	Keywords []string
+	Patterns []smsPattern
+}
+
+// ParsedSMS 从短信中识别出的交易
+type ParsedSMS struct {
+	Bank     string    `json:"bank"`
+	Type     string    `json:"type"`
+	Amount   float64   `json:"amount"`
+	Merchant string    `json:"merchant"`
+	CardTail string    `json:"card_tail"`
+	SpentAt  time.Time `json:"spent_at"`
+	HasTime  bool      `json:"has_time"` // 短信中没有时间时使用收到短信的时间
+}
+
+type ParseExpenseSMSRequest struct {
+	Text      string `json:"text" binding:"required,max=1000"`
+	Create    bool   `json:"create"`     // true 时直接记账，否则只返回草稿
+	AccountID uint   `json:"account_id"` // 缺省按卡号尾号匹配账户名称
+	Category  string `json:"category" binding:"max=50"`
+}
+
+func smsRe(parts ...string) *regexp.Regexp {
+	return regexp.MustCompile(strings.Join(parts, ""))
+}
+
+// smsTemplates 已支持的银行短信模板，新增银行时在此追加；
+// 所有模板都不匹配时使用 smsGenericTemplate
+var smsTemplates = []smsTemplate{
+	{
+		Bank:     "招商银行",
+		Keywords: []string{"招商银行", "招行"},
+		Patterns: []smsPattern{
+			{smsRe(`账户(?P<card>\d{4})于`, smsTime, `在【?(?P<merchant>[^】，,]+?)】?(?:快捷支付|消费|支付|扣款)`, smsAmount), transactionExpense},
+			{smsRe(`账户(?P<card>\d{4})于`, smsTime, `入账(?P<merchant>[^，,]*)[，,]`, smsAmount), transactionIncome},
+			{smsRe(`尾号(?P<card>\d{4})的\S*?信用卡`, smsTime, `(?:消费|支出)`, smsAmount, `[，,。]?(?:商户[:：](?P<merchant>[^，,。]+))?`), transactionExpense},
+		},
+	},
+	{
+		Bank:     "工商银行",
+		Keywords: []string{"工商银行", "工行"},
+		Patterns: []smsPattern{
+			{smsRe(`尾号(?P<card>\d{4})卡`, smsTime, `\S*?支出[(（](?P<merchant>[^)）]*)[)）]`, smsAmount), transactionExpense},
+			{smsRe(`尾号(?P<card>\d{4})卡`, smsTime, `\S*?收入[(（](?P<merchant>[^)）]*)[)）]`, smsAmount), transactionIncome},
+		},
+	},
+	{
+		Bank:     "建设银行",
+		Keywords: []string{"建设银行", "建行"},
+		Patterns: []smsPattern{
+			{smsRe(`尾号(?P<card>\d{4})的\S*?卡`, smsTime, `向(?P<merchant>[^，,]+?)支付`, smsAmount), transactionExpense},
+			{smsRe(`尾号(?P<card>\d{4})的\S*?卡`, smsTime, `消费支出`, smsAmount), transactionExpense},
+			{smsRe(`尾号(?P<card>\d{4})的\S*?卡`, smsTime, `(?:存入|收入)(?P<merchant>[^人]*?)`, smsAmount), transactionIncome},
+		},
+	},
+	{
+		Bank:     "农业银行",
+		Keywords: []string{"农业银行", "农行"},
+		Patterns: []smsPattern{
+			{smsRe(`尾号(?P<card>\d{4})账户`, smsTime, `完成(?P<merchant>\S*?)交易人民币-`, smsAmount), transactionExpense},
+			{smsRe(`尾号(?P<card>\d{4})账户`, smsTime, `完成(?P<merchant>\S*?)交易人民币\+?`, smsAmount), transactionIncome},
+		},
+	},
+	{
+		Bank:     "中国银行",
+		Keywords: []string{"中国银行", "中行"},
+		Patterns: []smsPattern{
+			{smsRe(`账户\D*(?P<card>\d{4})[，,]于(?P<month>\d{1,2})/(?P<day>\d{1,2})\s*(?P<hour>\d{1,2}):(?P<minute>\d{2})\S*?(?:支付|消费)交易`, smsAmount), transactionExpense},
+			{smsRe(`账户\D*(?P<card>\d{4})[，,]于(?P<month>\d{1,2})/(?P<day>\d{1,2})\s*(?P<hour>\d{1,2}):(?P<minute>\d{2})\S*?(?:收入|存入|入账)`, smsAmount), transactionIncome},
+		},
+	},
+	{
+		Bank:     "交通银行",
+		Keywords: []string{"交通银行", "交行"},
+		Patterns: []smsPattern{
+			{smsRe(`尾号\*?(?P<card>\d{4})的卡于`, smsTime, `在(?P<merchant>.+?)(?:网上支付|消费|支付)`, smsAmount), transactionExpense},
+		},
+	},
+	{
+		Bank:     "平安银行",
+		Keywords: []string{"平安银行"},
+		Patterns: []smsPattern{
+			{smsRe(`尾号(?P<card>\d{4})的\S*?卡`, smsTime, `(?:消费|支出)`, smsAmount, `[，,。]?(?:商户[:：](?P<merchant>[^，,。]+))?`), transactionExpense},
+		},
+	},
+}
+
+// smsGenericTemplate 未识别银行或格式时的兜底规则，只提取金额，卡号和时间从全文查找
+var smsGenericTemplate = smsTemplate{
+	Patterns: []smsPattern{
+		{smsRe(`(?:消费|支付|支出|扣款)`, smsAmount), transactionExpense},
+		{smsRe(`(?:入账|存入|收入)`, smsAmount), transactionIncome},
+	},
+}
+
+// matchSMSPattern 用模板的各格式依次匹配短信，返回命名分组的值
+func matchSMSPattern(tmpl smsTemplate, text string) (map[string]string, string, bool) {
+	for _, p := range tmpl.Patterns {
+		m := p.re.FindStringSubmatch(text)
+		if m == nil {
+			continue
+		}
+		groups := make(map[string]string)
+		for i, name := range p.re.SubexpNames() {
+			if name != "" && m[i] != "" {
+				groups[name] = strings.TrimSpace(m[i])
+			}
+		}
+		return groups, p.typ, true
+	}
+	return nil, "", false
+}
+
+// smsTimeFromGroups 根据短信中的月日时分确定交易时间；没有年份时取不晚于 now 的最近一年
+func smsTimeFromGroups(groups map[string]string, now time.Time) (time.Time, bool) {
+	month, err1 := strconv.Atoi(groups["month"])
+	day, err2 := strconv.Atoi(groups["day"])
+	hour, err3 := strconv.Atoi(groups["hour"])
+	minute, err4 := strconv.Atoi(groups["minute"])
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil || month < 1 || month > 12 || day < 1 || day > 31 || hour > 23 || minute > 59 {
+		return time.Time{}, false
+	}
+
+	year := now.Year()
+	if y, err := strconv.Atoi(groups["year"]); err == nil {
+		return time.Date(y, time.Month(month), day, hour, minute, 0, 0, now.Location()), true
+	}
+	t := time.Date(year, time.Month(month), day, hour, minute, 0, 0, now.Location())
+	// 跨年时收到的去年 12 月的短信
+	if t.After(now.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t, true
+}
+
+// cleanSMSMerchant 去掉商户名中的支付渠道前缀和括号
+func cleanSMSMerchant(s string) string {
+	s = strings.Trim(s, "【】[]（）() ")
+	for _, prefix := range smsMerchantPrefixes {
+		s = strings.TrimPrefix(s, prefix)
+	}
+	return truncateRunes(s, 100)
+}
+
+// parseSMS 识别银行短信中的金额、商户、卡号尾号和交易时间，now 为收到短信的时间
+func parseSMS(text string, now time.Time) (*ParsedSMS, error) {
+	text = strings.TrimSpace(text)
+
+	var groups map[string]string
+	var typ, bank string
+	matched := false
+	for _, tmpl := range smsTemplates {
+		if !containsAny(text, tmpl.Keywords) {
+			continue
+		}
+		if groups, typ, matched = matchSMSPattern(tmpl, text); matched {
+			bank = tmpl.Bank
+			break
+		}
+	}
+	if !matched {
+		if groups, typ, matched = matchSMSPattern(smsGenericTemplate, text); !matched {
+			return nil, errSMSNotRecognized
+		}
+		if m := smsBankRe.FindStringSubmatch(text); m != nil {
+			bank = m[1]
+		}
+	}
+
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(groups["amount"], ",", ""), 64)
+	if err != nil || amount <= 0 {
+		return nil, errSMSNotRecognized
+	}
+
+	parsed := &ParsedSMS{
+		Bank:     bank,
+		Type:     typ,
+		Amount:   round2(amount),
+		Merchant: cleanSMSMerchant(groups["merchant"]),
+		CardTail: groups["card"],
+		SpentAt:  now,
+	}
+
+	// 模板没有覆盖的字段从全文查找
+	if parsed.CardTail == "" {
+		if m := smsCardTailRe.FindStringSubmatch(text); m != nil {
+			parsed.CardTail = m[1]
+		}
+	}
+	if _, ok := groups["month"]; !ok {
+		if m := smsTimeRe.FindStringSubmatch(text); m != nil {
+			groups = make(map[string]string)
+			for i, name := range smsTimeRe.SubexpNames() {
+				if name != "" {
+					groups[name] = m[i]
+				}
+			}
+		}
+	}
+	if t, ok := smsTimeFromGroups(groups, now); ok {
+		parsed.SpentAt, parsed.HasTime = t, true
+	}
+
+	return parsed, nil
+}
+
+// matchAccountByCardTail 账户名称中包含卡号尾号且只有一个时返回该账户
+func matchAccountByCardTail(accounts []models.Account, tail string) *uint {
+	if tail == "" {
+		return nil
+	}
+	var found *uint
+	for i := range accounts {
+		if strings.Contains(accounts[i].Name, tail) {
+			if found != nil {
+				return nil
+			}
+			found = &accounts[i].ID
+		}
+	}
+	return found
+}
+
+// ParseExpenseSMS 解析银行或支付短信：create=false 时返回草稿，create=true 时直接记账。
+// 同一条短信只会记账一次
+func ParseExpenseSMS(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req ParseExpenseSMSRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation error", "details": err.Error()})
+		return
+	}
+
+	parsed, err := parseSMS(req.Text, time.Now())
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	expense := models.Expense{
+		UserID:   userID,
+		Type:     parsed.Type,
+		Amount:   parsed.Amount,
+		Currency: domesticCurrency,
+		Category: req.Category,
+		Merchant: parsed.Merchant,
+		Note:     strings.TrimSpace(parsed.Bank + " " + parsed.Merchant),
+		SpentAt:  parsed.SpentAt,
+		Source:   "sms",
+	}
+
+	if req.AccountID != 0 {
+		if !validateAccountLink(c, userID, req.AccountID) {
+			return
+		}
+		expense.AccountID = &req.AccountID
+	} else if parsed.CardTail != "" {
+		var accounts []models.Account
+		if err := database.GetDB().Where("user_id = ? AND archived = ?", userID, false).Find(&accounts).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		expense.AccountID = matchAccountByCardTail(accounts, parsed.CardTail)
+	}
+
+	if expense.Category == "" {
+		rules, err := loadCategoryRules(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		if !applyCategoryRule(rules, &expense) {
+			if expense.Type == transactionIncome {
+				expense.Category = fallbackIncomeCategory
+			} else {
+				expense.Category = guessCategory("", expense.Merchant, expense.Note)
+			}
+		}
+	}
+
+	if !req.Create {
+		c.JSON(http.StatusOK, gin.H{"parsed": parsed, "expense": expense, "created": false})
+		return
+	}
+
+	sum := sha1.Sum([]byte(strings.TrimSpace(req.Text)))
+	externalID := "sms:" + hex.EncodeToString(sum[:])
+	expense.ExternalID = &externalID
+
+	result := database.GetDB().Clauses(clause.OnConflict{DoNothing: true}).Create(&expense)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "message already recorded"})
+		return
+	}
+
+	if expense.Type == transactionExpense {
+		checkBudgetThresholds(userID, expense.Category, expense.SpentAt)
+	} else {
+		autoSetAside(&expense)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"parsed": parsed, "expense": expense, "created": true})
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"daily-planner-backend/internal/models"
+)
+
+func TestParseSMS(t *testing.T) {
+	now := time.Date(2024, 10, 20, 9, 0, 0, 0, time.Local)
+
+	cases := []struct {
+		name, text                    string
+		bank, typ, merchant, card, at string
+		amount                        float64
+	}{
+		{
+			name: "cmb debit",
+			text: "【招商银行】您账户1234于10月18日12:30在【财付通-美团外卖】快捷支付35.50元，余额1,234.56",
+			bank: "招商银行", typ: transactionExpense, merchant: "美团外卖", card: "1234", at: "2024-10-18 12:30", amount: 35.5,
+		},
+		{
+			name: "cmb salary",
+			text: "【招商银行】您账户1234于10月15日09:00入账工资，人民币12,800.00元，余额20,034.56",
+			bank: "招商银行", typ: transactionIncome, merchant: "工资", card: "1234", at: "2024-10-15 09:00", amount: 12800,
+		},
+		{
+			name: "cmb credit",
+			text: "【招商银行】您尾号5678的招行信用卡10月19日08:05消费人民币32.00元，商户：星巴克。",
+			bank: "招商银行", typ: transactionExpense, merchant: "星巴克", card: "5678", at: "2024-10-19 08:05", amount: 32,
+		},
+		{
+			name: "icbc",
+			text: "您尾号2345卡10月18日19:42快捷支付支出(支付宝-饿了么)46.80元，余额2,345.67元。【工商银行】",
+			bank: "工商银行", typ: transactionExpense, merchant: "饿了么", card: "2345", at: "2024-10-18 19:42", amount: 46.8,
+		},
+		{
+			name: "icbc income",
+			text: "您尾号2345卡10月10日10:00工资收入(代发工资)8,000.00元，余额10,345.67元。【工商银行】",
+			bank: "工商银行", typ: transactionIncome, merchant: "代发工资", card: "2345", at: "2024-10-10 10:00", amount: 8000,
+		},
+		{
+			name: "ccb",
+			text: "您尾号3456的储蓄卡10月17日12时30分向滴滴出行支付人民币23.60元,活期余额1234.56元。[建设银行]",
+			bank: "建设银行", typ: transactionExpense, merchant: "滴滴出行", card: "3456", at: "2024-10-17 12:30", amount: 23.6,
+		},
+		{
+			name: "ccb no merchant",
+			text: "您尾号3456的储蓄卡10月17日18时05分消费支出人民币128.00元,活期余额1106.56元。[建设银行]",
+			bank: "建设银行", typ: transactionExpense, card: "3456", at: "2024-10-17 18:05", amount: 128,
+		},
+		{
+			name: "abc",
+			text: "【中国农业银行】您尾号4567账户10月16日21:11完成支付宝交易人民币-59.90，余额1,940.10。",
+			bank: "农业银行", typ: transactionExpense, merchant: "支付宝", card: "4567", at: "2024-10-16 21:11", amount: 59.9,
+		},
+		{
+			name: "boc",
+			text: "【中国银行】您的借记卡账户长城电子借记卡6789，于10/18 12:30网上快捷支付交易人民币35.50元，交易后余额1234.56",
+			bank: "中国银行", typ: transactionExpense, card: "6789", at: "2024-10-18 12:30", amount: 35.5,
+		},
+		{
+			name: "bocom",
+			text: "您尾号*7890的卡于10月18日12:30在美团外卖网上支付35.50元，交易后余额为1234.56元。【交通银行】",
+			bank: "交通银行", typ: transactionExpense, merchant: "美团外卖", card: "7890", at: "2024-10-18 12:30", amount: 35.5,
+		},
+		{
+			name: "pingan credit",
+			text: "【平安银行】您尾号8901的信用卡10月19日20:15消费人民币299.00元，商户：京东商城。",
+			bank: "平安银行", typ: transactionExpense, merchant: "京东商城", card: "8901", at: "2024-10-19 20:15", amount: 299,
+		},
+		{
+			name: "unknown bank falls back to generic",
+			text: "【某某农商银行】您尾号9012的卡于10月18日13:00消费人民币18.00元。",
+			bank: "某某农商银行", typ: transactionExpense, card: "9012", at: "2024-10-18 13:00", amount: 18,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseSMS(tc.text, now)
+			if err != nil {
+				t.Fatalf("parseSMS failed: %v", err)
+			}
+			if got.Bank != tc.bank || got.Type != tc.typ || got.Merchant != tc.merchant || got.CardTail != tc.card || got.Amount != tc.amount {
+				t.Errorf("Unexpected result: %+v", got)
+			}
+			if at := got.SpentAt.Format("2006-01-02 15:04"); !got.HasTime || at != tc.at {
+				t.Errorf("Expected time %s, got %s (has_time=%v)", tc.at, at, got.HasTime)
+			}
+		})
+	}
+}
+
+func TestParseSMSYearRollover(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 10, 0, 0, time.Local)
+	got, err := parseSMS("【招商银行】您账户1234于12月31日23:58在【美团外卖】快捷支付35.50元，余额1,234.56", now)
+	if err != nil {
+		t.Fatalf("parseSMS failed: %v", err)
+	}
+	if got.SpentAt.Year() != 2024 {
+		t.Errorf("Expected December message to belong to 2024, got %v", got.SpentAt)
+	}
+}
+
+func TestParseSMSWithoutTime(t *testing.T) {
+	now := time.Date(2024, 10, 20, 9, 0, 0, 0, time.Local)
+	got, err := parseSMS("【微众银行】您尾号1111的卡消费人民币9.90元", now)
+	if err != nil {
+		t.Fatalf("parseSMS failed: %v", err)
+	}
+	if got.HasTime || !got.SpentAt.Equal(now) || got.Amount != 9.9 || got.CardTail != "1111" {
+		t.Errorf("Unexpected result: %+v", got)
+	}
+}
+
+func TestParseSMSNotRecognized(t *testing.T) {
+	for _, text := range []string{
+		"【招商银行】您的验证码为123456，请勿泄露。",
+		"明天下午三点开会",
+	} {
+		if _, err := parseSMS(text, time.Now()); err != errSMSNotRecognized {
+			t.Errorf("Expected %q not to be recognized, got %v", text, err)
+		}
+	}
+}
+
+func TestMatchAccountByCardTail(t *testing.T) {
+	accounts := []models.Account{{ID: 1, Name: "招行储蓄卡 1234"}, {ID: 2, Name: "工行 5678"}, {ID: 3, Name: "信用卡5678"}}
+	if id := matchAccountByCardTail(accounts, "1234"); id == nil || *id != 1 {
+		t.Errorf("Expected account 1, got %v", id)
+	}
+	if id := matchAccountByCardTail(accounts, "5678"); id != nil {
+		t.Errorf("Expected ambiguous tail to match nothing, got %v", *id)
+	}
+	if id := matchAccountByCardTail(accounts, ""); id != nil {
+		t.Errorf("Expected empty tail to match nothing, got %v", *id)
+	}
+}
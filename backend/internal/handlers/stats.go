@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"daily-planner-backend/internal/database"
+	"daily-planner-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// statsTopLimit 常用备注和商户的返回数量
+const statsTopLimit = 5
+
+// statsGroupExprs 各分组方式对应的 SQL 分组键，周以周一为起点
+var statsGroupExprs = map[string]string{
+	"category": "category",
+	"day":      "DATE_FORMAT(spent_at, '%Y-%m-%d')",
+	"week":     "DATE_FORMAT(DATE_SUB(DATE(spent_at), INTERVAL WEEKDAY(spent_at) DAY), '%Y-%m-%d')",
+	"month":    "DATE_FORMAT(spent_at, '%Y-%m')",
+}
+
+// StatsGroup 分组统计结果
+type StatsGroup struct {
+	Key      string   `json:"key"`
+	Total    float64  `json:"total"`
+	Count    int64    `json:"count"`
+	Percent  float64  `json:"percent"`
+	Previous *float64 `json:"previous,omitempty"` // 按分类分组时上一周期的金额
+}
+
+// StatsTopItem 常用备注或商户
+type StatsTopItem struct {
+	Name  string  `json:"name"`
+	Total float64 `json:"total"`
+	Count int64   `json:"count"`
+}
+
+// StatsPeriod 一个统计周期的汇总
+type StatsPeriod struct {
+	From         string  `json:"from"`
+	To           string  `json:"to"`
+	Total        float64 `json:"total"`
+	Count        int64   `json:"count"`
+	AverageDaily float64 `json:"average_daily"`
+}
+
+// previousPeriod 返回紧挨在 [from, to] 之前、天数相同的周期
+func previousPeriod(from, to time.Time) (time.Time, time.Time) {
+	days := daysBetween(from, to) + 1
+	return from.AddDate(0, 0, -days), from.AddDate(0, 0, -1)
+}
+
+// periodKeys 按分组方式列出 [from, to] 内的全部时间键，用于补齐没有支出的时间段
+func periodKeys(from, to time.Time, groupBy string) []string {
+	var keys []string
+	switch groupBy {
+	case "day":
+		for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+			keys = append(keys, d.Format(dayLayout))
+		}
+	case "week":
+		for d := weekStart(from); !d.After(to); d = d.AddDate(0, 0, 7) {
+			keys = append(keys, d.Format(dayLayout))
+		}
+	case "month":
+		for d, _ := monthRange(from); !d.After(to); d = d.AddDate(0, 1, 0) {
+			keys = append(keys, d.Format(monthLayout))
+		}
+	}
+	return keys
+}
+
+// changePercent 环比变化百分比，上一周期为 0 时无意义
+func changePercent(current, previous float64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	v := round2((current - previous) / previous * 100)
+	return &v
+}
+
+// 以下汇总均按消费日期的汇率换算为本位币
+
+func summarizePeriod(query *gorm.DB, from, to time.Time, conv *currencyConverter) (StatsPeriod, error) {
+	total, count, err := convertedSum(query.Session(&gorm.Session{}).
+		Where("spent_at >= ? AND spent_at < ?", from, to.AddDate(0, 0, 1)), conv)
+
+	return StatsPeriod{
+		From:         from.Format(dayLayout),
+		To:           to.Format(dayLayout),
+		Total:        total,
+		Count:        count,
+		AverageDaily: round2(total / float64(daysBetween(from, to)+1)),
+	}, err
+}
+
+func groupTotals(query *gorm.DB, expr string, from, to time.Time, conv *currencyConverter) ([]StatsGroup, error) {
+	rows, err := convertedTotals(query.Session(&gorm.Session{}).
+		Where("spent_at >= ? AND spent_at < ?", from, to.AddDate(0, 0, 1)), expr, conv)
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]StatsGroup, 0, len(rows))
+	for _, r := range rows {
+		groups = append(groups, StatsGroup{Key: r.Key, Total: r.Total, Count: r.Count})
+	}
+	return groups, nil
+}
+
+func topItems(query *gorm.DB, column string, from, to time.Time, conv *currencyConverter) ([]StatsTopItem, error) {
+	rows, err := convertedTotals(query.Session(&gorm.Session{}).
+		Where("spent_at >= ? AND spent_at < ?", from, to.AddDate(0, 0, 1)).
+		Where(column+" <> ''"), column, conv)
+	if err != nil {
+		return nil, err
+	}
+	items := []StatsTopItem{}
+	for _, r := range rows {
+		if len(items) == statsTopLimit {
+			break
+		}
+		items = append(items, StatsTopItem{Name: r.Key, Total: r.Total, Count: r.Count})
+	}
+	return items, nil
+}
+
+// parseStatsRange 解析 from/to 查询参数（均包含），缺省使用给定的范围；失败时已写入响应
+func parseStatsRange(c *gin.Context, from, to time.Time) (time.Time, time.Time, bool) {
+	if s := c.Query("from"); s != "" {
+		t, err := time.ParseInLocation(dayLayout, s, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+			return from, to, false
+		}
+		from = t
+	}
+	if s := c.Query("to"); s != "" {
+		t, err := time.ParseInLocation(dayLayout, s, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+			return from, to, false
+		}
+		to = t
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must not be before from"})
+		return from, to, false
+	}
+	if daysBetween(from, to) > 3660 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date range must not exceed 10 years"})
+		return from, to, false
+	}
+	return from, to, true
+}
+
+// GetExpenseStats 支出统计：分组汇总、环比、常用备注和商户、日均支出。
+// from/to 缺省为本月 1 日至今天，group_by 缺省按分类；exclude_reimbursed=true 时排除已报销的支出
+func GetExpenseStats(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	to := today()
+	from, _ := monthRange(to)
+	from, to, ok := parseStatsRange(c, from, to)
+	if !ok {
+		return
+	}
+
+	groupBy := c.DefaultQuery("group_by", "category")
+	expr, ok := statsGroupExprs[groupBy]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be one of category, day, week, month"})
+		return
+	}
+
+	query := database.GetDB().Model(&models.Expense{}).Where("user_id = ? AND type = ?", userID, transactionExpense)
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+	// 只统计个人实际承担的支出
+	if c.Query("exclude_reimbursed") == "true" {
+		query = query.Where("reimburse_status <> ?", reimburseReimbursed)
+	}
+
+	conv, err := loadCurrencyConverter(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	current, err := summarizePeriod(query, from, to, conv)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	prevFrom, prevTo := previousPeriod(from, to)
+	previous, err := summarizePeriod(query, prevFrom, prevTo, conv)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	groups, err := groupTotals(query, expr, from, to, conv)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	if groupBy == "category" {
+		prevGroups, err := groupTotals(query, expr, prevFrom, prevTo, conv)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		prevTotals := make(map[string]float64, len(prevGroups))
+		for _, g := range prevGroups {
+			prevTotals[g.Key] = g.Total
+		}
+		for i := range groups {
+			v := round2(prevTotals[groups[i].Key])
+			groups[i].Previous = &v
+		}
+	} else {
+		// 时间分组按时间顺序返回，并补齐没有支出的时间段
+		byKey := make(map[string]StatsGroup, len(groups))
+		for _, g := range groups {
+			byKey[g.Key] = g
+		}
+		keys := periodKeys(from, to, groupBy)
+		groups = make([]StatsGroup, 0, len(keys))
+		for _, k := range keys {
+			g := byKey[k]
+			g.Key = k
+			groups = append(groups, g)
+		}
+	}
+
+	for i := range groups {
+		groups[i].Total = round2(groups[i].Total)
+		if current.Total > 0 {
+			groups[i].Percent = round2(groups[i].Total / current.Total * 100)
+		}
+	}
+
+	topNotes, err := topItems(query, "note", from, to, conv)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	topMerchants, err := topItems(query, "merchant", from, to, conv)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"currency":       conv.base,
+		"missing_rates":  conv.missingCurrencies(),
+		"group_by":       groupBy,
+		"current":        current,
+		"previous":       previous,
+		"change":         round2(current.Total - previous.Total),
+		"change_percent": changePercent(current.Total, previous.Total),
+		"groups":         groups,
+		"top_notes":      topNotes,
+		"top_merchants":  topMerchants,
+	})
+}
+
+// CashFlowPeriod 一个时间段的收支和结余
+type CashFlowPeriod struct {
+	Key     string  `json:"key"`
+	Income  float64 `json:"income"`
+	Expense float64 `json:"expense"`
+	Net     float64 `json:"net"`
+}
+
+// buildCashFlow 按时间键补齐没有收支的时间段并计算结余和合计
+func buildCashFlow(keys []string, rows []CashFlowPeriod) ([]CashFlowPeriod, CashFlowPeriod) {
+	byKey := make(map[string]CashFlowPeriod, len(rows))
+	for _, r := range rows {
+		byKey[r.Key] = r
+	}
+
+	periods := make([]CashFlowPeriod, 0, len(keys))
+	var total CashFlowPeriod
+	for _, k := range keys {
+		p := byKey[k]
+		p.Key = k
+		p.Income = round2(p.Income)
+		p.Expense = round2(p.Expense)
+		p.Net = round2(p.Income - p.Expense)
+		total.Income += p.Income
+		total.Expense += p.Expense
+		periods = append(periods, p)
+	}
+	total.Key = "total"
+	total.Income = round2(total.Income)
+	total.Expense = round2(total.Expense)
+	total.Net = round2(total.Income - total.Expense)
+	return periods, total
+}
+
+// GetCashFlow 按 group_by=day|week|month（缺省按月）统计收入、支出和结余，
+// from/to 缺省为最近 6 个月；exclude_reimbursed=true 时排除已报销的支出及报销到账收入
+func GetCashFlow(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	to := today()
+	from, _ := monthRange(to)
+	from, to, ok := parseStatsRange(c, from.AddDate(0, -5, 0), to)
+	if !ok {
+		return
+	}
+
+	groupBy := c.DefaultQuery("group_by", "month")
+	expr, ok := statsGroupExprs[groupBy]
+	if !ok || groupBy == "category" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be one of day, week, month"})
+		return
+	}
+
+	query := database.GetDB().Model(&models.Expense{}).
+		Where("user_id = ? AND spent_at >= ? AND spent_at < ?", userID, from, to.AddDate(0, 0, 1))
+	// 已报销的支出和对应的报销到账收入相互抵消，排除后只反映个人收支
+	if c.Query("exclude_reimbursed") == "true" {
+		query = query.Where("reimburse_status <> ? AND source <> ?", reimburseReimbursed, reimbursementSource)
+	}
+
+	conv, err := loadCurrencyConverter(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	byKey := make(map[string]CashFlowPeriod)
+	for _, typ := range []string{transactionIncome, transactionExpense} {
+		totals, err := convertedTotals(query.Session(&gorm.Session{}).Where("type = ?", typ), expr, conv)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		for _, t := range totals {
+			p := byKey[t.Key]
+			p.Key = t.Key
+			if typ == transactionIncome {
+				p.Income = t.Total
+			} else {
+				p.Expense = t.Total
+			}
+			byKey[t.Key] = p
+		}
+	}
+	rows := make([]CashFlowPeriod, 0, len(byKey))
+	for _, p := range byKey {
+		rows = append(rows, p)
+	}
+
+	periods, total := buildCashFlow(periodKeys(from, to, groupBy), rows)
+
+	c.JSON(http.StatusOK, gin.H{
+		"currency":      conv.base,
+		"missing_rates": conv.missingCurrencies(),
+		"from":          from.Format(dayLayout),
+		"to":            to.Format(dayLayout),
+		"group_by":      groupBy,
+		"periods":       periods,
+		"income":        total.Income,
+		"expense":       total.Expense,
+		"net":           total.Net,
+	})
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPreviousPeriod(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+	to := time.Date(2024, 3, 31, 0, 0, 0, 0, time.Local)
+
+	prevFrom, prevTo := previousPeriod(from, to)
+	if got := prevFrom.Format(dayLayout); got != "2024-01-30" {
+		t.Errorf("Expected previous period to start on 2024-01-30, got %s", got)
+	}
+	if got := prevTo.Format(dayLayout); got != "2024-02-29" {
+		t.Errorf("Expected previous period to end on 2024-02-29, got %s", got)
+	}
+}
+
+func TestPeriodKeys(t *testing.T) {
+	// 2024-02-28 是周三
+	from := time.Date(2024, 2, 28, 0, 0, 0, 0, time.Local)
+	to := time.Date(2024, 3, 4, 0, 0, 0, 0, time.Local)
+
+	days := periodKeys(from, to, "day")
+	if len(days) != 6 || days[0] != "2024-02-28" || days[2] != "2024-03-01" || days[5] != "2024-03-04" {
+		t.Errorf("Unexpected day keys: %v", days)
+	}
+
+	weeks := periodKeys(from, to, "week")
+	if want := []string{"2024-02-26", "2024-03-04"}; !reflect.DeepEqual(weeks, want) {
+		t.Errorf("Expected week keys %v, got %v", want, weeks)
+	}
+
+	months := periodKeys(from, to, "month")
+	if want := []string{"2024-02", "2024-03"}; !reflect.DeepEqual(months, want) {
+		t.Errorf("Expected month keys %v, got %v", want, months)
+	}
+
+	if keys := periodKeys(from, to, "category"); keys != nil {
+		t.Errorf("Expected no keys for category grouping, got %v", keys)
+	}
+}
+
+func TestChangePercent(t *testing.T) {
+	if p := changePercent(150, 100); p == nil || *p != 50 {
+		t.Errorf("Expected 50%% increase, got %v", p)
+	}
+	if p := changePercent(100, 0); p != nil {
+		t.Errorf("Expected nil change for empty previous period, got %v", *p)
+	}
+}
+
+func TestBuildCashFlow(t *testing.T) {
+	keys := []string{"2024-01", "2024-02", "2024-03"}
+	rows := []CashFlowPeriod{
+		{Key: "2024-01", Income: 10000, Expense: 6543.21},
+		{Key: "2024-03", Income: 0, Expense: 120.5},
+	}
+
+	periods, total := buildCashFlow(keys, rows)
+	if len(periods) != 3 {
+		t.Fatalf("Expected 3 periods, got %d", len(periods))
+	}
+	if periods[0].Net != 3456.79 {
+		t.Errorf("Expected January net 3456.79, got %v", periods[0].Net)
+	}
+	if periods[1].Key != "2024-02" || periods[1].Income != 0 || periods[1].Net != 0 {
+		t.Errorf("Expected empty February, got %+v", periods[1])
+	}
+	if periods[2].Net != -120.5 {
+		t.Errorf("Expected March net -120.5, got %v", periods[2].Net)
+	}
+	if total.Income != 10000 || total.Expense != 6663.71 || total.Net != 3336.29 {
+		t.Errorf("Unexpected total: %+v", total)
+	}
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BasicAuthVerifier 校验 HTTP Basic 凭据，成功时返回用户 ID
+type BasicAuthVerifier func(username, password string) (uint, bool)
+
+// BasicAuthMiddleware HTTP Basic 认证中间件，供 CalDAV 等不支持 Bearer token 的客户端使用
+func BasicAuthMiddleware(realm string, verify BasicAuthVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username, password, ok := c.Request.BasicAuth()
+		if !ok {
+			c.Header("WWW-Authenticate", `Basic realm="`+realm+`"`)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		userID, ok := verify(username, password)
+		if !ok {
+			c.Header("WWW-Authenticate", `Basic realm="`+realm+`"`)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		c.Set("userID", userID)
+		c.Next()
+	}
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// Account 资金账户（现金、银行卡、支付宝余额、信用卡等），余额由期初余额和收支、转账记录推算
+type Account struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	UserID         uint      `gorm:"uniqueIndex:idx_user_account;not null" json:"user_id"`
+	Name           string    `gorm:"type:varchar(50);uniqueIndex:idx_user_account;not null" json:"name"`
+	Type           string    `gorm:"type:varchar(20);not null" json:"type"` // cash, debit, credit, alipay, wechat, other
+	OpeningBalance float64   `gorm:"not null;default:0" json:"opening_balance"`
+	SortOrder      int       `gorm:"not null;default:0" json:"sort_order"`
+	Archived       bool      `gorm:"default:false" json:"archived"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Transfer 账户间转账（含信用卡还款），不计入收支
+type Transfer struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	UserID        uint      `gorm:"index;not null" json:"user_id"`
+	FromAccountID uint      `gorm:"index;not null" json:"from_account_id"`
+	ToAccountID   uint      `gorm:"index;not null" json:"to_account_id"`
+	Amount        float64   `gorm:"not null" json:"amount"`
+	Note          string    `gorm:"type:varchar(255);default:''" json:"note"`
+	TransferredAt time.Time `gorm:"index" json:"transferred_at"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
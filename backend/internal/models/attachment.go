@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// Attachment 计划或支出的附件，OwnerType 为 plans 或 expenses
+type Attachment struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"index;not null" json:"user_id"`
+	OwnerType   string    `gorm:"type:varchar(20);index:idx_attachment_owner;not null" json:"owner_type"`
+	OwnerID     uint      `gorm:"index:idx_attachment_owner;not null" json:"owner_id"`
+	FileName    string    `gorm:"type:varchar(255);not null" json:"file_name"`
+	ContentType string    `gorm:"type:varchar(100);not null" json:"content_type"`
+	Size        int64     `gorm:"not null" json:"size"`
+	StorageKey  string    `gorm:"type:varchar(255);not null" json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+}
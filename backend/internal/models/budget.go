@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+// Budget 每月预算，Category 为空表示总预算
+type Budget struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_user_budget;not null" json:"user_id"`
+	Category  string    `gorm:"type:varchar(50);uniqueIndex:idx_user_budget;default:''" json:"category"`
+	Amount    float64   `gorm:"not null" json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// BudgetAlert 记录某月某预算已触发的阈值，保证同一阈值每月只提醒一次
+type BudgetAlert struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	BudgetID  uint      `gorm:"uniqueIndex:idx_budget_alert;not null" json:"budget_id"`
+	Month     string    `gorm:"type:varchar(7);uniqueIndex:idx_budget_alert;not null" json:"month"` // YYYY-MM
+	Threshold int       `gorm:"uniqueIndex:idx_budget_alert;not null" json:"threshold"`             // 百分比
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// ExpenseCategory 用户自定义的收支分类，收支记录通过名称引用
+type ExpenseCategory struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_user_category;not null" json:"user_id"`
+	Name      string    `gorm:"type:varchar(50);uniqueIndex:idx_user_category;not null" json:"name"`
+	Type      string    `gorm:"type:varchar(10);not null;default:'expense'" json:"type"` // expense 或 income
+	Icon      string    `gorm:"type:varchar(50);default:''" json:"icon"`                 // 客户端图标键名
+	Color     string    `gorm:"type:varchar(7);default:''" json:"color"`                 // #RRGGBB
+	SortOrder int       `gorm:"not null;default:0" json:"sort_order"`
+	ParentID  *uint     `gorm:"index" json:"parent_id"`
+	Archived  bool      `gorm:"default:false" json:"archived"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// CategoryRule 自动分类规则：备注/商户命中关键字或正则、金额落在区间内时使用规则的分类和标签。
+// 各条件之间为“且”关系，未设置的条件不参与判断
+type CategoryRule struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	Type      string    `gorm:"type:varchar(10);not null;default:'expense'" json:"type"` // 规则适用的收支类型
+	Keyword   string    `gorm:"type:varchar(100);default:''" json:"keyword"`             // 多个关键字用逗号分隔，命中任一即可
+	Pattern   string    `gorm:"type:varchar(255);default:''" json:"pattern"`             // 正则表达式，不区分大小写
+	MinAmount *float64  `json:"min_amount"`
+	MaxAmount *float64  `json:"max_amount"`
+	Category  string    `gorm:"type:varchar(50);not null" json:"category"`
+	Tags      string    `gorm:"type:varchar(255);default:''" json:"tags"` // 逗号分隔
+	Priority  int       `gorm:"not null;default:0" json:"priority"`       // 数值大的先匹配
+	Enabled   bool      `gorm:"not null;default:true" json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
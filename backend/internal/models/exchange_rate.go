@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// ExchangeRate 某日的汇率：1 单位 Currency 折合多少人民币（基准币种），
+// 某天没有汇率时使用此前最近一天的汇率
+type ExchangeRate struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Currency  string    `gorm:"type:varchar(3);uniqueIndex:idx_currency_date;not null" json:"currency"`
+	Date      time.Time `gorm:"type:date;uniqueIndex:idx_currency_date;not null" json:"date"`
+	Rate      float64   `gorm:"not null" json:"rate"`
+	Source    string    `gorm:"type:varchar(20);default:''" json:"source"` // manual 或 csv
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
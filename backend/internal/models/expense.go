@@ -5,11 +5,29 @@ import (
 )
 
 type Expense struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `gorm:"index;not null" json:"user_id"`
-	Amount    float64   `gorm:"not null" json:"amount"`
-	Category  string    `gorm:"not null" json:"category"`
-	Note      string    `json:"note"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	UserID          uint      `gorm:"index;uniqueIndex:idx_user_external;not null" json:"user_id"`
+	Type            string    `gorm:"type:varchar(10);index;not null;default:'expense'" json:"type"` // expense 或 income
+	Amount          float64   `gorm:"not null" json:"amount"`
+	Currency        string    `gorm:"type:varchar(3);not null;default:'CNY'" json:"currency"`
+	Category        string    `gorm:"not null" json:"category"`
+	Note            string    `json:"note"`
+	Merchant        string    `gorm:"type:varchar(100);default:''" json:"merchant"`
+	Tags            string    `gorm:"type:varchar(255);default:''" json:"tags"` // 逗号分隔
+	LedgerID        *uint     `gorm:"index" json:"ledger_id"`                   // 共享账本中的支出
+	AccountID       *uint     `gorm:"index" json:"account_id"`
+	GoalID          *uint     `gorm:"index" json:"goal_id"`
+	Reimbursable    bool      `gorm:"not null;default:false" json:"reimbursable"`                                   // 需要公司报销的垫付支出
+	ReimburseStatus string    `gorm:"type:varchar(20);index;default:''" json:"reimburse_status"`                    // pending, submitted, reimbursed, rejected；非报销支出为空
+	ClaimID         *uint     `gorm:"index" json:"claim_id"`                                                        // 所属报销单
+	RecurringID     *uint     `gorm:"index" json:"recurring_id"`                                                    // 由周期支出生成时关联的定义
+	InstallmentID   *uint     `gorm:"index" json:"installment_id"`                                                  // 由分期计划生成时关联的计划
+	SpentAt         time.Time `gorm:"index" json:"spent_at"`                                                        // 实际消费时间，由客户端设置
+	Source          string    `gorm:"type:varchar(20);default:''" json:"source"`                                    // 导入来源，手动记账为空
+	ExternalID      *string   `gorm:"type:varchar(100);uniqueIndex:idx_user_external" json:"external_id,omitempty"` // 导入账单中的交易号
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+
+	Attachments []Attachment   `gorm:"polymorphic:Owner;polymorphicValue:expenses" json:"attachments,omitempty"`
+	Splits      []ExpenseSplit `gorm:"foreignKey:ExpenseID" json:"splits,omitempty"`
 }
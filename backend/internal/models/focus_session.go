@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// FocusSession 专注计时（番茄钟），每个会话关联一条计划
+type FocusSession struct {
+	ID     uint `gorm:"primaryKey" json:"id"`
+	UserID uint `gorm:"index;not null" json:"user_id"`
+	PlanID uint `gorm:"index;not null" json:"plan_id"`
+	// ActiveUserID 仅在会话进行中或暂停时等于 UserID，唯一索引保证每个用户只有一个活动会话
+	ActiveUserID    *uint      `gorm:"uniqueIndex" json:"-"`
+	Status          string     `gorm:"type:varchar(20);not null" json:"status"` // active, paused, stopped
+	StartedAt       time.Time  `gorm:"index;not null" json:"started_at"`
+	PausedAt        *time.Time `json:"paused_at"`
+	PausedSeconds   int64      `gorm:"not null;default:0" json:"paused_seconds"`
+	EndedAt         *time.Time `json:"ended_at"`
+	DurationSeconds int64      `gorm:"not null;default:0" json:"duration_seconds"` // 结束时的有效专注时长
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// Goal 目标，进度由关联的已完成计划数或支出金额累计得出
+type Goal struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"index;not null" json:"user_id"`
+	Name        string    `gorm:"type:varchar(100);not null" json:"name"`
+	Description string    `gorm:"type:varchar(500);default:''" json:"description"`
+	Metric      string    `gorm:"type:varchar(20);not null" json:"metric"`                        // plans: 完成计划数, amount: 累计金额
+	AmountType  string    `gorm:"type:varchar(20);not null;default:'expense'" json:"amount_type"` // amount 目标累计的收支类型：expense 或 income
+	TargetValue float64   `gorm:"not null" json:"target_value"`
+	Unit        string    `gorm:"type:varchar(20);default:''" json:"unit"`
+	StartDate   time.Time `gorm:"not null" json:"start_date"`
+	Deadline    time.Time `gorm:"not null" json:"deadline"`
+	Archived    bool      `gorm:"default:false" json:"archived"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
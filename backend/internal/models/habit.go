@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// Habit 习惯，按每周目标次数打卡，与按日期执行的计划分开
+type Habit struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	UserID        uint      `gorm:"index;not null" json:"user_id"`
+	Name          string    `gorm:"type:varchar(100);not null" json:"name"`
+	TargetPerWeek int       `gorm:"not null;default:7" json:"target_per_week"` // 每周目标天数 1-7
+	DailyTarget   float64   `gorm:"not null;default:1" json:"daily_target"`    // 每天目标数量，如 8 杯水、30 分钟
+	Unit          string    `gorm:"type:varchar(20);default:''" json:"unit"`
+	GraceDays     int       `gorm:"not null;default:0" json:"grace_days"` // 连续打卡允许中断的天数
+	Archived      bool      `gorm:"default:false" json:"archived"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// HabitCheckIn 习惯每日打卡，同一天只有一条记录
+type HabitCheckIn struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	HabitID   uint      `gorm:"uniqueIndex:idx_habit_day;not null" json:"habit_id"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	Day       string    `gorm:"type:varchar(10);uniqueIndex:idx_habit_day;not null" json:"day"` // YYYY-MM-DD
+	Quantity  float64   `gorm:"not null;default:1" json:"quantity"`
+	Note      string    `gorm:"type:varchar(255);default:''" json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
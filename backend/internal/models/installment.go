@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// InstallmentPlan 分期付款计划，每月到期后由后台任务生成当期的本金和手续费支出
+type InstallmentPlan struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"index;not null" json:"user_id"`
+	Name        string     `gorm:"type:varchar(100);not null" json:"name"`
+	Principal   float64    `gorm:"not null" json:"principal"`
+	Currency    string     `gorm:"type:varchar(3);not null;default:'CNY'" json:"currency"` // 本金、手续费及生成支出的币种
+	Periods     int        `gorm:"not null" json:"periods"`
+	Fee         float64    `gorm:"not null;default:0" json:"fee"` // 全部期数的手续费合计
+	Category    string     `gorm:"type:varchar(50);not null" json:"category"`
+	Merchant    string     `gorm:"type:varchar(100);default:''" json:"merchant"`
+	Note        string     `gorm:"type:varchar(255);default:''" json:"note"`
+	AccountID   *uint      `gorm:"index" json:"account_id"`
+	StartDate   time.Time  `gorm:"not null" json:"start_date"` // 第一期还款日，之后每月同一天
+	PaidPeriods int        `gorm:"not null;default:0" json:"paid_periods"`
+	NextDueDate time.Time  `gorm:"index;not null" json:"next_due_date"`
+	Status      string     `gorm:"type:varchar(20);index;not null" json:"status"` // active, completed, settled
+	SettledAt   *time.Time `json:"settled_at"`                                    // 提前结清时间
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
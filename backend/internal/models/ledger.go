@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+)
+
+// Ledger 共享账本，成员记录的支出可以在成员间分摊；账本内的支出、分摊和结算均使用账本币种
+type Ledger struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	OwnerID   uint      `gorm:"index;not null" json:"owner_id"`
+	Name      string    `gorm:"type:varchar(100);not null" json:"name"`
+	Currency  string    `gorm:"type:varchar(3);not null;default:'CNY'" json:"currency"` // 创建时指定，缺省为所有者的本位币
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LedgerMember 账本成员及邀请状态，所有者不在此表中
+type LedgerMember struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	LedgerID  uint      `gorm:"uniqueIndex:idx_ledger_member;not null" json:"ledger_id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_ledger_member;index;not null" json:"user_id"`
+	Status    string    `gorm:"type:varchar(20);default:'pending'" json:"status"` // pending, accepted, declined
+	InvitedBy uint      `gorm:"not null" json:"invited_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ExpenseSplit 共享支出中某个成员应承担的金额，付款人为支出的 UserID
+type ExpenseSplit struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ExpenseID uint      `gorm:"uniqueIndex:idx_expense_split;not null" json:"expense_id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_expense_split;index;not null" json:"user_id"`
+	Share     float64   `gorm:"not null;default:0" json:"share"` // 按份数分摊时的份数，按金额分摊时为 0
+	Amount    float64   `gorm:"not null" json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LedgerSettlement 成员之间的结算还款
+type LedgerSettlement struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	LedgerID   uint      `gorm:"index;not null" json:"ledger_id"`
+	FromUserID uint      `gorm:"not null" json:"from_user_id"`
+	ToUserID   uint      `gorm:"not null" json:"to_user_id"`
+	Amount     float64   `gorm:"not null" json:"amount"`
+	Note       string    `gorm:"type:varchar(255);default:''" json:"note"`
+	SettledAt  time.Time `json:"settled_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// Loan 借出或借入的一笔钱，待收/待还金额由本金减去还款记录得出，不计入收支
+type Loan struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"index;not null" json:"user_id"`
+	Direction    string     `gorm:"type:varchar(20);not null" json:"direction"` // lend: 借出, borrow: 借入
+	Counterparty string     `gorm:"type:varchar(50);index;not null" json:"counterparty"`
+	Amount       float64    `gorm:"not null" json:"amount"`
+	Currency     string     `gorm:"type:varchar(3);not null;default:'CNY'" json:"currency"` // 本金和还款的币种
+	Note         string     `gorm:"type:varchar(255);default:''" json:"note"`
+	LoanedAt     time.Time  `gorm:"not null" json:"loaned_at"`
+	DueDate      *time.Time `gorm:"index" json:"due_date"`
+	Status       string     `gorm:"type:varchar(20);index;not null" json:"status"` // open, settled
+	SettledAt    *time.Time `json:"settled_at"`
+	ReminderID   *uint      `json:"reminder_id"` // 逾期后自动创建的提醒
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// LoanRepayment 借款的一笔还款（借出时为对方归还，借入时为自己归还），币种与借款相同
+type LoanRepayment struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	LoanID    uint      `gorm:"index;not null" json:"loan_id"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	Amount    float64   `gorm:"not null" json:"amount"`
+	Note      string    `gorm:"type:varchar(255);default:''" json:"note"`
+	RepaidAt  time.Time `gorm:"index" json:"repaid_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
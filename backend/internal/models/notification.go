@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// Notification 站内通知，客户端拉取后展示为本地通知
+type Notification struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	Type      string     `gorm:"type:varchar(30);not null" json:"type"`
+	Title     string     `gorm:"type:varchar(100);not null" json:"title"`
+	Content   string     `gorm:"type:varchar(500);default:''" json:"content"`
+	RefID     uint       `gorm:"default:0" json:"ref_id"` // 关联对象 ID，含义取决于 Type
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -7,9 +7,16 @@ import (
 type Plan struct {
 	ID            uint      `gorm:"primaryKey" json:"id"`
 	UserID        uint      `gorm:"index;not null" json:"user_id"`
+	ListID        *uint     `gorm:"index" json:"list_id"`
+	GoalID        *uint     `gorm:"index" json:"goal_id"`
 	Content       string    `gorm:"not null" json:"content"`
 	ExecutionDate time.Time `gorm:"index;not null" json:"execution_date"`
 	Status        string    `gorm:"default:'pending'" json:"status"`
+	Tags          string    `gorm:"type:varchar(255);default:''" json:"tags"`
+	CalendarUID   string    `gorm:"type:varchar(255)" json:"-"`
+	CalendarHref  string    `gorm:"type:varchar(255);index" json:"-"`
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
+
+	Attachments []Attachment `gorm:"polymorphic:Owner;polymorphicValue:plans" json:"attachments,omitempty"`
 }
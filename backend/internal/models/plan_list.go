@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// PlanList 计划清单，可共享给其他用户协作
+type PlanList struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	OwnerID   uint      `gorm:"index;not null" json:"owner_id"`
+	Name      string    `gorm:"type:varchar(100);not null" json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PlanListMember 清单成员及邀请状态
+type PlanListMember struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ListID    uint      `gorm:"uniqueIndex:idx_list_member;not null" json:"list_id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_list_member;index;not null" json:"user_id"`
+	Role      string    `gorm:"type:varchar(20);not null" json:"role"`            // viewer, editor
+	Status    string    `gorm:"type:varchar(20);default:'pending'" json:"status"` // pending, accepted, declined
+	InvitedBy uint      `gorm:"not null" json:"invited_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
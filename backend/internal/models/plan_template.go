@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// PlanTemplate 计划模板，一次性为某天生成多条计划
+type PlanTemplate struct {
+	ID          uint               `gorm:"primaryKey" json:"id"`
+	UserID      uint               `gorm:"index;not null" json:"user_id"`
+	Name        string             `gorm:"type:varchar(100);not null" json:"name"`
+	Description string             `gorm:"type:varchar(500);default:''" json:"description"`
+	StartTime   string             `gorm:"type:varchar(5);default:'00:00'" json:"start_time"` // HH:MM，条目时间相对于此
+	Items       []PlanTemplateItem `gorm:"foreignKey:TemplateID;constraint:OnDelete:CASCADE" json:"items"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+}
+
+// PlanTemplateItem 模板条目，OffsetMinutes 为相对模板开始时间的分钟数
+type PlanTemplateItem struct {
+	ID            uint   `gorm:"primaryKey" json:"id"`
+	TemplateID    uint   `gorm:"index;not null" json:"template_id"`
+	Content       string `gorm:"not null" json:"content"`
+	OffsetMinutes int    `gorm:"not null;default:0" json:"offset_minutes"`
+	SortOrder     int    `gorm:"not null;default:0" json:"sort_order"`
+}
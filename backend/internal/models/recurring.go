@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// RecurringExpense 周期支出定义，到期后由后台任务生成实际的支出记录
+type RecurringExpense struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	UserID         uint       `gorm:"index;not null" json:"user_id"`
+	Name           string     `gorm:"type:varchar(100);not null" json:"name"`
+	Amount         float64    `gorm:"not null" json:"amount"`
+	Currency       string     `gorm:"type:varchar(3);not null;default:'CNY'" json:"currency"`
+	Category       string     `gorm:"type:varchar(50);not null" json:"category"`
+	Merchant       string     `gorm:"type:varchar(100);default:''" json:"merchant"`
+	Note           string     `gorm:"type:varchar(255);default:''" json:"note"`
+	Interval       string     `gorm:"type:varchar(10);not null" json:"interval"` // daily, weekly, monthly, yearly
+	IntervalCount  int        `gorm:"not null;default:1" json:"interval_count"`  // 每 N 个周期
+	StartDate      time.Time  `gorm:"not null" json:"start_date"`
+	NextDueDate    time.Time  `gorm:"index;not null" json:"next_due_date"`
+	EndDate        *time.Time `json:"end_date"`
+	IsSubscription bool       `gorm:"default:false" json:"is_subscription"`
+	Active         bool       `gorm:"default:true" json:"active"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
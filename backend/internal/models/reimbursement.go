@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+// ReimbursementClaim 报销单，由若干垫付支出组成；到账后记录一笔报销收入
+type ReimbursementClaim struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"index;not null" json:"user_id"`
+	Title       string     `gorm:"type:varchar(100);not null" json:"title"`
+	Note        string     `gorm:"type:varchar(255);default:''" json:"note"`
+	Status      string     `gorm:"type:varchar(20);index;not null" json:"status"`          // pending, submitted, reimbursed, rejected
+	Total       float64    `gorm:"not null;default:0" json:"total"`                        // 报销单内支出换算为 Currency 后的合计
+	Currency    string     `gorm:"type:varchar(3);not null;default:'CNY'" json:"currency"` // 合计和到账金额的币种，为计算合计时用户的本位币
+	PaidAmount  float64    `gorm:"not null;default:0" json:"paid_amount"`                  // 实际到账金额
+	IncomeID    *uint      `json:"income_id"`                                              // 到账时记录的收入
+	SubmittedAt *time.Time `json:"submitted_at"`
+	PaidAt      *time.Time `json:"paid_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	Expenses []Expense `gorm:"foreignKey:ClaimID" json:"expenses,omitempty"`
+}
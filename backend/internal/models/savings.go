@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// SavingsGoal 储蓄目标（旅行、换电脑等），已存金额由存入和取出记录累计得出
+type SavingsGoal struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	UserID          uint      `gorm:"index;not null" json:"user_id"`
+	Name            string    `gorm:"type:varchar(100);not null" json:"name"`
+	Note            string    `gorm:"type:varchar(255);default:''" json:"note"`
+	TargetAmount    float64   `gorm:"not null" json:"target_amount"`
+	TargetDate      time.Time `gorm:"not null" json:"target_date"`
+	AutoSavePercent float64   `gorm:"not null;default:0" json:"auto_save_percent"` // 记录收入时自动存入收入的百分比，0 表示不自动存入
+	Archived        bool      `gorm:"default:false" json:"archived"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// SavingsEntry 储蓄目标的一笔存入或取出，自动存入的记录关联来源收入
+type SavingsEntry struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	GoalID          uint      `gorm:"index;not null" json:"goal_id"`
+	UserID          uint      `gorm:"index;not null" json:"user_id"`
+	Type            string    `gorm:"type:varchar(20);not null" json:"type"` // deposit, withdraw
+	Amount          float64   `gorm:"not null" json:"amount"`
+	Note            string    `gorm:"type:varchar(255);default:''" json:"note"`
+	SourceExpenseID *uint     `gorm:"index" json:"source_expense_id"`
+	OccurredAt      time.Time `gorm:"index" json:"occurred_at"`
+	CreatedAt       time.Time `json:"created_at"`
+}
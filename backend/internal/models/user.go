@@ -5,12 +5,13 @@ import (
 )
 
 type User struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	Username    string    `gorm:"type:varchar(50);uniqueIndex" json:"username"`
-	PhoneNumber string    `gorm:"type:varchar(20);uniqueIndex;not null" json:"phone_number"`
-	Password    string    `gorm:"type:varchar(255);not null" json:"-"`
-	Nickname    string    `gorm:"type:varchar(50);default:''" json:"nickname"`
-	Avatar      string    `gorm:"type:varchar(500);default:''" json:"avatar"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Username     string    `gorm:"type:varchar(50);uniqueIndex" json:"username"`
+	PhoneNumber  string    `gorm:"type:varchar(20);uniqueIndex;not null" json:"phone_number"`
+	Password     string    `gorm:"type:varchar(255);not null" json:"-"`
+	Nickname     string    `gorm:"type:varchar(50);default:''" json:"nickname"`
+	Avatar       string    `gorm:"type:varchar(500);default:''" json:"avatar"`
+	BaseCurrency string    `gorm:"type:varchar(3);not null;default:'CNY'" json:"base_currency"` // 统计时换算到的本位币
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }
@@ -0,0 +1,41 @@
+package scheduler
+
+import (
+	"log"
+	"time"
+)
+
+// Job 周期执行的后台任务
+type Job struct {
+	Name string
+	Run  func(now time.Time) error
+}
+
+// Start 启动后台任务：立即执行一次，之后每隔 interval 执行；同一任务不会并发执行
+func Start(interval time.Duration, jobs ...Job) {
+	for _, job := range jobs {
+		go run(interval, job)
+	}
+}
+
+func run(interval time.Duration, job Job) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		runOnce(job)
+		<-ticker.C
+	}
+}
+
+func runOnce(job Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Scheduled job %s panicked: %v", job.Name, r)
+		}
+	}()
+
+	if err := job.Run(time.Now()); err != nil {
+		log.Printf("Scheduled job %s failed: %v", job.Name, err)
+	}
+}
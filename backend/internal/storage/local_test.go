@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLocalSignedURLVerification(t *testing.T) {
+	s, err := NewLocalStorage(t.TempDir(), "http://localhost:8080/files/", "secret")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if _, err := s.path("../etc/passwd"); err == nil {
+		t.Error("Expected error for key escaping the root directory")
+	}
+
+	expires := strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10)
+	sig := s.sign("1/a.pdf", expires)
+
+	if !s.Verify("1/a.pdf", expires, sig) {
+		t.Error("Expected valid signature to verify")
+	}
+	if s.Verify("1/b.pdf", expires, sig) {
+		t.Error("Signature should not verify for a different key")
+	}
+	past := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	if s.Verify("1/a.pdf", past, s.sign("1/a.pdf", past)) {
+		t.Error("Expired signature should not verify")
+	}
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"daily-planner-backend/internal/config"
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("storage: object not found")
+
+// Storage 附件存储后端
+type Storage interface {
+	// Put 写入对象，size 为 -1 时表示长度未知
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open 读取对象内容
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 删除对象，对象不存在时不返回错误
+	Delete(ctx context.Context, key string) error
+	// SignedURL 生成限时下载地址
+	SignedURL(key string, expires time.Duration) (string, error)
+}
+
+var store Storage
+
+// Init 根据配置初始化存储后端
+func Init(cfg *config.Config) error {
+	switch cfg.StorageDriver {
+	case "s3":
+		s, err := NewS3Storage(S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: cfg.S3PathStyle,
+		})
+		if err != nil {
+			return err
+		}
+		store = s
+	case "local", "":
+		s, err := NewLocalStorage(cfg.StorageLocalDir, cfg.PublicBaseURL+LocalFilesPath, cfg.JWTSecret)
+		if err != nil {
+			return err
+		}
+		store = s
+	default:
+		return errors.New("storage: unknown driver " + cfg.StorageDriver)
+	}
+	return nil
+}
+
+func GetStorage() Storage {
+	return store
+}